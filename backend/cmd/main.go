@@ -3,8 +3,16 @@ package main
 import (
 	"coachella-backend/config"              // Database configuration package
 	_ "coachella-backend/docs"              // Swagger docs package (import for side effects)
+	"coachella-backend/internal/email"      // Email outbox and mail transports
 	"coachella-backend/internal/handlers"   // Handlers
+	"coachella-backend/internal/lockout"    // Failed login tracking
 	"coachella-backend/internal/middleware" // Middleware for authentication and authorization
+	"coachella-backend/internal/notify"     // Notification dispatcher
+	"coachella-backend/internal/push"       // Push notification providers
+	"coachella-backend/internal/rbac"       // Roles and permissions
+	"coachella-backend/internal/realtime"   // Notification streaming hub
+	"coachella-backend/internal/signing"    // JWT signing keys
+	"coachella-backend/internal/sms"        // SMS providers
 	"coachella-backend/internal/tasks"      // Scheduled tasks
 	"github.com/gin-gonic/gin"
 	"github.com/go-co-op/gocron"
@@ -14,7 +22,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // Embedded zone data so venue time zones resolve on any host
 )
 
 // @title Coachella API Documentation
@@ -44,11 +55,61 @@ func main() {
 	// Initialize database connection
 	config.ConnectDatabase()
 
+	// Seed the built-in roles and permissions
+	if err := rbac.Seed(); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+
+	// Load the JWT signing keys, generating the first one on a fresh database
+	if err := signing.Load(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	// Select where failed logins are tracked; instances behind a load balancer must share it
+	loginStore, err := lockout.NewStoreFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure login attempt store: %v", err)
+	}
+	lockout.Configure(loginStore, lockout.PolicyFromEnv())
+
+	// Parse the email templates and register the hub's database callbacks
+	// before anything runs in the background: jobs and workers render emails
+	// and create notifications from their first run
+	if err := email.LoadTemplates(); err != nil {
+		log.Fatalf("Failed to load email templates: %v", err)
+	}
+
+	// Push new notifications to connected clients
+	hub, err := realtime.NewHubFromEnv(config.DB)
+	if err != nil {
+		log.Fatalf("Failed to start notification hub: %v", err)
+	}
+
+	// Queue emails in the database outbox, then select the mail transport
+	// and start delivering them
+	email.UseOutbox(email.NewDBOutbox())
+	mailer, err := email.NewMailerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure mail transport: %v", err)
+	}
+	email.StartWorkers(mailer, emailWorkerCount())
+
+	// Select the SMS and push providers and start delivering queued notifications
+	smsSender, err := sms.NewSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure SMS provider: %v", err)
+	}
+	pushSender, err := push.NewSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure push provider: %v", err)
+	}
+	notify.StartWorkers(notify.Senders{SMS: smsSender, Push: pushSender}, 2)
+
 	// Initialize scheduler for background tasks
 	initializeScheduler()
 
 	// Set up Gin router
-	router := setupRouter()
+	router := setupRouter(mailer, smsSender, hub)
 
 	// Start the server
 	log.Println("Starting server on port 8080...")
@@ -73,13 +134,21 @@ func loadEnv() error {
 
 // initializeScheduler sets up and starts the task scheduler
 func initializeScheduler() {
-	scheduler := gocron.NewScheduler(time.Local)
+	// Jobs work out each event's local time themselves, so the scheduler runs in UTC
+	scheduler := gocron.NewScheduler(time.UTC)
 
 	// Schedule tasks
-	scheduler.Every(1).Day().At("09:00").Do(handlers.SendEventReminders) // Event reminders
+	scheduler.Cron("*/5 * * * *").Do(handlers.SendEventReminders)  // Reminder rules measured from each event's doors
+	scheduler.Every(1).Minute().Do(handlers.SendFavoriteReminders) // "Starting soon" set reminders
+	scheduler.Every(1).Minute().Do(handlers.SendBroadcasts)        // Rate-limited admin broadcasts
+	scheduler.Every(1).Minute().Do(signing.Refresh)                // Pick up keys rotated by other instances
+	scheduler.Every(1).Hour().Do(signing.Rotate)                   // Scheduled JWT signing key rotation
 	go func() {
 		for {
 			tasks.CleanUpExpiredTransactions() // Cleanup expired transactions
+			tasks.CleanUpExpiredSeatHolds()    // Release lapsed seat holds
+			tasks.CleanUpExpiredSessions()     // Forget long-expired logins
+			tasks.CleanUpLoginAttempts()       // Forget stale failed logins
 			time.Sleep(1 * time.Minute)
 		}
 	}()
@@ -87,40 +156,197 @@ func initializeScheduler() {
 	scheduler.StartAsync()
 }
 
+// emailWorkerCount reads the outbox worker pool size from EMAIL_WORKERS (default 4)
+func emailWorkerCount() int {
+	if n, err := strconv.Atoi(os.Getenv("EMAIL_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+// trustedProxies reads the comma-separated proxy addresses or CIDR ranges in
+// TRUSTED_PROXIES. Unset trusts none, so the client address is the peer's.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 // setupRouter initializes the Gin router and routes
-func setupRouter() *gin.Engine {
+func setupRouter(mailer email.Mailer, smsSender sms.Sender, hub *realtime.Hub) *gin.Engine {
 	r := gin.Default()
 
+	// Only take the client address from X-Forwarded-For when the request
+	// comes through one of our own proxies; login lockouts are keyed on it
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Test endpoint for email
-	r.GET("/test-email", handlers.SendTestEmail)
+	// Test endpoint for email, for staff checking the mail transport
+	r.GET("/test-email", middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"),
+		middleware.RequirePermission(rbac.EmailsResend), handlers.SendTestEmail(mailer))
 
 	// Authentication routes
 	r.POST("/auth/admin-login", handlers.AdminLogin)
 	r.POST("/auth/user-login", handlers.UserLogin)
+	r.POST("/auth/register", handlers.Register)
+	r.GET("/auth/verify-email", handlers.VerifyEmail)
+	r.POST("/auth/resend-verification", handlers.ResendVerification)
+	r.POST("/auth/forgot-password", handlers.ForgotPassword)
+	r.POST("/auth/admin-forgot-password", handlers.AdminForgotPassword)
+	r.GET("/auth/reset-password", handlers.ShowResetPassword)
+	r.POST("/auth/reset-password", handlers.ResetPassword)
+	r.POST("/auth/refresh", handlers.RefreshSession)
+	r.POST("/auth/mfa/enroll", handlers.ChallengeEnrollMFA)
+	r.POST("/auth/mfa/verify", handlers.VerifyMFA)
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
+	r.POST("/auth/logout", middleware.AuthMiddleware(), handlers.Logout)
+	r.POST("/auth/logout-all", middleware.AuthMiddleware(), handlers.LogoutAll)
 
-	// Admin routes (protected)
+	// Staff routes (protected); every staff account manages its own login,
+	// and everything else needs a permission from the account's roles
 	adminGroup := r.Group("/admin", middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"))
 	{
-		adminGroup.POST("/tickets", handlers.CreateTicket)
-		adminGroup.PUT("/tickets/:id", handlers.UpdateTicket)
-		adminGroup.DELETE("/tickets/:id", handlers.DeleteTicket)
+		adminGroup.POST("/change-password", handlers.ChangePassword)
+		adminGroup.GET("/sessions", handlers.GetSessions)
+		adminGroup.DELETE("/sessions/:id", handlers.RevokeSession)
+		adminGroup.GET("/mfa", handlers.GetMFAStatus)
+		adminGroup.POST("/mfa/enroll", handlers.EnrollMFA)
+		adminGroup.POST("/mfa/confirm", handlers.ConfirmMFA)
+		adminGroup.POST("/mfa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		adminGroup.POST("/mfa/disable", handlers.DisableMFA)
 	}
+	eventAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.EventsWrite))
+	{
+		eventAdmin.POST("/events", handlers.CreateEvent)
+		eventAdmin.PUT("/events/:id", handlers.UpdateEvent)
+		eventAdmin.POST("/events/:id/archive", handlers.ArchiveEvent)
+		eventAdmin.DELETE("/events/:id", handlers.DeleteEvent)
+	}
+	adminGroup.GET("/events", middleware.RequirePermission(rbac.EventsRead), handlers.GetAllEvents)
+	lineupAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.LineupWrite))
+	{
+		lineupAdmin.POST("/events/:id/stages", handlers.CreateStage)
+		lineupAdmin.DELETE("/stages/:id", handlers.DeleteStage)
+		lineupAdmin.GET("/artists", handlers.GetArtists)
+		lineupAdmin.POST("/artists", handlers.CreateArtist)
+		lineupAdmin.PUT("/artists/:id", handlers.UpdateArtist)
+		lineupAdmin.DELETE("/artists/:id", handlers.DeleteArtist)
+		lineupAdmin.GET("/events/:id/performances", handlers.GetEventPerformances)
+		lineupAdmin.POST("/events/:id/performances", handlers.CreatePerformance)
+		lineupAdmin.PUT("/performances/:id", handlers.UpdatePerformance)
+		lineupAdmin.DELETE("/performances/:id", handlers.DeletePerformance)
+		lineupAdmin.POST("/events/:id/schedule/publish", handlers.PublishSchedule)
+	}
+	reminderAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.RemindersWrite))
+	{
+		reminderAdmin.GET("/events/:id/reminder-rules", handlers.GetReminderRules)
+		reminderAdmin.POST("/events/:id/reminder-rules", handlers.CreateReminderRule)
+		reminderAdmin.PUT("/reminder-rules/:id", handlers.UpdateReminderRule)
+		reminderAdmin.DELETE("/reminder-rules/:id", handlers.DeleteReminderRule)
+	}
+	broadcastAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.BroadcastsSend))
+	{
+		broadcastAdmin.POST("/events/:id/broadcasts/preview", handlers.PreviewBroadcast)
+		broadcastAdmin.GET("/events/:id/broadcasts", handlers.GetEventBroadcasts)
+		broadcastAdmin.POST("/events/:id/broadcasts", handlers.CreateBroadcast)
+		broadcastAdmin.GET("/broadcasts/:id", handlers.GetBroadcastByID)
+		broadcastAdmin.POST("/broadcasts/:id/cancel", handlers.CancelBroadcast)
+	}
+	adminGroup.POST("/checkin", middleware.RequirePermission(rbac.CheckinScan), handlers.CheckInTicket)
+	emailAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.EmailsRead))
+	{
+		emailAdmin.GET("/emails", handlers.GetEmails)
+		emailAdmin.GET("/emails/:id", handlers.GetEmailByID)
+		emailAdmin.POST("/emails/:id/resend", middleware.RequirePermission(rbac.EmailsResend), handlers.ResendEmail)
+	}
+	ticketAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.TicketsWrite))
+	{
+		ticketAdmin.POST("/tickets", handlers.CreateTicket)
+		ticketAdmin.PUT("/tickets/:id", handlers.UpdateTicket)
+		ticketAdmin.DELETE("/tickets/:id", handlers.DeleteTicket)
+	}
+	seatingAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.SeatingWrite))
+	{
+		seatingAdmin.POST("/sites", handlers.CreateSite)
+		seatingAdmin.PUT("/sites/:id", handlers.UpdateSite)
+		seatingAdmin.DELETE("/sites/:id", handlers.DeleteSite)
+		seatingAdmin.GET("/site-allocations", handlers.GetSiteAllocations)
+		seatingAdmin.PUT("/site-allocations/:id", handlers.ReassignSite)
+		seatingAdmin.POST("/venue-sections", handlers.CreateVenueSection)
+		seatingAdmin.DELETE("/venue-sections/:id", handlers.DeleteVenueSection)
+	}
+	// Acting on behalf of a user is explicit: the user is always in the path
+	onBehalfRead := adminGroup.Group("", middleware.RequirePermission(rbac.TransactionsRead))
+	{
+		onBehalfRead.GET("/users/:id/transactions", handlers.GetTransactionsForUser)
+		onBehalfRead.GET("/waitlist", handlers.GetWaitlist)
+	}
+	onBehalfWrite := adminGroup.Group("", middleware.RequirePermission(rbac.TransactionsWrite))
+	{
+		onBehalfWrite.POST("/users/:id/transactions", handlers.CreateTransactionForUser)
+		onBehalfWrite.POST("/users/:id/waitlist", handlers.AddToWaitlistForUser)
+	}
+	roleAdmin := adminGroup.Group("", middleware.RequirePermission(rbac.RolesManage))
+	{
+		roleAdmin.GET("/permissions", handlers.GetPermissions)
+		roleAdmin.GET("/roles", handlers.GetRoles)
+		roleAdmin.POST("/roles", handlers.CreateRole)
+		roleAdmin.PUT("/roles/:id", handlers.UpdateRole)
+		roleAdmin.DELETE("/roles/:id", handlers.DeleteRole)
+		roleAdmin.GET("/accounts/:type/:id/roles", handlers.GetAccountRoles)
+		roleAdmin.POST("/accounts/:type/:id/roles", handlers.AssignRole)
+		roleAdmin.DELETE("/accounts/:type/:id/roles/:role_id", handlers.RemoveRole)
+		roleAdmin.POST("/staff", handlers.CreateStaff)
+	}
+	adminGroup.POST("/accounts/:type/:id/unlock", middleware.RequirePermission(rbac.AccountsUnlock), handlers.UnlockAccount)
 
 	// User routes (protected)
-	userGroup := r.Group("/user", middleware.AuthMiddleware(), middleware.RoleMiddleware("user"))
+	userGroup := r.Group("/user", middleware.AuthMiddleware(), middleware.RoleMiddleware("user"), middleware.RequirePermission(rbac.AccountSelf))
 	{
 		userGroup.GET("/transactions", handlers.GetUserTransactions)
-		userGroup.POST("/transactions", handlers.CreateTransaction)
+		userGroup.GET("/transactions/:id/sites", handlers.GetTransactionSites)
+		userGroup.GET("/transactions/:id/tickets.pdf", handlers.GetTransactionTicketsPDF)
+		userGroup.GET("/transactions/:id/invoice.pdf", handlers.GetTransactionInvoicePDF)
+		userGroup.GET("/favorites", handlers.GetFavorites)
+		userGroup.POST("/favorites", handlers.AddFavorite)
+		userGroup.PATCH("/favorites/:id", handlers.UpdateFavorite)
+		userGroup.DELETE("/favorites/:id", handlers.RemoveFavorite)
+		userGroup.GET("/schedule", handlers.GetPersonalSchedule)
+		userGroup.GET("/schedule/export", handlers.ExportPersonalSchedule)
+		userGroup.GET("/calendar-feed", handlers.GetCalendarFeedURL)
+		userGroup.POST("/calendar-feed/reset", handlers.ResetCalendarFeedURL)
+		userGroup.PUT("/language", handlers.UpdateLanguage)
+		userGroup.GET("/notification-preferences", handlers.GetNotificationSettings)
+		userGroup.PUT("/notification-preferences", handlers.UpdateNotificationSettings)
+		userGroup.POST("/change-password", handlers.ChangePassword)
+		userGroup.GET("/sessions", handlers.GetSessions)
+		userGroup.DELETE("/sessions/:id", handlers.RevokeSession)
+		userGroup.GET("/mfa", handlers.GetMFAStatus)
+		userGroup.POST("/mfa/enroll", handlers.EnrollMFA)
+		userGroup.POST("/mfa/confirm", handlers.ConfirmMFA)
+		userGroup.POST("/mfa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		userGroup.POST("/mfa/disable", handlers.DisableMFA)
+		userGroup.PUT("/phone", handlers.UpdatePhone)
+		userGroup.DELETE("/phone", handlers.DeletePhone)
+		userGroup.GET("/devices", handlers.GetDevices)
+		userGroup.POST("/devices", handlers.RegisterDevice)
+		userGroup.DELETE("/devices/:id", handlers.DeleteDevice)
 	}
-
-	// Waitlist routes
-	waitlistGroup := r.Group("/waitlist")
+	purchaseGroup := userGroup.Group("", middleware.RequirePermission(rbac.TicketsPurchase))
 	{
-		waitlistGroup.POST("", handlers.AddUserToWaitlist)
-		waitlistGroup.GET("", handlers.GetWaitlist)
+		purchaseGroup.POST("/transactions", handlers.CreateTransaction)
+		purchaseGroup.POST("/transactions/:id/sites", handlers.AllocateSites)
+		purchaseGroup.POST("/seat-holds", handlers.HoldSeats)
+		purchaseGroup.DELETE("/seat-holds/:id", handlers.ReleaseSeatHold)
+		purchaseGroup.POST("/waitlist", handlers.AddUserToWaitlist)
 	}
 
 	// Ticket routes (public)
@@ -130,31 +356,67 @@ func setupRouter() *gin.Engine {
 		ticketGroup.GET("/:id", handlers.GetTicketByID)
 	}
 
+	// Site routes (public)
+	r.GET("/sites", handlers.GetSites)
+
+	// Event routes (public)
+	eventGroup := r.Group("/events")
+	{
+		eventGroup.GET("", handlers.GetEvents)
+		eventGroup.GET("/:id", handlers.GetEventByID)
+		eventGroup.GET("/:id/seat-map", handlers.GetSeatMap)
+		eventGroup.GET("/:id/lineup", handlers.GetEventLineup)
+		eventGroup.GET("/:id/schedule", handlers.GetEventSchedule)
+		eventGroup.GET("/:id/calendar.ics", handlers.GetEventCalendar)
+	}
+
+	// Calendar subscription feed (authenticated by the token in the URL)
+	r.GET("/calendar/:token/events.ics", handlers.GetCalendarFeed)
+
+	// Email unsubscribe links (authenticated by the signed token in the URL)
+	r.GET("/unsubscribe", handlers.ShowUnsubscribe)
+	r.POST("/unsubscribe", handlers.Unsubscribe)
+
+	// Provider delivery status callbacks (authenticated by request signature),
+	// only served for the provider that is sending
+	if smsSender != nil && smsSender.Name() == "twilio" {
+		r.POST("/webhooks/twilio/status", handlers.TwilioStatusCallback)
+	}
+
 	// Transaction routes (admin-only)
-	transactionGroup := r.Group("/transactions", middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"))
+	transactionGroup := r.Group("/transactions", middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"), middleware.RequirePermission(rbac.TransactionsRead))
 	{
 		transactionGroup.GET("", handlers.GetTransactions)
 		transactionGroup.GET("/:id", handlers.GetTransactionByID)
-		transactionGroup.GET("/user-transactions", handlers.GetUserTransactions)
+		transactionGroup.POST("/:id/confirm-payment", middleware.RequirePermission(rbac.TransactionsWrite), handlers.ConfirmPayment)
 	}
 
 	// Notification routes (user-specific)
-	notificationGroup := r.Group("/notifications", middleware.AuthMiddleware())
+	notificationGroup := r.Group("/notifications", middleware.AuthMiddleware(), middleware.RoleMiddleware("user"), middleware.RequirePermission(rbac.NotificationsRead))
 	{
 		notificationGroup.GET("", handlers.GetNotifications)
+		notificationGroup.POST("/stream-ticket", handlers.CreateStreamTicket)
+		notificationGroup.GET("/unread-count", handlers.GetUnreadNotificationCount)
+		notificationGroup.POST("/read-all", handlers.MarkAllNotificationsAsRead)
 		notificationGroup.PATCH("/:id", handlers.MarkNotificationAsRead)
+		notificationGroup.DELETE("/:id", handlers.DeleteNotification)
+		notificationGroup.GET("/:id/deliveries", handlers.GetNotificationDeliveries)
 	}
 
+	// Notification stream; EventSource cannot set headers, so it takes a
+	// short-lived ticket from /notifications/stream-ticket in the URL
+	r.GET("/notifications/stream",
+		middleware.StreamTicketMiddleware(), middleware.RoleMiddleware("user"),
+		middleware.RequirePermission(rbac.NotificationsRead), handlers.StreamNotifications(hub))
+
 	// Protected test route
 	r.GET("/protected", middleware.AuthMiddleware(), func(c *gin.Context) {
-		userID := c.GetString("id")
-		userType := c.GetString("type")
-		email := c.GetString("email")
+		principal, _ := middleware.CurrentPrincipal(c)
 		c.JSON(200, gin.H{
 			"message":   "Access granted",
-			"user_id":   userID,
-			"user_type": userType,
-			"email":     email,
+			"user_id":   principal.ID,
+			"user_type": principal.AccountType,
+			"email":     principal.Email,
 		})
 	})
 
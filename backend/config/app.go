@@ -0,0 +1,11 @@
+package config
+
+import "os"
+
+// BaseURL is the public URL of the API, used in links sent to users (APP_BASE_URL)
+func BaseURL() string {
+	if url := os.Getenv("APP_BASE_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:8080"
+}
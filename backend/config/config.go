@@ -11,25 +11,59 @@ var DB *gorm.DB
 
 func ConnectDatabase() {
     dsn := "root:root@tcp(127.0.0.1:3306)/coachella?charset=utf8mb4&parseTime=True&loc=Local"
-    database, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+    // TranslateError reports unique key violations as gorm.ErrDuplicatedKey
+    database, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
     if err != nil {
         panic("Failed to connect to database!")
     }
 
-    // Migrate all models
-	err = database.AutoMigrate(
+    err = Migrate(database)
+    if err != nil {
+        panic("Failed to migrate database!")
+    }
+
+    fmt.Println("Database connected and migrated successfully!")
+    DB = database
+}
+
+// Migrate creates or updates the tables of all models
+func Migrate(database *gorm.DB) error {
+    return database.AutoMigrate(
         &models.User{},
         &models.Admin{},
         &models.Event{},
         &models.Ticket{},
         &models.Transaction{},
         &models.Notification{},
+        &models.Site{},
+        &models.SiteAllocation{},
+        &models.VenueSection{},
+        &models.Seat{},
+        &models.SeatHold{},
+        &models.Artist{},
+        &models.Stage{},
+        &models.Performance{},
+        &models.Favorite{},
+        &models.ReminderRule{},
+        &models.ReminderLog{},
+        &models.EmailMessage{},
+        &models.EmailAttachment{},
+        &models.IssuedTicket{},
+        &models.NotificationPreference{},
+        &models.DeviceToken{},
+        &models.NotificationDelivery{},
+        &models.Broadcast{},
+        &models.BroadcastRecipient{},
+        &models.PasswordResetToken{},
+        &models.Session{},
+        &models.RefreshToken{},
+        &models.SigningKey{},
+        &models.MFAFactor{},
+        &models.RecoveryCode{},
+        &models.Permission{},
+        &models.Role{},
+        &models.AccountRole{},
+        &models.RoleGrantSeed{},
+        &models.LoginAttempt{},
     )
-
-    if err != nil {
-        panic("Failed to migrate database!")
-    }
-
-    fmt.Println("Database connected and migrated successfully!")
-    DB = database
 }
@@ -0,0 +1,244 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContentType is the MIME type of iCalendar data
+const ContentType = "text/calendar; charset=utf-8"
+
+const (
+	dateFormat      = "20060102"
+	localTimeFormat = "20060102T150405"
+	utcTimeFormat   = "20060102T150405Z"
+)
+
+// Calendar is an RFC 5545 VCALENDAR object
+type Calendar struct {
+	Name     string         // Shown by clients as the calendar title
+	Location *time.Location // Default zone, advertised to clients as X-WR-TIMEZONE
+	Events   []Event
+}
+
+// Event is a VEVENT. Timed events are written in their Location using a
+// TZID reference; all-day events use DATE values and End is exclusive.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Place       string
+	URL         string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Location    *time.Location
+}
+
+// Bytes renders the calendar, including a VTIMEZONE for every zone its timed events use
+func (c *Calendar) Bytes() []byte {
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Coachella//Coachella Backend//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		w.line("X-WR-CALNAME:" + escape(c.Name))
+	}
+	if c.Location != nil {
+		w.line("X-WR-TIMEZONE:" + c.Location.String())
+	}
+
+	for _, zone := range c.timezones() {
+		writeTimezone(w, zone.location, zone.from, zone.to)
+	}
+
+	stamp := time.Now().UTC().Format(utcTimeFormat)
+	for _, event := range c.Events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + event.UID)
+		w.line("DTSTAMP:" + stamp)
+		if event.AllDay {
+			w.line("DTSTART;VALUE=DATE:" + event.Start.Format(dateFormat))
+			w.line("DTEND;VALUE=DATE:" + event.End.Format(dateFormat))
+		} else {
+			w.line("DTSTART" + dateTime(event.Start, event.Location))
+			w.line("DTEND" + dateTime(event.End, event.Location))
+		}
+		w.line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			w.line("DESCRIPTION:" + escape(event.Description))
+		}
+		if event.Place != "" {
+			w.line("LOCATION:" + escape(event.Place))
+		}
+		if event.URL != "" {
+			w.line("URL:" + event.URL)
+		}
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes()
+}
+
+type zoneRange struct {
+	location *time.Location
+	from, to time.Time
+}
+
+// timezones collects the zones used by timed events and the span of time each covers
+func (c *Calendar) timezones() []zoneRange {
+	byName := map[string]*zoneRange{}
+	for _, event := range c.Events {
+		if event.AllDay || !usesTZID(event.Location) {
+			continue
+		}
+		zone, ok := byName[event.Location.String()]
+		if !ok {
+			zone = &zoneRange{location: event.Location, from: event.Start, to: event.End}
+			byName[event.Location.String()] = zone
+		}
+		if event.Start.Before(zone.from) {
+			zone.from = event.Start
+		}
+		if event.End.After(zone.to) {
+			zone.to = event.End
+		}
+	}
+
+	zones := make([]zoneRange, 0, len(byName))
+	for _, zone := range byName {
+		zones = append(zones, *zone)
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].location.String() < zones[j].location.String() })
+	return zones
+}
+
+// usesTZID reports whether times in loc are written with a TZID parameter rather than in UTC
+func usesTZID(loc *time.Location) bool {
+	return loc != nil && loc != time.UTC && loc.String() != "UTC" && loc.String() != "Local"
+}
+
+// dateTime formats the value of a DTSTART or DTEND property, including its parameters
+func dateTime(t time.Time, loc *time.Location) string {
+	if !usesTZID(loc) {
+		return ":" + t.UTC().Format(utcTimeFormat)
+	}
+	return ";TZID=" + loc.String() + ":" + t.In(loc).Format(localTimeFormat)
+}
+
+// writeTimezone writes a VTIMEZONE for loc with every offset transition from
+// the year before from until the end of to's year, taken from the Go time zone
+// database. Starting a year early covers the observance in effect on January 1.
+func writeTimezone(w *writer, loc *time.Location, from, to time.Time) {
+	start := time.Date(from.In(loc).Year()-1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.In(loc).Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+
+	transitions := 0
+	previous := start.In(loc)
+	for day := start.AddDate(0, 0, 1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		current := day.In(loc)
+		_, previousOffset := previous.Zone()
+		if _, offset := current.Zone(); offset != previousOffset {
+			writeTransition(w, findTransition(previous, current), previousOffset)
+			transitions++
+		}
+		previous = current
+	}
+
+	// Zones without daylight saving still need one observance
+	if transitions == 0 {
+		name, offset := start.In(loc).Zone()
+		w.line("BEGIN:STANDARD")
+		w.line("DTSTART:19700101T000000")
+		w.line("TZOFFSETFROM:" + formatOffset(offset))
+		w.line("TZOFFSETTO:" + formatOffset(offset))
+		w.line("TZNAME:" + name)
+		w.line("END:STANDARD")
+	}
+
+	w.line("END:VTIMEZONE")
+}
+
+// findTransition narrows down the instant the offset changes between two times
+func findTransition(before, after time.Time) time.Time {
+	_, offset := before.Zone()
+	for after.Sub(before) > time.Second {
+		middle := before.Add(after.Sub(before) / 2)
+		if _, middleOffset := middle.Zone(); middleOffset == offset {
+			before = middle
+		} else {
+			after = middle
+		}
+	}
+	return after.Truncate(time.Second)
+}
+
+// writeTransition writes a STANDARD or DAYLIGHT observance starting at the transition
+func writeTransition(w *writer, transition time.Time, offsetFrom int) {
+	name, offsetTo := transition.Zone()
+	component := "STANDARD"
+	if transition.IsDST() {
+		component = "DAYLIGHT"
+	}
+
+	// DTSTART is the local wall-clock time in the offset being left
+	onset := transition.UTC().Add(time.Duration(offsetFrom) * time.Second)
+
+	w.line("BEGIN:" + component)
+	w.line("DTSTART:" + onset.Format(localTimeFormat))
+	w.line("TZOFFSETFROM:" + formatOffset(offsetFrom))
+	w.line("TZOFFSETTO:" + formatOffset(offsetTo))
+	w.line("TZNAME:" + name)
+	w.line("END:" + component)
+}
+
+// formatOffset formats seconds east of UTC as an RFC 5545 UTC-OFFSET
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	if seconds%60 != 0 {
+		return fmt.Sprintf("%s%02d%02d%02d", sign, seconds/3600, seconds%3600/60, seconds%60)
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// escape escapes a TEXT property value
+func escape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+// writer writes content lines, folding them at 75 octets
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) line(content string) {
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		// Don't split a multi-byte UTF-8 sequence
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.buf.WriteString(content[:cut])
+		w.buf.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = 74
+	}
+	w.buf.WriteString(content)
+	w.buf.WriteString("\r\n")
+}
@@ -0,0 +1,56 @@
+// Package document renders PDF e-tickets and invoices
+package document
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// ContentType is the MIME type of the rendered documents
+const ContentType = "application/pdf"
+
+const (
+	pageMargin = 15.0 // mm
+	fontFamily = "Helvetica"
+)
+
+// newDocument creates an A4 portrait document. The core fonts only cover
+// Windows-1252, so text is passed through the returned translator.
+func newDocument(title string) (*fpdf.Fpdf, func(string) string) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("Coachella", true)
+	return pdf, pdf.UnicodeTranslatorFromDescriptor("")
+}
+
+// output finishes the document and returns its bytes
+func output(pdf *fpdf.Fpdf) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := pdf.Output(&buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// money formats an amount with two decimals and thousands separators, e.g. 1,250.00
+func money(amount float64) string {
+	formatted := strconv.FormatFloat(amount, 'f', 2, 64)
+	sign := ""
+	if strings.HasPrefix(formatted, "-") {
+		sign, formatted = "-", formatted[1:]
+	}
+	whole, fraction, _ := strings.Cut(formatted, ".")
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + grouped.String() + "." + fraction
+}
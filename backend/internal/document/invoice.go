@@ -0,0 +1,140 @@
+package document
+
+import (
+	"fmt"
+	"time"
+)
+
+// Party is the seller or buyer named on an invoice
+type Party struct {
+	Name    string
+	Address string
+	Email   string
+	TaxID   string
+}
+
+// InvoiceLine is one row of an invoice. UnitPrice includes tax.
+type InvoiceLine struct {
+	Description string
+	Quantity    int
+	UnitPrice   float64
+}
+
+// Invoice is a tax invoice for one order. Prices are tax-inclusive, so the
+// tax shown is the portion of the total attributable to TaxRate.
+type Invoice struct {
+	Number   string
+	IssuedAt time.Time
+	Seller   Party
+	Buyer    Party
+	Lines    []InvoiceLine
+	TaxRate  float64 // Percent, e.g. 11 for 11%
+	Status   string  // Payment status printed on the invoice, e.g. Paid
+}
+
+// Total returns the tax-inclusive sum of the lines
+func (inv Invoice) Total() float64 {
+	var total float64
+	for _, line := range inv.Lines {
+		total += float64(line.Quantity) * line.UnitPrice
+	}
+	return total
+}
+
+// Tax returns the tax included in the total
+func (inv Invoice) Tax() float64 {
+	total := inv.Total()
+	return total - total/(1+inv.TaxRate/100)
+}
+
+// RenderInvoice renders an invoice as a single-page PDF
+func RenderInvoice(inv Invoice) ([]byte, error) {
+	pdf, tr := newDocument("Invoice " + inv.Number)
+	pdf.AddPage()
+	pageWidth, _ := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*pageMargin
+	half := contentWidth / 2
+
+	// Heading
+	pdf.SetFont(fontFamily, "B", 20)
+	pdf.CellFormat(half, 10, tr("TAX INVOICE"), "", 0, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 11)
+	pdf.CellFormat(half, 10, tr("No. "+inv.Number), "", 1, "R", false, 0, "")
+	pdf.CellFormat(half, 6, "", "", 0, "L", false, 0, "")
+	pdf.CellFormat(half, 6, tr("Date: "+inv.IssuedAt.Format("02 January 2006")), "", 1, "R", false, 0, "")
+	if inv.Status != "" {
+		pdf.CellFormat(half, 6, "", "", 0, "L", false, 0, "")
+		pdf.CellFormat(half, 6, tr("Status: "+inv.Status), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// Seller and buyer side by side
+	top := pdf.GetY()
+	writeParty := func(x float64, heading string, party Party) float64 {
+		pdf.SetXY(x, top)
+		pdf.SetFont(fontFamily, "B", 11)
+		pdf.CellFormat(half, 6, tr(heading), "", 2, "L", false, 0, "")
+		pdf.SetFont(fontFamily, "", 10)
+		for _, line := range []string{party.Name, party.Address, party.Email} {
+			if line != "" {
+				pdf.MultiCell(half-4, 5, tr(line), "", "L", false)
+				pdf.SetX(x)
+			}
+		}
+		if party.TaxID != "" {
+			pdf.CellFormat(half, 5, tr("Tax ID: "+party.TaxID), "", 2, "L", false, 0, "")
+		}
+		return pdf.GetY()
+	}
+	sellerBottom := writeParty(pageMargin, "From", inv.Seller)
+	buyerBottom := writeParty(pageMargin+half, "Bill to", inv.Buyer)
+	pdf.SetXY(pageMargin, max(sellerBottom, buyerBottom)+8)
+
+	// Line items
+	widths := []float64{contentWidth - 90, 20, 35, 35}
+	pdf.SetFont(fontFamily, "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, heading := range []string{"Description", "Qty", "Unit price", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 8, heading, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont(fontFamily, "", 10)
+	for _, line := range inv.Lines {
+		pdf.CellFormat(widths[0], 8, tr(line.Description), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 8, fmt.Sprint(line.Quantity), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 8, money(line.UnitPrice), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 8, money(float64(line.Quantity)*line.UnitPrice), "1", 1, "R", false, 0, "")
+	}
+
+	// Totals
+	total, tax := inv.Total(), inv.Tax()
+	labelWidth := widths[0] + widths[1] + widths[2]
+	totals := [][2]string{
+		{"Subtotal (excl. tax)", money(total - tax)},
+		{fmt.Sprintf("Tax (%s%%)", trimZeros(inv.TaxRate)), money(tax)},
+		{"Total", money(total)},
+	}
+	for i, row := range totals {
+		style := ""
+		if i == len(totals)-1 {
+			style = "B"
+		}
+		pdf.SetFont(fontFamily, style, 10)
+		pdf.CellFormat(labelWidth, 8, row[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 8, row[1], "1", 1, "R", false, 0, "")
+	}
+
+	pdf.Ln(10)
+	pdf.SetFont(fontFamily, "I", 9)
+	pdf.MultiCell(contentWidth, 5, tr("Prices include tax. This invoice was generated electronically and is valid without a signature."), "", "L", false)
+	return output(pdf)
+}
+
+// trimZeros formats a rate without trailing zeros, e.g. 11 or 7.5
+func trimZeros(rate float64) string {
+	return fmt.Sprintf("%g", rate)
+}
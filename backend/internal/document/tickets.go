@@ -0,0 +1,82 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// Ticket is one admission printed on its own page
+type Ticket struct {
+	Code         string // Encoded in the QR code and scanned at the gate
+	AttendeeName string
+	TicketType   string
+	EventName    string
+	Venue        string
+	Date         string // Preformatted in the venue's time zone
+	DoorsOpen    string
+	Seat         string // Empty for general admission
+	OrderNumber  string
+}
+
+// Tickets renders one page per ticket
+func Tickets(tickets []Ticket) ([]byte, error) {
+	pdf, tr := newDocument("E-Tickets")
+	for i, ticket := range tickets {
+		png, err := qrcode.Encode(ticket.Code, qrcode.Medium, 512)
+		if err != nil {
+			return nil, err
+		}
+		imageName := fmt.Sprintf("qr-%d", i)
+		pdf.RegisterImageOptionsReader(imageName, fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+
+		pdf.AddPage()
+		pageWidth, _ := pdf.GetPageSize()
+		contentWidth := pageWidth - 2*pageMargin
+
+		// Event heading
+		pdf.SetFont(fontFamily, "B", 22)
+		pdf.MultiCell(contentWidth, 10, tr(ticket.EventName), "", "C", false)
+		pdf.SetFont(fontFamily, "", 12)
+		pdf.CellFormat(contentWidth, 7, tr(ticket.Venue), "", 1, "C", false, 0, "")
+		pdf.CellFormat(contentWidth, 7, tr(ticket.Date), "", 1, "C", false, 0, "")
+		pdf.Ln(6)
+
+		// QR code, centred
+		qrSize := 80.0
+		pdf.ImageOptions(imageName, (pageWidth-qrSize)/2, pdf.GetY(), qrSize, qrSize, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		pdf.SetY(pdf.GetY() + qrSize + 2)
+		pdf.SetFont("Courier", "", 10)
+		pdf.CellFormat(contentWidth, 6, ticket.Code, "", 1, "C", false, 0, "")
+		pdf.Ln(8)
+
+		// Admission details
+		details := [][2]string{
+			{"Attendee", ticket.AttendeeName},
+			{"Ticket type", ticket.TicketType},
+		}
+		if ticket.Seat != "" {
+			details = append(details, [2]string{"Seat", ticket.Seat})
+		}
+		if ticket.DoorsOpen != "" {
+			details = append(details, [2]string{"Doors open", ticket.DoorsOpen})
+		}
+		details = append(details,
+			[2]string{"Order", ticket.OrderNumber},
+			[2]string{"Ticket", fmt.Sprintf("%d of %d", i+1, len(tickets))},
+		)
+		for _, detail := range details {
+			pdf.SetFont(fontFamily, "B", 12)
+			pdf.CellFormat(45, 8, tr(detail[0]), "B", 0, "L", false, 0, "")
+			pdf.SetFont(fontFamily, "", 12)
+			pdf.CellFormat(contentWidth-45, 8, tr(detail[1]), "B", 1, "L", false, 0, "")
+		}
+
+		pdf.Ln(8)
+		pdf.SetFont(fontFamily, "I", 9)
+		pdf.MultiCell(contentWidth, 5, tr("Present this page, printed or on your phone, at the gate. Each QR code admits one person once. Do not share it."), "", "C", false)
+	}
+	return output(pdf)
+}
@@ -1,42 +1,90 @@
 package email
 
 import (
-	"gopkg.in/gomail.v2"
-	"log"
+	"context"
+	"fmt"
+	"io"
 	"os"
-	"strconv"
+
+	"gopkg.in/gomail.v2"
 )
 
-// SendEmail sends an email using SMTP configuration
-func SendEmail(to, subject, body string) error {
-	// Load SMTP details from environment variables
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	smtpUsername := os.Getenv("SMTP_USERNAME")
-	smtpPassword := os.Getenv("SMTP_PASSWORD")
-	emailFrom := os.Getenv("EMAIL_FROM")
-
-	// Create a new mailer
-	mailer := gomail.NewMessage()
-	mailer.SetHeader("From", emailFrom)
-	mailer.SetHeader("To", to)
-	mailer.SetHeader("Subject", subject)
-	mailer.SetBody("text/html", body)
-
-	// Convert SMTP_PORT to int
-	port, err := strconv.Atoi(smtpPort)
-	if err != nil {
-		log.Fatalf("Invalid SMTP_PORT: %v", err)
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an email ready for delivery
+type Message struct {
+	From        string // Defaults to the mailer's sender address
+	To          string
+	Subject     string
+	HTMLBody    string
+	TextBody    string            // Optional plain-text alternative
+	Headers     map[string]string // Extra headers, e.g. List-Unsubscribe
+	Attachments []Attachment
+}
+
+// Mailer delivers messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewMailerFromEnv builds the mailer selected by MAIL_TRANSPORT:
+// "smtp" (default), "file" to write .eml files to MAIL_DROP_DIR, or "memory"
+func NewMailerFromEnv() (Mailer, error) {
+	from := os.Getenv("EMAIL_FROM")
+	switch transport := os.Getenv("MAIL_TRANSPORT"); transport {
+	case "", "smtp":
+		config, err := SMTPConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewSMTPMailer(config), nil
+	case "file":
+		dir := os.Getenv("MAIL_DROP_DIR")
+		if dir == "" {
+			dir = "tmp/maildrop"
+		}
+		return NewFileMailer(dir, from)
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q", transport)
 	}
+}
 
-	// Set up the dialer
-	dialer := gomail.NewDialer(smtpHost, port, smtpUsername, smtpPassword)
+// mime renders the message as a MIME document, using from when the message has no sender
+func (m Message) mime(from string) *gomail.Message {
+	if m.From != "" {
+		from = m.From
+	}
+
+	message := gomail.NewMessage()
+	message.SetHeader("From", from)
+	message.SetHeader("To", m.To)
+	message.SetHeader("Subject", m.Subject)
+	for name, value := range m.Headers {
+		message.SetHeader(name, value)
+	}
+	if m.TextBody != "" {
+		message.SetBody("text/plain", m.TextBody)
+		message.AddAlternative("text/html", m.HTMLBody)
+	} else {
+		message.SetBody("text/html", m.HTMLBody)
+	}
 
-	// Send the email
-	if err := dialer.DialAndSend(mailer); err != nil {
-		log.Printf("Failed to send email to %s: %v\n", to, err)
-		return err
+	for _, attachment := range m.Attachments {
+		data := attachment.Data
+		message.Attach(attachment.Filename,
+			gomail.SetHeader(map[string][]string{"Content-Type": {attachment.ContentType}}),
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}),
+		)
 	}
-	log.Printf("Email sent successfully to %s\n", to)
-	return nil
+	return message
 }
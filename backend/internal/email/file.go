@@ -0,0 +1,44 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each message to an .eml file instead of sending it, for local development
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a FileMailer that drops messages into dir, creating it if needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message as <timestamp>-<random>.eml
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	file, err := os.Create(filepath.Join(m.dir, name))
+	if err != nil {
+		return err
+	}
+	if _, err := message.mime(m.from).WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,50 @@
+package email
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer records messages instead of sending them, for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+// NewMemoryMailer creates an empty MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send records the message, or returns the error set with FailWith
+func (m *MemoryMailer) Send(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// FailWith makes every following Send return err. A nil err restores normal delivery.
+func (m *MemoryMailer) FailWith(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+// Messages returns a copy of the recorded messages, oldest first
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Reset forgets every recorded message
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
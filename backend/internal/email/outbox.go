@@ -0,0 +1,256 @@
+package email
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	pollInterval   = 2 * time.Second
+	claimDuration  = 5 * time.Minute // A claim outlives any SMTP timeout, so an expired claim means the worker died
+	initialBackoff = 30 * time.Second
+	maxBackoff     = time.Hour
+	sendTimeout    = 2 * time.Minute
+)
+
+// Outbox accepts emails for delivery. Handlers and jobs queue through the
+// package functions, which use the outbox set with UseOutbox.
+type Outbox interface {
+	// EnqueueAt accepts an email to be delivered no earlier than at
+	EnqueueAt(email Message, at time.Time) error
+}
+
+var (
+	outboxMu sync.RWMutex
+	outbox   Outbox = NewDBOutbox()
+)
+
+// UseOutbox sets the outbox Enqueue and EnqueueAt deliver through
+func UseOutbox(o Outbox) {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	outbox = o
+}
+
+func currentOutbox() Outbox {
+	outboxMu.RLock()
+	defer outboxMu.RUnlock()
+	return outbox
+}
+
+// Enqueue hands an email to the outbox for delivery straight away
+func Enqueue(email Message) error {
+	return EnqueueAt(email, time.Now())
+}
+
+// EnqueueAt hands an email to the outbox to be delivered no earlier than at
+func EnqueueAt(email Message, at time.Time) error {
+	return currentOutbox().EnqueueAt(email, at)
+}
+
+// DBOutbox stores emails in the email_messages table, from which the worker
+// pool delivers them with retries
+type DBOutbox struct{}
+
+// NewDBOutbox creates a DBOutbox on config.DB
+func NewDBOutbox() *DBOutbox {
+	return &DBOutbox{}
+}
+
+// EnqueueAt stores an email to be delivered no earlier than at
+func (o *DBOutbox) EnqueueAt(email Message, at time.Time) error {
+	message := models.EmailMessage{
+		To:            email.To,
+		Subject:       email.Subject,
+		HTMLBody:      email.HTMLBody,
+		TextBody:      email.TextBody,
+		Status:        "Pending",
+		NextAttemptAt: at,
+	}
+	if len(email.Headers) > 0 {
+		headers, err := json.Marshal(email.Headers)
+		if err != nil {
+			return err
+		}
+		message.Headers = string(headers)
+	}
+	for _, attachment := range email.Attachments {
+		message.Attachments = append(message.Attachments, models.EmailAttachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Data:        attachment.Data,
+		})
+	}
+	return config.DB.Create(&message).Error
+}
+
+// MailerOutbox sends emails straight through a Mailer, ignoring when they
+// are due and without retries. With a MemoryMailer it lets tests see what
+// handlers and jobs send without a database.
+type MailerOutbox struct {
+	Mailer Mailer
+}
+
+// NewMailerOutbox creates a MailerOutbox sending through mailer
+func NewMailerOutbox(mailer Mailer) *MailerOutbox {
+	return &MailerOutbox{Mailer: mailer}
+}
+
+// EnqueueAt sends the email now
+func (o *MailerOutbox) EnqueueAt(email Message, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return o.Mailer.Send(ctx, email)
+}
+
+// ErrNotDead is returned by Resend for a message that has not gone Dead
+var ErrNotDead = errors.New("email is not dead")
+
+// Resend puts a Dead message back in the queue with a fresh set of attempts.
+// Messages still queued, being delivered or already sent are left alone, so
+// nobody is emailed twice.
+func Resend(emailID uint) error {
+	result := config.DB.Model(&models.EmailMessage{}).
+		Where("email_id = ? AND status = ?", emailID, "Dead").
+		Updates(map[string]interface{}{
+			"status":          "Pending",
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+			"locked_until":    nil,
+			"last_error":      "",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := config.DB.Model(&models.EmailMessage{}).Where("email_id = ?", emailID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrNotDead
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// StartWorkers starts n goroutines that deliver outbox messages through mailer
+func StartWorkers(mailer Mailer, n int) {
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				message, ok := claimNext()
+				if !ok {
+					time.Sleep(pollInterval)
+					continue
+				}
+				deliver(mailer, message)
+			}
+		}()
+	}
+	log.Printf("Started %d email outbox workers\n", n)
+}
+
+// claimNext takes the oldest due message. The conditional update means only
+// one worker, on any server instance, can claim a given message.
+func claimNext() (models.EmailMessage, bool) {
+	var message models.EmailMessage
+	now := time.Now()
+	due := "(status = 'Pending' AND next_attempt_at <= ?) OR (status = 'Sending' AND locked_until < ?)"
+	if err := config.DB.Where(due, now, now).Order("next_attempt_at").First(&message).Error; err != nil {
+		return message, false
+	}
+
+	result := config.DB.Model(&models.EmailMessage{}).
+		Where("email_id = ?", message.EmailID).
+		Where(due, now, now).
+		Updates(map[string]interface{}{"status": "Sending", "locked_until": now.Add(claimDuration)})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return message, false
+	}
+
+	config.DB.Where("email_id = ?", message.EmailID).Find(&message.Attachments)
+	return message, true
+}
+
+// deliver sends a claimed message and records the outcome
+func deliver(mailer Mailer, message models.EmailMessage) {
+	attachments := make([]Attachment, len(message.Attachments))
+	for i, attachment := range message.Attachments {
+		attachments[i] = Attachment{Filename: attachment.Filename, ContentType: attachment.ContentType, Data: attachment.Data}
+	}
+
+	var headers map[string]string
+	if message.Headers != "" {
+		json.Unmarshal([]byte(message.Headers), &headers)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	err := mailer.Send(ctx, Message{
+		To:          message.To,
+		Subject:     message.Subject,
+		HTMLBody:    message.HTMLBody,
+		TextBody:    message.TextBody,
+		Headers:     headers,
+		Attachments: attachments,
+	})
+	if err == nil {
+		config.DB.Model(&message).Updates(map[string]interface{}{
+			"status":       "Sent",
+			"attempts":     message.Attempts + 1,
+			"sent_at":      time.Now(),
+			"locked_until": nil,
+			"last_error":   "",
+		})
+		return
+	}
+
+	attempts := message.Attempts + 1
+	updates := map[string]interface{}{
+		"status":       "Pending",
+		"attempts":     attempts,
+		"locked_until": nil,
+		"last_error":   err.Error(),
+	}
+	log.Printf("Failed to send email %d to %s: %v\n", message.EmailID, message.To, err)
+	if attempts >= maxAttempts() {
+		updates["status"] = "Dead"
+		log.Printf("Email %d to %s moved to dead letters after %d attempts\n", message.EmailID, message.To, attempts)
+	} else {
+		updates["next_attempt_at"] = time.Now().Add(backoff(attempts))
+	}
+	config.DB.Model(&message).Updates(updates)
+}
+
+// backoff doubles the delay after each failed attempt, with up to 20% jitter
+func backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/5)+1))
+}
+
+// maxAttempts is the number of deliveries tried before a message goes Dead (EMAIL_MAX_ATTEMPTS, default 8)
+func maxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("EMAIL_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 8
+}
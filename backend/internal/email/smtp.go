@@ -0,0 +1,228 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SMTP connection security modes
+const (
+	SecurityStartTLS = "starttls" // Plain connection upgraded with STARTTLS, which the server must offer
+	SecurityTLS      = "tls"      // Implicit TLS, usually on port 465
+	SecurityNone     = "none"     // No encryption, for local relays only
+)
+
+const smtpTimeout = time.Minute
+
+// SMTPConfig configures an SMTPMailer
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Security string // SecurityStartTLS, SecurityTLS or SecurityNone
+	PoolSize int    // Idle connections kept open for reuse
+}
+
+// SMTPConfigFromEnv reads the SMTP_* settings. SMTP_SECURITY defaults to
+// "tls" on port 465 and "starttls" otherwise; SMTP_POOL_SIZE defaults to 4.
+func SMTPConfigFromEnv() (SMTPConfig, error) {
+	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		return SMTPConfig{}, fmt.Errorf("invalid SMTP_PORT %q: %w", os.Getenv("SMTP_PORT"), err)
+	}
+
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("EMAIL_FROM"),
+		Security: os.Getenv("SMTP_SECURITY"),
+		PoolSize: 4,
+	}
+	if config.Security == "" {
+		config.Security = SecurityStartTLS
+		if port == 465 {
+			config.Security = SecurityTLS
+		}
+	}
+	if config.Security != SecurityStartTLS && config.Security != SecurityTLS && config.Security != SecurityNone {
+		return SMTPConfig{}, fmt.Errorf("invalid SMTP_SECURITY %q", config.Security)
+	}
+	if size, err := strconv.Atoi(os.Getenv("SMTP_POOL_SIZE")); err == nil && size >= 0 {
+		config.PoolSize = size
+	}
+	return config, nil
+}
+
+// SMTPMailer delivers messages over SMTP, reusing connections between sends
+type SMTPMailer struct {
+	config SMTPConfig
+	idle   chan *smtpConn
+}
+
+type smtpConn struct {
+	conn   net.Conn
+	client *smtp.Client
+}
+
+// NewSMTPMailer creates an SMTPMailer. Connections are opened on first use.
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{config: config, idle: make(chan *smtpConn, config.PoolSize)}
+}
+
+// Send delivers a message, retrying once on a fresh connection if a pooled one has gone stale
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	var buffer bytesWriter
+	if _, err := message.mime(m.config.From).WriteTo(&buffer); err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		c, pooled, err := m.get(ctx)
+		if err != nil {
+			return err
+		}
+		err = m.deliver(ctx, c, message, buffer)
+		if err == nil {
+			m.put(c)
+			return nil
+		}
+		c.client.Close()
+		if !pooled || attempt > 0 {
+			return err
+		}
+	}
+}
+
+// Close closes every idle connection
+func (m *SMTPMailer) Close() {
+	for {
+		select {
+		case c := <-m.idle:
+			c.client.Quit()
+		default:
+			return
+		}
+	}
+}
+
+func (m *SMTPMailer) deliver(ctx context.Context, c *smtpConn, message Message, data bytesWriter) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	from := message.From
+	if from == "" {
+		from = m.config.From
+	}
+	if err := c.client.Mail(address(from)); err != nil {
+		return err
+	}
+	if err := c.client.Rcpt(address(message.To)); err != nil {
+		return err
+	}
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// get takes an idle connection or dials a new one. pooled reports whether it was reused.
+func (m *SMTPMailer) get(ctx context.Context) (*smtpConn, bool, error) {
+	select {
+	case c := <-m.idle:
+		return c, true, nil
+	default:
+	}
+	c, err := m.dial(ctx)
+	return c, false, err
+}
+
+// put returns a connection to the pool, closing it when the pool is full
+func (m *SMTPMailer) put(c *smtpConn) {
+	if err := c.client.Reset(); err != nil {
+		c.client.Close()
+		return
+	}
+	select {
+	case m.idle <- c:
+	default:
+		c.client.Quit()
+	}
+}
+
+func (m *SMTPMailer) dial(ctx context.Context) (*smtpConn, error) {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	tlsConfig := &tls.Config{ServerName: m.config.Host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	var err error
+	if m.config.Security == SecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if m.config.Security == SecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	if m.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return &smtpConn{conn: conn, client: client}, nil
+}
+
+// address strips any display name, since the SMTP envelope takes bare addresses
+func address(value string) string {
+	if parsed, err := mail.ParseAddress(value); err == nil {
+		return parsed.Address
+	}
+	return value
+}
+
+// bytesWriter collects the rendered message so it can be replayed on retry
+type bytesWriter []byte
+
+func (b *bytesWriter) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
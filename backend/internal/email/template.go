@@ -2,31 +2,263 @@ package email
 
 import (
 	"bytes"
-	"html/template"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
 	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"coachella-backend/templates"
+)
+
+// DefaultLocale is used when a user has no language set, or a template has no variant in their language
+const DefaultLocale = "en"
+
+// Template directory layout: layouts/ and partials/ are shared by every locale,
+// every other top-level directory is a locale. Files in a locale directory
+// starting with "_" are partials for that locale.
+const (
+	layoutsDir  = "layouts"
+	partialsDir = "partials"
+	layoutName  = "base"
 )
 
-// RenderTemplate loads an HTML template and injects dynamic data
-func RenderTemplate(templatePath string, data map[string]interface{}) (string, error) {
-	// Open the template file
-	file, err := os.Open(templatePath)
+// Rendered is a template rendered for one recipient
+type Rendered struct {
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+// Message addresses the rendered email to a recipient
+func (r Rendered) Message(to string, attachments ...Attachment) Message {
+	return Message{To: to, Subject: r.Subject, HTMLBody: r.HTMLBody, TextBody: r.TextBody, Attachments: attachments}
+}
+
+// Registry holds the parsed email templates, keyed by locale and name
+type Registry struct {
+	html map[string]map[string]*htmltemplate.Template
+	text map[string]map[string]*texttemplate.Template
+}
+
+var registry *Registry
+
+// LoadTemplates parses the embedded email templates. Files in EMAIL_TEMPLATE_DIR,
+// laid out like templates/emails, replace or add to the embedded ones.
+func LoadTemplates() error {
+	files, err := readTemplateFiles(templates.Emails, "emails")
 	if err != nil {
-		return "", err
+		return err
+	}
+	if dir := os.Getenv("EMAIL_TEMPLATE_DIR"); dir != "" {
+		overrides, err := readTemplateFiles(os.DirFS(dir), ".")
+		if err != nil {
+			return fmt.Errorf("reading EMAIL_TEMPLATE_DIR: %w", err)
+		}
+		for name, content := range overrides {
+			files[name] = content
+		}
 	}
-	defer file.Close()
 
-	// Parse the template
-	tmpl, err := template.ParseFiles(templatePath)
+	loaded, err := NewRegistry(files)
 	if err != nil {
-		return "", err
+		return err
+	}
+	registry = loaded
+	return nil
+}
+
+// Render renders a named template in the given language, falling back to the default locale
+func Render(name, language string, data map[string]interface{}) (Rendered, error) {
+	if registry == nil {
+		return Rendered{}, errors.New("email templates are not loaded")
 	}
+	return registry.Render(name, language, data)
+}
 
-	// Render the template with dynamic data
-	var rendered bytes.Buffer
-	err = tmpl.Execute(&rendered, data)
-	if err != nil {
-		return "", err
+// HasTemplate reports whether a template exists in the default locale
+func HasTemplate(name string) bool {
+	return registry != nil && registry.Has(name)
+}
+
+// SupportsLocale reports whether any template has a variant in the given language
+func SupportsLocale(language string) bool {
+	return registry != nil && len(registry.html[NormalizeLocale(language)]) > 0
+}
+
+// NewRegistry parses template files, keyed by slash-separated path relative to the template root
+func NewRegistry(files map[string]string) (*Registry, error) {
+	r := &Registry{
+		html: map[string]map[string]*htmltemplate.Template{},
+		text: map[string]map[string]*texttemplate.Template{},
+	}
+
+	// Collect the shared files first so every page is parsed on top of them
+	var sharedHTML, sharedText []string
+	for _, name := range sortedKeys(files) {
+		dir := strings.SplitN(name, "/", 2)[0]
+		if dir != layoutsDir && dir != partialsDir {
+			continue
+		}
+		switch path.Ext(name) {
+		case ".html":
+			sharedHTML = append(sharedHTML, name)
+		case ".txt":
+			sharedText = append(sharedText, name)
+		}
+	}
+
+	for _, name := range sortedKeys(files) {
+		locale, file := path.Split(name)
+		locale = strings.TrimSuffix(locale, "/")
+		if locale == "" || strings.Contains(locale, "/") || locale == layoutsDir || locale == partialsDir ||
+			strings.HasPrefix(file, "_") || path.Ext(file) != ".html" {
+			continue
+		}
+		templateName := strings.TrimSuffix(file, ".html")
+		localePartials := func(ext string) []string {
+			var names []string
+			for _, other := range sortedKeys(files) {
+				if strings.HasPrefix(other, locale+"/_") && path.Ext(other) == ext {
+					names = append(names, other)
+				}
+			}
+			return names
+		}
+
+		html := htmltemplate.New(templateName).Funcs(htmltemplate.FuncMap{"dict": dict})
+		for _, source := range slices.Concat(sharedHTML, localePartials(".html"), []string{name}) {
+			if _, err := html.New(source).Parse(files[source]); err != nil {
+				return nil, err
+			}
+		}
+
+		textFile := strings.TrimSuffix(name, ".html") + ".txt"
+		if _, ok := files[textFile]; !ok {
+			return nil, fmt.Errorf("email template %s has no plain-text part %s", name, textFile)
+		}
+		text := texttemplate.New(templateName).Funcs(texttemplate.FuncMap{"dict": dict})
+		for _, source := range slices.Concat(sharedText, localePartials(".txt"), []string{textFile}) {
+			if _, err := text.New(source).Parse(files[source]); err != nil {
+				return nil, err
+			}
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("email template %s does not define a subject", textFile)
+		}
+
+		if r.html[locale] == nil {
+			r.html[locale] = map[string]*htmltemplate.Template{}
+			r.text[locale] = map[string]*texttemplate.Template{}
+		}
+		r.html[locale][templateName] = html
+		r.text[locale][templateName] = text
+	}
+
+	if len(r.html[DefaultLocale]) == 0 {
+		return nil, fmt.Errorf("no email templates found for the default locale %q", DefaultLocale)
+	}
+	return r, nil
+}
+
+// Has reports whether a template exists in the default locale
+func (r *Registry) Has(name string) bool {
+	_, ok := r.html[DefaultLocale][name]
+	return ok
+}
+
+// Render renders a named template in the given language, e.g. "id" or "en-US",
+// falling back to the default locale when there is no variant in that language
+func (r *Registry) Render(name, language string, data map[string]interface{}) (Rendered, error) {
+	locale := NormalizeLocale(language)
+	if _, ok := r.html[locale][name]; !ok {
+		locale = DefaultLocale
+	}
+	html, ok := r.html[locale][name]
+	if !ok {
+		return Rendered{}, fmt.Errorf("email template %q does not exist", name)
+	}
+	text := r.text[locale][name]
+
+	var subject, htmlBody, textBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Rendered{}, err
+	}
+	if err := html.ExecuteTemplate(&htmlBody, layoutName, data); err != nil {
+		return Rendered{}, err
 	}
+	if err := text.ExecuteTemplate(&textBody, layoutName, data); err != nil {
+		return Rendered{}, err
+	}
+	return Rendered{
+		Subject:  strings.TrimSpace(subject.String()),
+		HTMLBody: htmlBody.String(),
+		TextBody: textBody.String(),
+	}, nil
+}
 
-	return rendered.String(), nil
+// NormalizeLocale reduces a language tag such as "id-ID" to its primary language
+func NormalizeLocale(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+	if language == "" {
+		return DefaultLocale
+	}
+	return language
+}
+
+// readTemplateFiles reads every .html and .txt file under root into a map keyed by relative path
+func readTemplateFiles(fsys fs.FS, root string) (map[string]string, error) {
+	files := map[string]string{}
+	err := fs.WalkDir(fsys, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if ext := path.Ext(name); ext != ".html" && ext != ".txt" {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		relative := name
+		if root != "." {
+			relative = strings.TrimPrefix(name, root+"/")
+		}
+		files[relative] = string(content)
+		return nil
+	})
+	return files, err
+}
+
+// dict builds a map from alternating keys and values, for passing several values to a partial
+func dict(values ...interface{}) (map[string]interface{}, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("dict needs an even number of arguments")
+	}
+	result := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, errors.New("dict keys must be strings")
+		}
+		result[key] = values[i+1]
+	}
+	return result, nil
+}
+
+func sortedKeys(files map[string]string) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
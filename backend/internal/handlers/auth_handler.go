@@ -2,27 +2,28 @@ package handlers
 
 import (
 	"coachella-backend/config"
+	"coachella-backend/internal/lockout"
 	"coachella-backend/internal/models"
+	"coachella-backend/internal/signing"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"time"
-	"os"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
 // AdminLogin allows admins to authenticate
 // @Summary Admin Login
-// @Description Authenticate an admin and issue a JWT token for accessing admin-specific routes
+// @Description Authenticate an admin and issue a JWT token for accessing admin-specific routes. Admins with two-factor authentication, or required to have it, get an mfa_required challenge to complete at /auth/mfa/verify instead. Failed logins delay further attempts, and too many lock the account for a while and email its owner.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param credentials body models.LoginRequest true "Admin credentials (email and password)"
-// @Success 200 {object} models.TokenResponse "JWT token for admin"
+// @Success 200 {object} models.TokenResponse "Access and refresh token for admin, or a models.MFAChallengeResponse"
 // @Failure 400 {object} models.GenericResponse "Invalid request body"
 // @Failure 401 {object} models.GenericResponse "Invalid email or password"
+// @Failure 429 {object} models.GenericResponse "Too many failed attempts; retry after the Retry-After header's seconds"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
 // @Router /auth/admin-login [post]
 func AdminLogin(c *gin.Context) {
 	var credentials models.LoginRequest
@@ -31,31 +32,40 @@ func AdminLogin(c *gin.Context) {
 		return
 	}
 
+	// Repeated failures on the account or from the IP address delay and then lock logins
+	if !beginLogin(c, lockout.AccountKey("admin", credentials.Email)) {
+		return
+	}
+
 	var admin models.Admin
 	if err := config.DB.Where("email = ?", credentials.Email).First(&admin).Error; err != nil {
+		loginFailed(c, "admin", credentials.Email)
 		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid email or password"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(credentials.Password)); err != nil {
+		loginFailed(c, "admin", credentials.Email)
 		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid email or password"})
 		return
 	}
 
-	token := generateJWT(admin.AdminID, admin.Email, "admin")
-	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
+	loginSucceeded(c, "admin", credentials.Email)
+	completeLogin(c, account{Type: "admin", ID: admin.AdminID, Email: admin.Email})
 }
 
 // UserLogin allows users to authenticate
 // @Summary User Login
-// @Description Authenticate a user and issue a JWT token for accessing user-specific routes
+// @Description Authenticate a user and issue a JWT token for accessing user-specific routes. Users with two-factor authentication get an mfa_required challenge to complete at /auth/mfa/verify instead. Failed logins delay further attempts, and too many lock the account for a while and email its owner.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param credentials body models.LoginRequest true "User credentials (email and password)"
-// @Success 200 {object} models.TokenResponse "JWT token for user"
+// @Success 200 {object} models.TokenResponse "Access and refresh token for user, or a models.MFAChallengeResponse"
 // @Failure 400 {object} models.GenericResponse "Invalid request body"
 // @Failure 401 {object} models.GenericResponse "Invalid email or password"
+// @Failure 429 {object} models.GenericResponse "Too many failed attempts; retry after the Retry-After header's seconds"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
 // @Router /auth/user-login [post]
 func UserLogin(c *gin.Context) {
 	var credentials models.LoginRequest
@@ -64,29 +74,36 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
+	// Repeated failures on the account or from the IP address delay and then lock logins
+	if !beginLogin(c, lockout.AccountKey("user", credentials.Email)) {
+		return
+	}
+
 	var user models.User
 	if err := config.DB.Where("email = ? AND deleted_at IS NULL", credentials.Email).First(&user).Error; err != nil {
+		loginFailed(c, "user", credentials.Email)
 		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid email or password"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
+		loginFailed(c, "user", credentials.Email)
 		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid email or password"})
 		return
 	}
 
-	token := generateJWT(user.UserID, user.Email, "user")
-	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
+	loginSucceeded(c, "user", credentials.Email)
+	completeLogin(c, account{Type: "user", ID: user.UserID, Email: user.Email})
 }
 
-// Generate a JWT for authenticated users or admins
-func generateJWT(id uint, email, userType string) string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// Generate a short-lived access JWT for a session of a user or admin
+func generateJWT(id uint, email, userType string, sessionID uint, ttl time.Duration) (string, error) {
+	return signing.Sign(signing.AudienceAccess, jwt.MapClaims{
 		"id":    id,
 		"email": email,
-		"type":  userType, // 'user' or 'admin'
-		"exp":   time.Now().Add(time.Hour * 24).Unix(),
+		"type":  userType,  // 'user' or 'admin'
+		"sid":   sessionID, // Revoking the session rejects the token
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(ttl).Unix(),
 	})
-	tokenString, _ := token.SignedString(jwtSecret)
-	return tokenString
 }
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// broadcastTypes are the notification types a broadcast may be sent as, so
+// users' preferences for them decide the channels it goes out on
+var broadcastTypes = map[string]bool{
+	notify.TypeUpdate:         true,
+	notify.TypeScheduleChange: true,
+	notify.TypeMarketing:      true,
+}
+
+// PreviewBroadcast counts the users a broadcast would reach
+// @Summary Preview a broadcast audience
+// @Description Count the users a broadcast to an event segment would reach if sent now, without sending anything
+// @Tags Broadcasts
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param broadcast body models.BroadcastRequest true "Broadcast segment"
+// @Success 200 {object} models.BroadcastPreview
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events/{id}/broadcasts/preview [post]
+func PreviewBroadcast(c *gin.Context) {
+	broadcast, ok := bindBroadcast(c)
+	if !ok {
+		return
+	}
+
+	var preview models.BroadcastPreview
+	userIDs, err := broadcastAudience(broadcast)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	preview.RecipientCount = int64(len(userIDs))
+	c.JSON(http.StatusOK, preview)
+}
+
+// CreateBroadcast schedules a broadcast to an event segment
+// @Summary Create a broadcast
+// @Description Schedule a message to a segment of an event's attendees: Paid holders, holders of a ticket type or batch, waitlisted or checked-in users. It is delivered through the notification system on each user's preferred channels, at send_at or straight away. Email, SMS and push wait for the end of a user's quiet hours unless urgent is set.
+// @Tags Broadcasts
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param broadcast body models.BroadcastRequest true "Broadcast"
+// @Success 201 {object} models.Broadcast
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events/{id}/broadcasts [post]
+func CreateBroadcast(c *gin.Context) {
+	broadcast, ok := bindBroadcast(c)
+	if !ok {
+		return
+	}
+	if broadcast.Title == "" || broadcast.Content == "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "title and content are required"})
+		return
+	}
+
+	if err := config.DB.Create(&broadcast).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, broadcast)
+}
+
+// GetEventBroadcasts lists an event's broadcasts
+// @Summary List an event's broadcasts
+// @Description Get an event's broadcasts and their progress, newest first
+// @Tags Broadcasts
+// @Security BearerAuth
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {array} models.Broadcast
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events/{id}/broadcasts [get]
+func GetEventBroadcasts(c *gin.Context) {
+	var broadcasts []models.Broadcast
+	if err := config.DB.Where("event_id = ?", c.Param("id")).Order("send_at DESC, broadcast_id DESC").Find(&broadcasts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, broadcasts)
+}
+
+// GetBroadcastByID retrieves a broadcast
+// @Summary Retrieve a broadcast
+// @Description Get a broadcast and its progress
+// @Tags Broadcasts
+// @Security BearerAuth
+// @Param id path int true "Broadcast ID"
+// @Produce json
+// @Success 200 {object} models.Broadcast
+// @Failure 404 {object} models.GenericResponse "Broadcast not found"
+// @Router /admin/broadcasts/{id} [get]
+func GetBroadcastByID(c *gin.Context) {
+	var broadcast models.Broadcast
+	if err := config.DB.First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Broadcast not found"})
+		return
+	}
+	c.JSON(http.StatusOK, broadcast)
+}
+
+// CancelBroadcast stops a broadcast
+// @Summary Cancel a broadcast
+// @Description Cancel a scheduled broadcast, or stop one that is sending. Users already notified are not affected.
+// @Tags Broadcasts
+// @Security BearerAuth
+// @Param id path int true "Broadcast ID"
+// @Produce json
+// @Success 200 {object} models.Broadcast
+// @Failure 404 {object} models.GenericResponse "Broadcast not found"
+// @Failure 409 {object} models.GenericResponse "Broadcast already finished"
+// @Router /admin/broadcasts/{id}/cancel [post]
+func CancelBroadcast(c *gin.Context) {
+	var broadcast models.Broadcast
+	if err := config.DB.First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Broadcast not found"})
+		return
+	}
+
+	result := config.DB.Model(&broadcast).
+		Where("status IN ?", []string{"Scheduled", "Sending"}).
+		Updates(map[string]interface{}{"status": "Cancelled", "completed_at": time.Now()})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Broadcast already " + broadcast.Status})
+		return
+	}
+	config.DB.First(&broadcast, broadcast.BroadcastID)
+	c.JSON(http.StatusOK, broadcast)
+}
+
+// bindBroadcast reads and validates a broadcast request for the event in the path
+func bindBroadcast(c *gin.Context) (models.Broadcast, bool) {
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return models.Broadcast{}, false
+	}
+
+	var request models.BroadcastRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return models.Broadcast{}, false
+	}
+
+	broadcast := models.Broadcast{
+		EventID:          event.EventID,
+		Segment:          request.Segment,
+		TicketID:         request.TicketID,
+		Batch:            request.Batch,
+		NotificationType: request.NotificationType,
+		Title:            request.Title,
+		Content:          request.Content,
+		Urgent:           request.Urgent,
+		SendAt:           time.Now(),
+		Status:           "Scheduled",
+	}
+	if broadcast.NotificationType == "" {
+		broadcast.NotificationType = notify.TypeUpdate
+	}
+	if request.SendAt != nil && request.SendAt.After(broadcast.SendAt) {
+		broadcast.SendAt = *request.SendAt
+	}
+
+	if message := validateBroadcast(broadcast); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return models.Broadcast{}, false
+	}
+	return broadcast, true
+}
+
+// validateBroadcast checks a broadcast's segment and type, returning a message
+// describing the first problem found or "" when it is valid
+func validateBroadcast(broadcast models.Broadcast) string {
+	if !broadcastTypes[broadcast.NotificationType] {
+		return "notification_type must be Update, ScheduleChange or Marketing"
+	}
+	if broadcast.Urgent && broadcast.NotificationType == notify.TypeMarketing {
+		return "Marketing broadcasts cannot be urgent"
+	}
+
+	switch broadcast.Segment {
+	case "Paid", "CheckedIn":
+	case "TicketType":
+		if broadcast.TicketID == nil {
+			return "ticket_id is required for the TicketType segment"
+		}
+	case "Batch":
+		if broadcast.Batch == nil {
+			return "batch is required for the Batch segment"
+		}
+	case "Waitlisted":
+	default:
+		return "segment must be Paid, TicketType, Batch, Waitlisted or CheckedIn"
+	}
+
+	if broadcast.TicketID != nil {
+		var count int64
+		config.DB.Model(&models.Ticket{}).Where("ticket_id = ? AND event_id = ?", *broadcast.TicketID, broadcast.EventID).Count(&count)
+		if count == 0 {
+			return "ticket_id is not a ticket of this event"
+		}
+	}
+	return ""
+}
+
+// broadcastAudience returns the users in a broadcast's segment
+func broadcastAudience(broadcast models.Broadcast) ([]uint, error) {
+	var query *gorm.DB
+	switch broadcast.Segment {
+	case "Waitlisted":
+		query = config.DB.Model(&models.Waitlist{}).
+			Distinct("waitlists.user_id").
+			Joins("JOIN tickets ON tickets.ticket_id = waitlists.ticket_id").
+			Where("tickets.event_id = ?", broadcast.EventID)
+		if broadcast.TicketID != nil {
+			query = query.Where("waitlists.ticket_id = ?", *broadcast.TicketID)
+		}
+		var userIDs []uint
+		err := query.Pluck("waitlists.user_id", &userIDs).Error
+		return userIDs, err
+	case "CheckedIn":
+		query = config.DB.Model(&models.Transaction{}).
+			Joins("JOIN issued_tickets ON issued_tickets.transaction_id = transactions.transaction_id").
+			Where("issued_tickets.checked_in_at IS NOT NULL")
+	default:
+		query = config.DB.Model(&models.Transaction{})
+	}
+
+	query = query.
+		Distinct("transactions.user_id").
+		Joins("JOIN tickets ON tickets.ticket_id = transactions.ticket_id").
+		Where("tickets.event_id = ? AND transactions.payment_status = ?", broadcast.EventID, "Paid")
+	if broadcast.Segment == "TicketType" {
+		query = query.Where("tickets.ticket_id = ?", *broadcast.TicketID)
+	}
+	if broadcast.Segment == "Batch" {
+		query = query.Where("tickets.batch = ?", *broadcast.Batch)
+	}
+
+	var userIDs []uint
+	err := query.Pluck("transactions.user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// broadcastMaxAttempts is how many times notifying a recipient is tried
+// before they are given up on, so a permanent failure doesn't keep the
+// broadcast Sending forever
+const broadcastMaxAttempts = 5
+
+// broadcastRatePerMinute is how many users broadcasts notify per minute in
+// total, to protect the mail relay (BROADCAST_RATE_PER_MINUTE, default 300)
+func broadcastRatePerMinute() int {
+	rate, err := strconv.Atoi(envOrDefault("BROADCAST_RATE_PER_MINUTE", "300"))
+	if err != nil || rate < 1 {
+		return 300
+	}
+	return rate
+}
+
+// SendBroadcasts starts broadcasts that have come due and notifies the next
+// users of those sending, up to the per-minute rate. It is run every minute,
+// so a large broadcast goes out over several runs.
+func SendBroadcasts() {
+	startDueBroadcasts()
+
+	var broadcasts []models.Broadcast
+	if err := config.DB.Preload("Event").Where("status = ?", "Sending").Order("send_at, broadcast_id").Find(&broadcasts).Error; err != nil {
+		log.Printf("Error fetching sending broadcasts: %v\n", err)
+		return
+	}
+
+	budget := broadcastRatePerMinute()
+	for _, broadcast := range broadcasts {
+		if budget == 0 {
+			return
+		}
+		budget -= sendBroadcastBatch(broadcast, budget)
+	}
+}
+
+// startDueBroadcasts fixes the audience of each due broadcast and marks it Sending
+func startDueBroadcasts() {
+	var due []models.Broadcast
+	if err := config.DB.Where("status = ? AND send_at <= ?", "Scheduled", time.Now()).Find(&due).Error; err != nil {
+		log.Printf("Error fetching due broadcasts: %v\n", err)
+		return
+	}
+
+	for _, broadcast := range due {
+		userIDs, err := broadcastAudience(broadcast)
+		if err != nil {
+			log.Printf("Error resolving audience of broadcast %d: %v\n", broadcast.BroadcastID, err)
+			continue
+		}
+
+		err = config.DB.Transaction(func(tx *gorm.DB) error {
+			// Claim the broadcast so a concurrent run doesn't start it too
+			result := tx.Model(&broadcast).Where("status = ?", "Scheduled").
+				Updates(map[string]interface{}{"status": "Sending", "recipient_count": len(userIDs)})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			recipients := make([]models.BroadcastRecipient, 0, len(userIDs))
+			for _, userID := range userIDs {
+				recipients = append(recipients, models.BroadcastRecipient{BroadcastID: broadcast.BroadcastID, UserID: userID})
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(recipients, 500).Error
+		})
+		if err != nil {
+			log.Printf("Failed to start broadcast %d: %v\n", broadcast.BroadcastID, err)
+		}
+	}
+}
+
+// sendBroadcastBatch notifies up to limit of a broadcast's remaining users and
+// returns how many it tried. A broadcast with nobody left to notify or give up
+// on is marked Sent.
+func sendBroadcastBatch(broadcast models.Broadcast, limit int) int {
+	var recipients []models.BroadcastRecipient
+	err := config.DB.Where("broadcast_id = ? AND sent_at IS NULL AND failed_at IS NULL", broadcast.BroadcastID).
+		Order("broadcast_recipient_id").Limit(limit).Find(&recipients).Error
+	if err != nil {
+		log.Printf("Error fetching recipients of broadcast %d: %v\n", broadcast.BroadcastID, err)
+		return 0
+	}
+
+	if len(recipients) == 0 {
+		config.DB.Model(&broadcast).Where("status = ?", "Sending").
+			Updates(map[string]interface{}{"status": "Sent", "completed_at": time.Now()})
+		return 0
+	}
+
+	sent, failed := 0, 0
+	for _, recipient := range recipients {
+		// Claim the recipient first so a concurrent run never notifies them twice
+		result := config.DB.Model(&recipient).Where("sent_at IS NULL").Update("sent_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		err := notify.Send(notify.Message{
+			UserID:  recipient.UserID,
+			Type:    broadcast.NotificationType,
+			Title:   broadcast.Title,
+			Content: broadcast.Content,
+			Urgent:  broadcast.Urgent,
+			Email: &notify.Email{
+				Template: "broadcast",
+				Data: map[string]interface{}{
+					"title":      broadcast.Title,
+					"content":    broadcast.Content,
+					"event_name": broadcast.Event.Name,
+				},
+			},
+		})
+		if err != nil {
+			// Give the claim back so a later run retries the recipient, until
+			// they have failed too often
+			log.Printf("Failed to notify user %d of broadcast %d: %v\n", recipient.UserID, broadcast.BroadcastID, err)
+			attempts := recipient.Attempts + 1
+			updates := map[string]interface{}{"sent_at": nil, "attempts": attempts}
+			if attempts >= broadcastMaxAttempts {
+				updates["failed_at"] = time.Now()
+				failed++
+				log.Printf("Gave up notifying user %d of broadcast %d after %d attempts\n", recipient.UserID, broadcast.BroadcastID, attempts)
+			}
+			config.DB.Model(&recipient).Updates(updates)
+			continue
+		}
+		sent++
+	}
+
+	config.DB.Model(&broadcast).UpdateColumns(map[string]interface{}{
+		"sent_count":   gorm.Expr("sent_count + ?", sent),
+		"failed_count": gorm.Expr("failed_count + ?", failed),
+	})
+	return len(recipients)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/testdb"
+	"testing"
+	"time"
+)
+
+func TestSendBroadcastsGivesUpOnPermanentFailures(t *testing.T) {
+	testdb.Use(t)
+	useTestMailer(t)
+
+	event := models.Event{Name: "Coachella Weekend 1", Timezone: "America/Los_Angeles"}
+	testdb.Create(t, &event)
+	ticket := models.Ticket{EventID: event.EventID, Batch: 1, Type: "GA", Price: 499}
+	ana := models.User{Name: "Ana", Email: "ana@example.com", Password: "x"}
+	budi := models.User{Name: "Budi", Email: "budi@example.com", Password: "x"}
+	testdb.Create(t, &ticket, &ana, &budi)
+	for _, user := range []models.User{ana, budi} {
+		testdb.Create(t, &models.Transaction{UserID: user.UserID, TicketID: ticket.TicketID, Quantity: 1, PaymentStatus: "Paid", Timeout: time.Now()})
+	}
+	broadcast := models.Broadcast{EventID: event.EventID, Segment: "Paid", NotificationType: "Update", Title: "Gates open late", Content: "Gates open at 13:00.", SendAt: time.Now().Add(-time.Minute)}
+	testdb.Create(t, &broadcast)
+
+	// Budi's account goes away after the audience is fixed, so notifying him
+	// fails on every run
+	startDueBroadcasts()
+	config.DB.Delete(&budi)
+
+	for run := 1; run <= broadcastMaxAttempts; run++ {
+		SendBroadcasts()
+		config.DB.First(&broadcast, broadcast.BroadcastID)
+		if broadcast.Status != "Sending" {
+			t.Fatalf("run %d: status = %s, want Sending while Budi has attempts left", run, broadcast.Status)
+		}
+	}
+	var recipient models.BroadcastRecipient
+	config.DB.Where("user_id = ?", budi.UserID).First(&recipient)
+	if recipient.Attempts != broadcastMaxAttempts || recipient.FailedAt == nil || recipient.SentAt != nil {
+		t.Errorf("Budi's recipient = %+v, want given up after %d attempts", recipient, broadcastMaxAttempts)
+	}
+
+	SendBroadcasts()
+	config.DB.First(&broadcast, broadcast.BroadcastID)
+	if broadcast.Status != "Sent" || broadcast.CompletedAt == nil {
+		t.Errorf("status = %s, want Sent once only given-up recipients are left", broadcast.Status)
+	}
+	if broadcast.SentCount != 1 || broadcast.FailedCount != 1 {
+		t.Errorf("sent %d and failed %d, want 1 each", broadcast.SentCount, broadcast.FailedCount)
+	}
+}
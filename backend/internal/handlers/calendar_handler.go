@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/calendar"
+	"coachella-backend/internal/models"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEventCalendar downloads an event as an iCalendar file
+// @Summary Download event calendar
+// @Description Get an RFC 5545 iCalendar (.ics) file for the event
+// @Tags Calendar
+// @Param id path int true "Event ID"
+// @Produce text/calendar
+// @Success 200 {file} file "iCalendar file"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Router /events/{id}/calendar.ics [get]
+func GetEventCalendar(c *gin.Context) {
+	event, ok := findPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	cal := calendar.Calendar{Name: event.Name, Location: event.Location(), Events: []calendar.Event{eventCalendarEntry(event)}}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=event-%d.ics", event.EventID))
+	c.Data(http.StatusOK, calendar.ContentType, cal.Bytes())
+}
+
+// GetCalendarFeedURL returns the authenticated user's calendar subscription URL
+// @Summary Retrieve calendar feed URL
+// @Description Get the secret URL calendar apps can subscribe to for the events the user holds tickets for
+// @Tags Calendar
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.CalendarFeedResponse
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/calendar-feed [get]
+func GetCalendarFeedURL(c *gin.Context) {
+	calendarFeedURL(c, false)
+}
+
+// ResetCalendarFeedURL replaces the authenticated user's calendar subscription URL
+// @Summary Reset calendar feed URL
+// @Description Issue a new calendar subscription URL. The previous URL stops working.
+// @Tags Calendar
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.CalendarFeedResponse
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/calendar-feed/reset [post]
+func ResetCalendarFeedURL(c *gin.Context) {
+	calendarFeedURL(c, true)
+}
+
+// GetCalendarFeed serves a user's calendar subscription feed
+// @Summary Calendar subscription feed
+// @Description iCalendar feed of the events the feed owner holds Paid tickets for. The token in the URL authenticates the request.
+// @Tags Calendar
+// @Param token path string true "Feed token"
+// @Produce text/calendar
+// @Success 200 {file} file "iCalendar feed"
+// @Failure 404 {object} models.GenericResponse "Feed not found"
+// @Router /calendar/{token}/events.ics [get]
+func GetCalendarFeed(c *gin.Context) {
+	var user models.User
+	if err := config.DB.Where("calendar_token = ?", c.Param("token")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Feed not found"})
+		return
+	}
+
+	var events []models.Event
+	err := config.DB.
+		Where("event_id IN (?)", config.DB.Model(&models.Transaction{}).
+			Select("tickets.event_id").
+			Joins("JOIN tickets ON tickets.ticket_id = transactions.ticket_id").
+			Where("transactions.user_id = ? AND transactions.payment_status = ?", user.UserID, "Paid")).
+		Order("start_date").
+		Find(&events).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	cal := calendar.Calendar{Name: "My Coachella Events"}
+	for _, event := range events {
+		cal.Events = append(cal.Events, eventCalendarEntry(event))
+	}
+	c.Data(http.StatusOK, calendar.ContentType, cal.Bytes())
+}
+
+// calendarFeedURL responds with the user's feed URL, issuing a new token when
+// the user has none or reset is set
+func calendarFeedURL(c *gin.Context, reset bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "User not found"})
+		return
+	}
+
+	if reset || user.CalendarToken == nil {
+		token, err := randomToken(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to generate feed token"})
+			return
+		}
+		if err := config.DB.Model(&user).Update("calendar_token", token).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+			return
+		}
+		user.CalendarToken = &token
+	}
+
+	c.JSON(http.StatusOK, models.CalendarFeedResponse{URL: config.BaseURL() + "/calendar/" + *user.CalendarToken + "/events.ics"})
+}
+
+// eventCalendarEntry converts an event into a calendar entry. Events with doors
+// and curfew times are timed in the venue's zone, others span whole days.
+func eventCalendarEntry(event models.Event) calendar.Event {
+	entry := calendar.Event{
+		UID:         fmt.Sprintf("event-%d@coachella.com", event.EventID),
+		Summary:     event.Name,
+		Description: event.Description,
+		Place:       event.Venue(),
+		URL:         fmt.Sprintf("%s/events/%d", config.BaseURL(), event.EventID),
+		Location:    event.Location(),
+	}
+	if event.DoorsOpenAt != nil && event.CurfewAt != nil {
+		entry.Start, entry.End = *event.DoorsOpenAt, *event.CurfewAt
+		return entry
+	}
+
+	entry.AllDay = true
+	entry.Start = event.StartDate.Time
+	entry.End = event.EndDate.AddDate(0, 0, 1) // DATE end values are exclusive
+	return entry
+}
+
+// eventCalendarAttachment renders an event as an .ics email attachment
+func eventCalendarAttachment(event models.Event) []byte {
+	cal := calendar.Calendar{Name: event.Name, Location: event.Location(), Events: []calendar.Event{eventCalendarEntry(event)}}
+	return cal.Bytes()
+}
+
+// randomToken returns n random bytes, hex encoded
+func randomToken(n int) (string, error) {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckInTicket admits the holder of an issued ticket at the gate
+// @Summary Check in a ticket
+// @Description Scan an issued ticket's QR code at the gate of an event. A ticket admits once, only to its own event, only while its purchase is Paid, and only from doors to curfew on one of the event's days at the venue.
+// @Tags Check-in
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param scan body models.CheckInRequest true "Scanned code"
+// @Success 200 {object} models.IssuedTicket
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 403 {object} models.GenericResponse "Purchase is not paid, ticket is for another event, or gates are closed"
+// @Failure 404 {object} models.GenericResponse "Ticket not found"
+// @Failure 409 {object} models.GenericResponse "Already checked in"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/checkin [post]
+func CheckInTicket(c *gin.Context) {
+	var request models.CheckInRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	var issued models.IssuedTicket
+	if err := config.DB.Preload("Transaction").Preload("Ticket.Event").Where("code = ?", request.Code).First(&issued).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Ticket not found"})
+		return
+	}
+	if issued.Ticket.EventID != request.EventID {
+		c.JSON(http.StatusForbidden, models.GenericResponse{Error: "Ticket is for another event"})
+		return
+	}
+	if !issued.Ticket.Event.GatesOpen(time.Now()) {
+		c.JSON(http.StatusForbidden, models.GenericResponse{Error: "Gates are closed for this event"})
+		return
+	}
+	if issued.Transaction.PaymentStatus != "Paid" {
+		c.JSON(http.StatusForbidden, models.GenericResponse{Error: "Purchase is " + issued.Transaction.PaymentStatus})
+		return
+	}
+
+	// Only the first of two simultaneous scans finds the ticket not yet checked in
+	now := time.Now()
+	result := config.DB.Model(&models.IssuedTicket{}).
+		Where("issued_ticket_id = ? AND checked_in_at IS NULL", issued.IssuedTicketID).
+		Update("checked_in_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		config.DB.First(&issued, issued.IssuedTicketID)
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Already checked in at " + issued.CheckedInAt.Format(time.RFC3339)})
+		return
+	}
+
+	issued.CheckedInAt = &now
+	c.JSON(http.StatusOK, issued)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"coachella-backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUserID returns the account ID of the principal set by AuthMiddleware
+func currentUserID(c *gin.Context) (uint, bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	return principal.ID, ok
+}
+
+// currentAccount returns the account type and ID of the principal set by AuthMiddleware
+func currentAccount(c *gin.Context) (string, uint, bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	return principal.AccountType, principal.ID, ok
+}
+
+// currentSessionID returns the session ID of the principal set by AuthMiddleware
+func currentSessionID(c *gin.Context) (uint, bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	return principal.SessionID, ok
+}
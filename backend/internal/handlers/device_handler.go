@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"coachella-backend/internal/sms"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// UpdatePhone sets the current user's phone number for SMS notifications
+// @Summary Set phone number
+// @Description Set the number SMS notifications are sent to, in E.164 format
+// @Tags Notifications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param phone body models.PhoneRequest true "Phone number"
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/phone [put]
+func UpdatePhone(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var request models.PhoneRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+	if !sms.ValidPhone(request.Phone) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "phone must be in E.164 format, e.g. +6281234567890"})
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("user_id = ?", userID).Update("phone", request.Phone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Phone number updated"})
+}
+
+// DeletePhone removes the current user's phone number
+// @Summary Remove phone number
+// @Description Remove the number SMS notifications are sent to
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/phone [delete]
+func DeletePhone(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("user_id = ?", userID).Update("phone", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Phone number removed"})
+}
+
+// GetDevices lists the current user's push devices
+// @Summary List push devices
+// @Description Get the devices registered for the logged-in user's push notifications
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.DeviceToken
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/devices [get]
+func GetDevices(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var devices []models.DeviceToken
+	if err := config.DB.Where("user_id = ?", userID).Order("device_token_id").Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, devices)
+}
+
+// RegisterDevice registers a device for the current user's push notifications
+// @Summary Register a push device
+// @Description Register a device token from FCM. A token already registered to another account moves to this one, since a device has one signed-in user.
+// @Tags Notifications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param device body models.DeviceTokenRequest true "Device token and platform (android, ios or web)"
+// @Success 201 {object} models.DeviceToken
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/devices [post]
+func RegisterDevice(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var request models.DeviceTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+	if request.Platform != "android" && request.Platform != "ios" && request.Platform != "web" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "platform must be android, ios or web"})
+		return
+	}
+
+	device := models.DeviceToken{UserID: userID, Token: request.Token, Platform: request.Platform}
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "updated_at"}),
+	}).Create(&device).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	config.DB.Where("token = ?", request.Token).First(&device)
+	c.JSON(http.StatusCreated, device)
+}
+
+// DeleteDevice unregisters one of the current user's push devices
+// @Summary Unregister a push device
+// @Description Stop sending push notifications to a device, e.g. on sign-out
+// @Tags Notifications
+// @Security BearerAuth
+// @Param id path int true "Device token ID"
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 404 {object} models.GenericResponse "Device not found"
+// @Router /user/devices/{id} [delete]
+func DeleteDevice(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	result := config.DB.Where("user_id = ?", userID).Delete(&models.DeviceToken{}, c.Param("id"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Device not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Device unregistered"})
+}
+
+// GetNotificationDeliveries lists the SMS and push receipts of a notification
+// @Summary Retrieve delivery receipts
+// @Description Get the SMS and push deliveries of one of the logged-in user's notifications and their status (Pending, Sending, Sent, Delivered or Failed)
+// @Tags Notifications
+// @Security BearerAuth
+// @Param id path int true "Notification ID"
+// @Produce json
+// @Success 200 {array} models.NotificationDelivery
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 404 {object} models.GenericResponse "Notification not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /notifications/{id}/deliveries [get]
+func GetNotificationDeliveries(c *gin.Context) {
+	notification, ok := findUserNotification(c)
+	if !ok {
+		return
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := config.DB.Where("notification_id = ?", notification.NotificationID).Order("delivery_id").Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// TwilioStatusCallback records SMS delivery status updates from Twilio
+// @Summary Twilio status callback
+// @Description Receives Twilio message status callbacks, verified with the X-Twilio-Signature header, and updates the matching delivery receipt
+// @Tags Notifications
+// @Accept x-www-form-urlencoded
+// @Param X-Twilio-Signature header string true "Request signature"
+// @Success 204
+// @Failure 403 {object} models.GenericResponse "Invalid signature"
+// @Router /webhooks/twilio/status [post]
+func TwilioStatusCallback(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid form"})
+		return
+	}
+	callbackURL := os.Getenv("TWILIO_STATUS_CALLBACK_URL")
+	if !sms.VerifyTwilioSignature(os.Getenv("TWILIO_AUTH_TOKEN"), callbackURL, c.Request.PostForm, c.GetHeader("X-Twilio-Signature")) {
+		c.JSON(http.StatusForbidden, models.GenericResponse{Error: "Invalid signature"})
+		return
+	}
+
+	sid := c.Request.PostForm.Get("MessageSid")
+	switch status := c.Request.PostForm.Get("MessageStatus"); status {
+	case "delivered":
+		notify.RecordDeliveryStatus("twilio", sid, true, "")
+	case "failed", "undelivered":
+		notify.RecordDeliveryStatus("twilio", sid, false, "twilio: "+status+" "+c.Request.PostForm.Get("ErrorCode"))
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/document"
+	"coachella-backend/internal/models"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetTransactionTicketsPDF downloads the e-tickets of a purchase
+// @Summary Download e-tickets
+// @Description Download a PDF with one page per ticket in the purchase, each with its QR code for entry. Tickets are issued once the purchase is paid.
+// @Tags Transactions
+// @Security BearerAuth
+// @Param id path int true "Transaction ID"
+// @Produce application/pdf
+// @Success 200 {file} file
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 404 {object} models.GenericResponse "Transaction not found"
+// @Failure 409 {object} models.GenericResponse "Transaction is not paid"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/transactions/{id}/tickets.pdf [get]
+func GetTransactionTicketsPDF(c *gin.Context) {
+	transaction, ok := findPaidTransaction(c)
+	if !ok {
+		return
+	}
+
+	pdf, err := transactionTicketsPDF(transaction.TransactionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to render tickets"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="tickets-%d.pdf"`, transaction.TransactionID))
+	c.Data(http.StatusOK, document.ContentType, pdf)
+}
+
+// GetTransactionInvoicePDF downloads the tax invoice of a purchase
+// @Summary Download invoice
+// @Description Download the tax invoice for a purchase as a PDF. The invoice is issued once the purchase is paid.
+// @Tags Transactions
+// @Security BearerAuth
+// @Param id path int true "Transaction ID"
+// @Produce application/pdf
+// @Success 200 {file} file
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 404 {object} models.GenericResponse "Transaction not found"
+// @Failure 409 {object} models.GenericResponse "Transaction is not paid"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/transactions/{id}/invoice.pdf [get]
+func GetTransactionInvoicePDF(c *gin.Context) {
+	transaction, ok := findPaidTransaction(c)
+	if !ok {
+		return
+	}
+
+	pdf, err := transactionInvoicePDF(transaction.TransactionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to render invoice"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, invoiceNumber(transaction)))
+	c.Data(http.StatusOK, document.ContentType, pdf)
+}
+
+// findPaidTransaction loads the caller's transaction named by the id path
+// parameter, responding 409 itself when it has not been paid
+func findPaidTransaction(c *gin.Context) (models.Transaction, bool) {
+	transaction, ok := findUserTransaction(c)
+	if !ok {
+		return transaction, false
+	}
+	if transaction.PaymentStatus != "Paid" {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Transaction is not paid"})
+		return transaction, false
+	}
+	return transaction, true
+}
+
+// issueTickets creates one IssuedTicket per ticket bought, assigning bound seats in order
+func issueTickets(tx *gorm.DB, transaction models.Transaction, buyerName string) error {
+	issued := make([]models.IssuedTicket, transaction.Quantity)
+	for i := range issued {
+		code, err := randomToken(16)
+		if err != nil {
+			return err
+		}
+		issued[i] = models.IssuedTicket{
+			TransactionID: transaction.TransactionID,
+			TicketID:      transaction.TicketID,
+			Code:          code,
+			AttendeeName:  buyerName,
+		}
+		if i < len(transaction.AttendeeNames) && transaction.AttendeeNames[i] != "" {
+			issued[i].AttendeeName = transaction.AttendeeNames[i]
+		}
+		if i < len(transaction.SeatIDs) {
+			issued[i].SeatID = &transaction.SeatIDs[i]
+		}
+	}
+	return tx.Create(&issued).Error
+}
+
+// transactionTicketsPDF renders the issued tickets of a transaction
+func transactionTicketsPDF(transactionID uint) ([]byte, error) {
+	var issued []models.IssuedTicket
+	err := config.DB.Preload("Ticket.Event").Preload("Seat.Section").
+		Where("transaction_id = ?", transactionID).Order("issued_ticket_id").Find(&issued).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(issued) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	tickets := make([]document.Ticket, len(issued))
+	for i, entry := range issued {
+		event := entry.Ticket.Event
+		ticket := document.Ticket{
+			Code:         entry.Code,
+			AttendeeName: entry.AttendeeName,
+			TicketType:   entry.Ticket.Type,
+			EventName:    event.Name,
+			Venue:        event.Venue(),
+			Date:         eventDateRange(event),
+			OrderNumber:  fmt.Sprintf("#%d", transactionID),
+		}
+		if event.DoorsOpenAt != nil {
+			ticket.DoorsOpen = event.DoorsOpenAt.Format("Mon 02 Jan 2006, 15:04 MST")
+		}
+		if entry.Seat != nil {
+			ticket.Seat = fmt.Sprintf("%s, row %s, seat %d", entry.Seat.Section.Name, entry.Seat.Row, entry.Seat.Number)
+		}
+		tickets[i] = ticket
+	}
+	return document.Tickets(tickets)
+}
+
+// transactionInvoicePDF renders the tax invoice of a transaction
+func transactionInvoicePDF(transactionID uint) ([]byte, error) {
+	var transaction models.Transaction
+	if err := config.DB.Preload("User").Preload("Ticket.Event").First(&transaction, transactionID).Error; err != nil {
+		return nil, err
+	}
+
+	// The invoice is issued on payment
+	if transaction.PaymentStatus != "Paid" {
+		return nil, errNotPaid
+	}
+
+	// Purchases from before unit prices were recorded are invoiced at the ticket's price
+	unitPrice := transaction.UnitPrice
+	if unitPrice == 0 {
+		unitPrice = transaction.Ticket.Price
+	}
+	// Purchases from before payment times were recorded are dated at purchase
+	issuedAt := transaction.CreatedAt
+	if transaction.PaidAt != nil {
+		issuedAt = *transaction.PaidAt
+	}
+	return document.RenderInvoice(document.Invoice{
+		Number:   invoiceNumber(transaction),
+		IssuedAt: issuedAt,
+		Seller: document.Party{
+			Name:    envOrDefault("INVOICE_SELLER_NAME", "Coachella"),
+			Address: os.Getenv("INVOICE_SELLER_ADDRESS"),
+			TaxID:   os.Getenv("INVOICE_SELLER_TAX_ID"),
+		},
+		Buyer: document.Party{Name: transaction.User.Name, Email: transaction.User.Email},
+		Lines: []document.InvoiceLine{{
+			Description: fmt.Sprintf("%s - %s ticket", transaction.Ticket.Event.Name, transaction.Ticket.Type),
+			Quantity:    transaction.Quantity,
+			UnitPrice:   unitPrice,
+		}},
+		TaxRate: invoiceTaxRate(),
+		Status:  transaction.PaymentStatus,
+	})
+}
+
+// invoiceNumber identifies a transaction's invoice, e.g. INV-2025-000042
+func invoiceNumber(transaction models.Transaction) string {
+	return fmt.Sprintf("INV-%d-%06d", transaction.CreatedAt.Year(), transaction.TransactionID)
+}
+
+// invoiceTaxRate is the tax percentage included in ticket prices (INVOICE_TAX_RATE, default 0)
+func invoiceTaxRate() float64 {
+	rate, err := strconv.ParseFloat(os.Getenv("INVOICE_TAX_RATE"), 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// eventDateRange formats an event's dates in its venue's zone, e.g. "11 Apr 2025 - 13 Apr 2025"
+func eventDateRange(event models.Event) string {
+	start, end := event.StartDate.Time, event.EndDate.Time
+	if event.DoorsOpenAt != nil {
+		start = *event.DoorsOpenAt
+	}
+	if event.CurfewAt != nil {
+		end = *event.CurfewAt
+	}
+	if end.IsZero() || start.Format(time.DateOnly) == end.Format(time.DateOnly) {
+		return start.Format("02 Jan 2006")
+	}
+	return start.Format("02 Jan 2006") + " - " + end.Format("02 Jan 2006")
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
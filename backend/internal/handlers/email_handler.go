@@ -1,23 +1,116 @@
 package handlers
 
 import (
+	"coachella-backend/config"
 	"coachella-backend/internal/email"
+	"coachella-backend/internal/models"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
 	"net/http"
+	"strconv"
 )
 
-// SendTestEmail is a handler to test email functionality
-func SendTestEmail(c *gin.Context) {
-	// Replace with the recipient email, subject, and body content
-	err := email.SendEmail(
-		"ggzane23@gmail.com", // Replace with actual recipient
-		"Test Email from Coachella",
-		"<h1>Welcome!</h1><p>This is a test email from the Coachella system.</p>",
-	)
+// SendTestEmail returns a handler that sends a test email straight through mailer,
+// bypassing the outbox so transport problems surface at once. The transport's
+// error is logged rather than returned, since it can name internal hosts.
+func SendTestEmail(mailer email.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Replace with the recipient email, subject, and body content
+		err := mailer.Send(c.Request.Context(), email.Message{
+			To:       "ggzane23@gmail.com", // Replace with actual recipient
+			Subject:  "Test Email from Coachella",
+			HTMLBody: "<h1>Welcome!</h1><p>This is a test email from the Coachella system.</p>",
+		})
+		if err != nil {
+			log.Printf("Failed to send test email: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Test email sent successfully!"})
+	}
+}
+
+// GetEmails retrieves outbox messages
+// @Summary Retrieve outbox emails
+// @Description Get outbox messages, newest first, optionally filtered by status (Pending, Sending, Sent or Dead)
+// @Tags Emails
+// @Security BearerAuth
+// @Param status query string false "Message status"
+// @Param limit query int false "Maximum number of messages (default 100)"
+// @Produce json
+// @Success 200 {array} models.EmailMessage
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/emails [get]
+func GetEmails(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := config.DB.Omit("html_body", "text_body").Order("email_id DESC").Limit(limit)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var messages []models.EmailMessage
+	if err := query.Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// GetEmailByID retrieves a single outbox message
+// @Summary Retrieve an outbox email
+// @Description Get an outbox message including its body, attachments and last delivery error
+// @Tags Emails
+// @Security BearerAuth
+// @Param id path int true "Email ID"
+// @Produce json
+// @Success 200 {object} models.EmailMessage
+// @Failure 404 {object} models.GenericResponse "Email not found"
+// @Router /admin/emails/{id} [get]
+func GetEmailByID(c *gin.Context) {
+	var message models.EmailMessage
+	if err := config.DB.Preload("Attachments").First(&message, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Email not found"})
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// ResendEmail queues an outbox message for delivery again
+// @Summary Re-send an outbox email
+// @Description Put a dead message back in the queue with a fresh set of attempts
+// @Tags Emails
+// @Security BearerAuth
+// @Param id path int true "Email ID"
+// @Success 200 {object} models.GenericResponse "Email queued"
+// @Failure 404 {object} models.GenericResponse "Email not found"
+// @Failure 409 {object} models.GenericResponse "Email is not dead"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/emails/{id}/resend [post]
+func ResendEmail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Email not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Test email sent successfully!"})
+	if err := email.Resend(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Email not found"})
+			return
+		}
+		if errors.Is(err, email.ErrNotDead) {
+			c.JSON(http.StatusConflict, models.GenericResponse{Error: "Only dead emails can be resent"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Email queued"})
 }
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/email"
+	"coachella-backend/internal/middleware"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/rbac"
+	"coachella-backend/internal/testdb"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSendTestEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		sendErr  error
+		wantCode int
+		wantSent int
+	}{
+		{name: "delivered", wantCode: http.StatusOK, wantSent: 1},
+		{name: "transport fails", sendErr: errors.New("dial tcp: connection refused"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := email.NewMemoryMailer()
+			mailer.FailWith(tt.sendErr)
+			router := gin.New()
+			router.GET("/test-email", SendTestEmail(mailer))
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test-email", nil))
+
+			if recorder.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantCode)
+			}
+			if strings.Contains(recorder.Body.String(), "connection refused") {
+				t.Errorf("response leaks the transport error: %s", recorder.Body.String())
+			}
+			if got := len(mailer.Messages()); got != tt.wantSent {
+				t.Errorf("sent %d messages, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestSendTestEmailRequiresPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+	useTestKeys(t)
+	if err := rbac.Seed(); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+
+	var support, gateStaff models.Role
+	config.DB.Where("name = ?", "support").First(&support)
+	config.DB.Where("name = ?", "gate_staff").First(&gateStaff)
+	supportAdmin := models.Admin{Name: "Support", Email: "support@example.com", Password: "x"}
+	gateAdmin := models.Admin{Name: "Gate 3", Email: "gate3@example.com", Password: "x"}
+	user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x", EmailVerifiedAt: ptr(time.Now())}
+	testdb.Create(t, &supportAdmin, &gateAdmin, &user)
+	testdb.Create(t,
+		&models.AccountRole{AccountType: "admin", AccountID: supportAdmin.AdminID, RoleID: support.RoleID},
+		&models.AccountRole{AccountType: "admin", AccountID: gateAdmin.AdminID, RoleID: gateStaff.RoleID},
+	)
+
+	token := func(id uint, address, accountType string) string {
+		session := models.Session{AccountType: accountType, AccountID: id, ExpiresAt: time.Now().Add(time.Hour)}
+		testdb.Create(t, &session)
+		token, err := generateJWT(id, address, accountType, session.SessionID, time.Hour)
+		if err != nil {
+			t.Fatalf("generateJWT: %v", err)
+		}
+		return token
+	}
+
+	mailer := email.NewMemoryMailer()
+	router := gin.New()
+	router.GET("/test-email", middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"),
+		middleware.RequirePermission(rbac.EmailsResend), SendTestEmail(mailer))
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{name: "no token", wantCode: http.StatusUnauthorized},
+		{name: "user", token: token(user.UserID, user.Email, "user"), wantCode: http.StatusForbidden},
+		{name: "admin without emails:resend", token: token(gateAdmin.AdminID, gateAdmin.Email, "admin"), wantCode: http.StatusForbidden},
+		{name: "admin with emails:resend", token: token(supportAdmin.AdminID, supportAdmin.Email, "admin"), wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/test-email", nil)
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.wantCode, recorder.Body.String())
+			}
+		})
+	}
+	if got := len(mailer.Messages()); got != 1 {
+		t.Errorf("sent %d messages, want 1", got)
+	}
+}
+
+func TestResendEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+
+	router := gin.New()
+	router.POST("/admin/emails/:id/resend", ResendEmail)
+
+	tests := []struct {
+		status   string
+		wantCode int
+	}{
+		{status: "Dead", wantCode: http.StatusOK},
+		{status: "Pending", wantCode: http.StatusConflict},
+		{status: "Sending", wantCode: http.StatusConflict},
+		{status: "Sent", wantCode: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			message := models.EmailMessage{To: "ana@example.com", Subject: "Your tickets", Status: tt.status, Attempts: 8, NextAttemptAt: time.Now()}
+			testdb.Create(t, &message)
+
+			recorder := serve(router, http.MethodPost, "/admin/emails/"+strconv.FormatUint(uint64(message.EmailID), 10)+"/resend", nil)
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantCode, recorder.Body.String())
+			}
+
+			wantStatus, wantAttempts := tt.status, 8
+			if tt.wantCode == http.StatusOK {
+				wantStatus, wantAttempts = "Pending", 0
+			}
+			config.DB.First(&message, message.EmailID)
+			if message.Status != wantStatus || message.Attempts != wantAttempts {
+				t.Errorf("message is %s after %d attempts, want %s after %d", message.Status, message.Attempts, wantStatus, wantAttempts)
+			}
+		})
+	}
+
+	if code := serve(router, http.MethodPost, "/admin/emails/999/resend", nil).Code; code != http.StatusNotFound {
+		t.Errorf("missing email: status = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestSendLockoutEmail(t *testing.T) {
+	if err := email.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	mailer := email.NewMemoryMailer()
+	email.UseOutbox(email.NewMailerOutbox(mailer))
+	defer email.UseOutbox(email.NewDBOutbox())
+
+	owner := account{Type: "user", ID: 7, Name: "Ana", Email: "ana@example.com", Language: "en"}
+	if err := sendLockoutEmail(owner, time.Now().Add(15*time.Minute), "203.0.113.9"); err != nil {
+		t.Fatalf("sendLockoutEmail: %v", err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(messages))
+	}
+	if messages[0].To != owner.Email {
+		t.Errorf("To = %q, want %q", messages[0].To, owner.Email)
+	}
+	if !strings.Contains(messages[0].TextBody, "203.0.113.9") {
+		t.Errorf("text body does not name the IP address:\n%s", messages[0].TextBody)
+	}
+}
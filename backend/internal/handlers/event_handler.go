@@ -1,53 +1,246 @@
 package handlers
 
 import (
-    "coachella-backend/config"
-    "coachella-backend/internal/models"
-    "github.com/gin-gonic/gin"
-    "net/http"
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// Get event details
-func GetEvent(c *gin.Context) {
-    var event models.Event
-    result := config.DB.First(&event)
-    if result.Error != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-        return
-    }
-    c.JSON(http.StatusOK, event)
-}
-
-// Create or update the event (single event system)
-func CreateOrUpdateEvent(c *gin.Context) {
-    var event models.Event
-    if err := c.ShouldBindJSON(&event); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    // Check if event exists
-    var existingEvent models.Event
-    result := config.DB.First(&existingEvent)
-    if result.Error == nil {
-        // Update the existing event
-        event.EventID = existingEvent.EventID
-        config.DB.Save(&event)
-        c.JSON(http.StatusOK, event)
-        return
-    }
-
-    // Create a new event
-    config.DB.Create(&event)
-    c.JSON(http.StatusCreated, event)
-}
-
-// Delete the event (optional, for admins)
+// GetEvents retrieves all public events
+// @Summary Retrieve events
+// @Description Get all events that have not been archived, ordered by start date
+// @Tags Events
+// @Produce json
+// @Success 200 {array} models.Event
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /events [get]
+func GetEvents(c *gin.Context) {
+	var events []models.Event
+	result := config.DB.Scopes(models.Unarchived).Order("start_date").Find(&events)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// GetAllEvents retrieves every event, including archived ones
+// @Summary Retrieve all events
+// @Description Get all events including archived ones
+// @Tags Events
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Event
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events [get]
+func GetAllEvents(c *gin.Context) {
+	var events []models.Event
+	result := config.DB.Order("start_date").Find(&events)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// GetEventByID retrieves a single event by ID
+// @Summary Retrieve an event by ID
+// @Description Get a single event's details using its ID. Archived events are not found.
+// @Tags Events
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {object} models.Event
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Router /events/{id} [get]
+func GetEventByID(c *gin.Context) {
+	event, ok := findPublicEvent(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+// CreateEvent creates a new event
+// @Summary Create an event
+// @Description Add a new event to the system
+// @Tags Events
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param event body models.Event true "Event details"
+// @Success 201 {object} models.Event
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events [post]
+func CreateEvent(c *gin.Context) {
+	var event models.Event
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	if message := validateEvent(event); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	event.EventID = 0
+	event.ArchivedAt = nil
+	if err := config.DB.Create(&event).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, event)
+}
+
+// UpdateEvent updates an existing event
+// @Summary Update an event
+// @Description Modify the details of an existing event
+// @Tags Events
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param event body models.Event true "Updated event details"
+// @Success 200 {object} models.Event
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Router /admin/events/{id} [put]
+func UpdateEvent(c *gin.Context) {
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return
+	}
+
+	eventID, archivedAt := event.EventID, event.ArchivedAt
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	if message := validateEvent(event); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	// The ID and archive state can't be changed through the payload
+	event.EventID, event.ArchivedAt = eventID, archivedAt
+	if err := config.DB.Save(&event).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+// ArchiveEvent hides an event from public listings
+// @Summary Archive an event
+// @Description Archive an event so it no longer appears publicly or accepts new tickets. Existing purchases are kept.
+// @Tags Events
+// @Security BearerAuth
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {object} models.Event
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Router /admin/events/{id}/archive [post]
+func ArchiveEvent(c *gin.Context) {
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return
+	}
+
+	if event.ArchivedAt == nil {
+		event.ArchivedAt = ptr(time.Now())
+		if err := config.DB.Model(&event).Update("archived_at", event.ArchivedAt).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+// DeleteEvent deletes an event
+// @Summary Delete an event
+// @Description Permanently remove an event and its tickets. Events with purchases must be archived instead.
+// @Tags Events
+// @Security BearerAuth
+// @Param id path int true "Event ID"
+// @Success 200 {object} models.GenericResponse "Event deleted successfully"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Failure 409 {object} models.GenericResponse "Event has purchases"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events/{id} [delete]
 func DeleteEvent(c *gin.Context) {
-    result := config.DB.Delete(&models.Event{})
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
-        return
-    }
-    c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return
+	}
+
+	var purchases int64
+	config.DB.Model(&models.Transaction{}).
+		Joins("JOIN tickets ON tickets.ticket_id = transactions.ticket_id").
+		Where("tickets.event_id = ?", event.EventID).
+		Count(&purchases)
+	if purchases > 0 {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Event has purchases, archive it instead"})
+		return
+	}
+
+	if err := config.DB.Delete(&event).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Event deleted successfully"})
+}
+
+// validateEvent returns a message describing the first invalid field, if any
+func validateEvent(event models.Event) string {
+	if event.Name == "" {
+		return "name is required"
+	}
+	if _, err := time.LoadLocation(event.Timezone); err != nil || event.Timezone == "" {
+		return "timezone must be an IANA time zone such as America/Los_Angeles"
+	}
+	if (event.StartDate.IsZero() && event.DoorsOpenAt == nil) || (event.EndDate.IsZero() && event.CurfewAt == nil) {
+		return "start_date and end_date, or doors_open_at and curfew_at, are required"
+	}
+
+	startDate, endDate := event.StartDate, event.EndDate
+	if startDate.IsZero() {
+		startDate = event.LocalDate(*event.DoorsOpenAt)
+	}
+	if endDate.IsZero() {
+		endDate = event.LocalDate(*event.CurfewAt)
+	}
+	if endDate.Before(startDate.Time) {
+		return "end_date must not be before start_date"
+	}
+
+	if event.DoorsOpenAt != nil && event.FirstSetAt != nil && event.FirstSetAt.Before(*event.DoorsOpenAt) {
+		return "first_set_at must not be before doors_open_at"
+	}
+	if event.DoorsOpenAt != nil && event.CurfewAt != nil && !event.CurfewAt.After(*event.DoorsOpenAt) {
+		return "curfew_at must be after doors_open_at"
+	}
+	return ""
+}
+
+// findPublicEvent loads the event named by the id path parameter, responding
+// 404 itself when it does not exist or has been archived
+func findPublicEvent(c *gin.Context) (models.Event, bool) {
+	var event models.Event
+	if err := config.DB.Scopes(models.Unarchived).First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return event, false
+	}
+	return event, true
+}
+
+// publicEventIDs is a subquery selecting the IDs of events that are not archived
+func publicEventIDs() *gorm.DB {
+	return config.DB.Model(&models.Event{}).Scopes(models.Unarchived).Select("event_id")
 }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/testdb"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestArchivedEventsArePrivate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+
+	live := models.Event{Name: "Stagecoach", Timezone: "America/Los_Angeles"}
+	archived := models.Event{Name: "Coachella 2024", Timezone: "America/Los_Angeles", ArchivedAt: ptr(time.Now())}
+	testdb.Create(t, &live, &archived)
+	liveTicket := models.Ticket{EventID: live.EventID, Batch: 1, Type: "GA", QuantityAvailable: 10}
+	archivedTicket := models.Ticket{EventID: archived.EventID, Batch: 1, Type: "GA", QuantityAvailable: 10}
+	testdb.Create(t, &liveTicket, &archivedTicket)
+
+	router := gin.New()
+	router.GET("/tickets", GetTickets)
+	router.GET("/tickets/:id", GetTicketByID)
+	router.GET("/events/:id", GetEventByID)
+	router.GET("/events/:id/seat-map", GetSeatMap)
+	router.GET("/events/:id/lineup", GetEventLineup)
+	router.GET("/events/:id/schedule", GetEventSchedule)
+	router.GET("/events/:id/calendar.ics", GetEventCalendar)
+
+	for _, path := range []string{"", "/seat-map", "/lineup", "/schedule", "/calendar.ics"} {
+		t.Run("event"+path, func(t *testing.T) {
+			if code := serve(router, http.MethodGet, "/events/1"+path, nil).Code; code != http.StatusOK {
+				t.Errorf("live event: status = %d, want %d", code, http.StatusOK)
+			}
+			if code := serve(router, http.MethodGet, "/events/2"+path, nil).Code; code != http.StatusNotFound {
+				t.Errorf("archived event: status = %d, want %d", code, http.StatusNotFound)
+			}
+		})
+	}
+
+	t.Run("tickets", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/tickets", nil)
+		var tickets []models.Ticket
+		if err := json.Unmarshal(recorder.Body.Bytes(), &tickets); err != nil {
+			t.Fatalf("decode tickets: %v", err)
+		}
+		if len(tickets) != 1 || tickets[0].TicketID != liveTicket.TicketID {
+			t.Errorf("tickets = %+v, want only ticket %d", tickets, liveTicket.TicketID)
+		}
+		if code := serve(router, http.MethodGet, "/tickets/2", nil).Code; code != http.StatusNotFound {
+			t.Errorf("ticket of archived event: status = %d, want %d", code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"coachella-backend/internal/email"
+	"coachella-backend/internal/middleware"
+	"coachella-backend/internal/signing"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestKeys generates a signing key in the test database. Call it after testdb.Use.
+func useTestKeys(t *testing.T) {
+	t.Helper()
+	t.Setenv("JWT_KEY_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err := signing.Load(); err != nil {
+		t.Fatalf("load signing keys: %v", err)
+	}
+}
+
+// useTestMailer sends queued email straight to a MemoryMailer for the rest of the test
+func useTestMailer(t *testing.T) *email.MemoryMailer {
+	t.Helper()
+	if err := email.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	mailer := email.NewMemoryMailer()
+	email.UseOutbox(email.NewMailerOutbox(mailer))
+	t.Cleanup(func() { email.UseOutbox(email.NewDBOutbox()) })
+	return mailer
+}
+
+// serve sends a request with an optional JSON body through a router and returns the recorded response
+func serve(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	request := httptest.NewRequest(method, path, &payload)
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// asPrincipal authenticates every request as principal, like AuthMiddleware
+// does for a valid access token
+func asPrincipal(principal middleware.Principal) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("principal", principal)
+	}
+}
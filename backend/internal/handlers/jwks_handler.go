@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"coachella-backend/internal/signing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the keys that verify access tokens
+// @Summary JSON Web Key Set
+// @Description Get the public keys that verify the API's tokens, for services that check tokens themselves. Match a token's kid header to a key; refetch when a kid is unknown, since new keys are published before they sign.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} signing.JWKSet
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, signing.JWKS())
+}
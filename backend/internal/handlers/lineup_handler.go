@@ -0,0 +1,531 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetEventLineup retrieves the artists on an event's published schedule
+// @Summary Retrieve event lineup
+// @Description Get the artists with at least one published set at the event
+// @Tags Lineup
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {array} models.Artist
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /events/{id}/lineup [get]
+func GetEventLineup(c *gin.Context) {
+	event, ok := findPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	var artists []models.Artist
+	published := config.DB.Model(&models.Performance{}).
+		Select("artist_id").
+		Where("event_id = ? AND published_start_time IS NOT NULL", event.EventID)
+	if err := config.DB.Where("artist_id IN (?)", published).Order("name").Find(&artists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, artists)
+}
+
+// GetEventSchedule retrieves an event's published set times
+// @Summary Retrieve event schedule
+// @Description Get the published set times grouped by day and stage
+// @Tags Lineup
+// @Param id path int true "Event ID"
+// @Param day query string false "Only this day (YYYY-MM-DD)"
+// @Param stage_id query int false "Only this stage"
+// @Produce json
+// @Success 200 {array} models.ScheduleDay
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /events/{id}/schedule [get]
+func GetEventSchedule(c *gin.Context) {
+	event, ok := findPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Artist").
+		Where("event_id = ? AND published_start_time IS NOT NULL", event.EventID).
+		Order("published_start_time")
+	if stageID := c.Query("stage_id"); stageID != "" {
+		query = query.Where("published_stage_id = ?", stageID)
+	}
+	var performances []models.Performance
+	if err := query.Find(&performances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	stages, err := eventStages(event.EventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	schedule := buildSchedule(performances, stages, event.Location())
+	if day := c.Query("day"); day != "" {
+		filtered := []models.ScheduleDay{}
+		for _, scheduleDay := range schedule {
+			if scheduleDay.Day == day {
+				filtered = append(filtered, scheduleDay)
+			}
+		}
+		schedule = filtered
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// GetArtists retrieves all artists
+// @Summary Retrieve artists
+// @Description Get every artist, ordered by name
+// @Tags Lineup
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Artist
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/artists [get]
+func GetArtists(c *gin.Context) {
+	var artists []models.Artist
+	if err := config.DB.Order("name").Find(&artists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, artists)
+}
+
+// CreateArtist creates a new artist
+// @Summary Create an artist
+// @Description Add a new artist
+// @Tags Lineup
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param artist body models.Artist true "Artist details"
+// @Success 201 {object} models.Artist
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/artists [post]
+func CreateArtist(c *gin.Context) {
+	var artist models.Artist
+	if err := c.ShouldBindJSON(&artist); err != nil || artist.Name == "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "name is required"})
+		return
+	}
+	if err := config.DB.Create(&artist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, artist)
+}
+
+// UpdateArtist updates an existing artist
+// @Summary Update an artist
+// @Description Modify an artist's details
+// @Tags Lineup
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Artist ID"
+// @Param artist body models.Artist true "Updated artist details"
+// @Success 200 {object} models.Artist
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Artist not found"
+// @Router /admin/artists/{id} [put]
+func UpdateArtist(c *gin.Context) {
+	var artist models.Artist
+	if err := config.DB.First(&artist, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Artist not found"})
+		return
+	}
+	artistID := artist.ArtistID
+	if err := c.ShouldBindJSON(&artist); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	artist.ArtistID = artistID
+	config.DB.Save(&artist)
+	c.JSON(http.StatusOK, artist)
+}
+
+// DeleteArtist deletes an artist
+// @Summary Delete an artist
+// @Description Remove an artist that has no performances
+// @Tags Lineup
+// @Security BearerAuth
+// @Param id path int true "Artist ID"
+// @Success 200 {object} models.GenericResponse "Artist deleted successfully"
+// @Failure 409 {object} models.GenericResponse "Artist has performances"
+// @Router /admin/artists/{id} [delete]
+func DeleteArtist(c *gin.Context) {
+	var performances int64
+	config.DB.Model(&models.Performance{}).Where("artist_id = ?", c.Param("id")).Count(&performances)
+	if performances > 0 {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Artist has performances, remove them first"})
+		return
+	}
+	if err := config.DB.Delete(&models.Artist{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Artist deleted successfully"})
+}
+
+// CreateStage adds a stage to an event
+// @Summary Create a stage
+// @Description Add a stage to an event
+// @Tags Lineup
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param stage body models.Stage true "Stage details"
+// @Success 201 {object} models.Stage
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Router /admin/events/{id}/stages [post]
+func CreateStage(c *gin.Context) {
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return
+	}
+
+	var stage models.Stage
+	if err := c.ShouldBindJSON(&stage); err != nil || stage.Name == "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "name is required"})
+		return
+	}
+	stage.StageID = 0
+	stage.EventID = event.EventID
+	if err := config.DB.Create(&stage).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, stage)
+}
+
+// DeleteStage deletes a stage
+// @Summary Delete a stage
+// @Description Remove a stage that has no performances
+// @Tags Lineup
+// @Security BearerAuth
+// @Param id path int true "Stage ID"
+// @Success 200 {object} models.GenericResponse "Stage deleted successfully"
+// @Failure 409 {object} models.GenericResponse "Stage has performances"
+// @Router /admin/stages/{id} [delete]
+func DeleteStage(c *gin.Context) {
+	var performances int64
+	config.DB.Model(&models.Performance{}).Where("stage_id = ?", c.Param("id")).Count(&performances)
+	if performances > 0 {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Stage has performances, move or remove them first"})
+		return
+	}
+	if err := config.DB.Delete(&models.Stage{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Stage deleted successfully"})
+}
+
+// GetEventPerformances retrieves the working copy of an event's schedule
+// @Summary Retrieve draft performances
+// @Description Get every performance of an event, including unpublished changes
+// @Tags Lineup
+// @Security BearerAuth
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {array} models.Performance
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events/{id}/performances [get]
+func GetEventPerformances(c *gin.Context) {
+	var performances []models.Performance
+	err := config.DB.Preload("Artist").Preload("Stage").
+		Where("event_id = ?", c.Param("id")).
+		Order("start_time").
+		Find(&performances).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, performances)
+}
+
+// CreatePerformance adds a set to an event's schedule
+// @Summary Create a performance
+// @Description Add an unpublished set time for an artist on one of the event's stages
+// @Tags Lineup
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param performance body models.Performance true "Performance details"
+// @Success 201 {object} models.Performance
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Router /admin/events/{id}/performances [post]
+func CreatePerformance(c *gin.Context) {
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return
+	}
+
+	var performance models.Performance
+	if err := c.ShouldBindJSON(&performance); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	performance.PerformanceID = 0
+	performance.EventID = event.EventID
+	performance.PublishedStageID = nil
+	performance.PublishedStartTime = nil
+	performance.PublishedEndTime = nil
+	if message := validatePerformance(&performance); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	if err := config.DB.Omit(clause.Associations).Create(&performance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, performance)
+}
+
+// UpdatePerformance changes a set in the working copy of the schedule
+// @Summary Update a performance
+// @Description Change a set's artist, stage or times. Changes become public when the schedule is published.
+// @Tags Lineup
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Performance ID"
+// @Param performance body models.Performance true "Updated performance details"
+// @Success 200 {object} models.Performance
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Performance not found"
+// @Router /admin/performances/{id} [put]
+func UpdatePerformance(c *gin.Context) {
+	var existing models.Performance
+	if err := config.DB.First(&existing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Performance not found"})
+		return
+	}
+
+	performance := existing
+	if err := c.ShouldBindJSON(&performance); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	// Only the working copy is editable
+	performance.PerformanceID = existing.PerformanceID
+	performance.EventID = existing.EventID
+	performance.PublishedStageID = existing.PublishedStageID
+	performance.PublishedStartTime = existing.PublishedStartTime
+	performance.PublishedEndTime = existing.PublishedEndTime
+	if message := validatePerformance(&performance); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	if err := config.DB.Omit(clause.Associations).Save(&performance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, performance)
+}
+
+// DeletePerformance removes a set from the schedule
+// @Summary Delete a performance
+// @Description Remove a set. Ticket holders are notified when a published set is cancelled.
+// @Tags Lineup
+// @Security BearerAuth
+// @Param id path int true "Performance ID"
+// @Success 200 {object} models.GenericResponse "Performance deleted successfully"
+// @Failure 404 {object} models.GenericResponse "Performance not found"
+// @Router /admin/performances/{id} [delete]
+func DeletePerformance(c *gin.Context) {
+	var performance models.Performance
+	if err := config.DB.Preload("Artist").Preload("Event").First(&performance, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Performance not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&performance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	if performance.PublishedStartTime != nil {
+		notifyTicketHolders(performance.EventID, notify.TypeScheduleChange,
+			fmt.Sprintf("Schedule change for %s: %s has been cancelled.", performance.Event.Name, performance.Artist.Name))
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Performance deleted successfully"})
+}
+
+// PublishSchedule makes the working copy of an event's schedule public
+// @Summary Publish schedule
+// @Description Publish all pending schedule changes. Ticket holders are notified of sets that moved.
+// @Tags Lineup
+// @Security BearerAuth
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {object} models.GenericResponse "Schedule published"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events/{id}/schedule/publish [post]
+func PublishSchedule(c *gin.Context) {
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return
+	}
+
+	var performances []models.Performance
+	if err := config.DB.Preload("Artist").Preload("Stage").Where("event_id = ?", event.EventID).Find(&performances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	published := 0
+	var changes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, performance := range performances {
+			if !performanceChanged(performance) {
+				continue
+			}
+			err := tx.Model(&models.Performance{}).Where("performance_id = ?", performance.PerformanceID).Updates(map[string]interface{}{
+				"published_stage_id":   performance.StageID,
+				"published_start_time": performance.StartTime,
+				"published_end_time":   performance.EndTime,
+			}).Error
+			if err != nil {
+				return err
+			}
+			// Favorites get a fresh "starting soon" reminder for the new time
+			err = tx.Model(&models.Favorite{}).Where("performance_id = ?", performance.PerformanceID).Update("reminder_sent_at", nil).Error
+			if err != nil {
+				return err
+			}
+			// Sets published for the first time are additions, not changes
+			if performance.PublishedStartTime != nil {
+				changes = append(changes, fmt.Sprintf("%s now plays %s at %s",
+					performance.Artist.Name, performance.Stage.Name, performance.StartTime.In(event.Location()).Format("15:04 on Mon 02-01-2006")))
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	if len(changes) > 0 {
+		notifyTicketHolders(event.EventID, notify.TypeScheduleChange,
+			"Schedule change for "+event.Name+": "+strings.Join(changes, "; ")+".")
+	}
+
+	c.JSON(http.StatusOK, models.GenericResponse{
+		Message: fmt.Sprintf("Published %d performance(s), %d set time change(s) announced", published, len(changes)),
+	})
+}
+
+// performanceChanged reports whether the working copy differs from what is published
+func performanceChanged(performance models.Performance) bool {
+	return performance.PublishedStartTime == nil ||
+		performance.PublishedEndTime == nil ||
+		performance.PublishedStageID == nil ||
+		*performance.PublishedStageID != performance.StageID ||
+		!performance.PublishedStartTime.Equal(performance.StartTime) ||
+		!performance.PublishedEndTime.Equal(performance.EndTime)
+}
+
+// validatePerformance returns a message describing the first invalid field, if any
+func validatePerformance(performance *models.Performance) string {
+	if performance.StartTime.IsZero() || !performance.EndTime.After(performance.StartTime) {
+		return "end_time must be after start_time"
+	}
+	var count int64
+	config.DB.Model(&models.Artist{}).Where("artist_id = ?", performance.ArtistID).Count(&count)
+	if count == 0 {
+		return "artist_id must reference an existing artist"
+	}
+	config.DB.Model(&models.Stage{}).Where("stage_id = ? AND event_id = ?", performance.StageID, performance.EventID).Count(&count)
+	if count == 0 {
+		return "stage_id must reference a stage of this event"
+	}
+	return ""
+}
+
+// eventStages loads an event's stages keyed by ID
+func eventStages(eventID uint) (map[uint]models.Stage, error) {
+	var stages []models.Stage
+	if err := config.DB.Where("event_id = ?", eventID).Find(&stages).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Stage, len(stages))
+	for _, stage := range stages {
+		byID[stage.StageID] = stage
+	}
+	return byID, nil
+}
+
+// buildSchedule groups published performances, ordered by start time, into days
+// and stages. Days and times are those of the venue's time zone.
+func buildSchedule(performances []models.Performance, stages map[uint]models.Stage, loc *time.Location) []models.ScheduleDay {
+	schedule := []models.ScheduleDay{}
+	dayIndex := map[string]int{}
+	for _, performance := range performances {
+		day := performance.PublishedStartTime.In(loc).Format("2006-01-02")
+		i, ok := dayIndex[day]
+		if !ok {
+			i = len(schedule)
+			dayIndex[day] = i
+			schedule = append(schedule, models.ScheduleDay{Day: day})
+		}
+
+		stageID := *performance.PublishedStageID
+		dayStages := &schedule[i].Stages
+		j := -1
+		for k := range *dayStages {
+			if (*dayStages)[k].StageID == stageID {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			*dayStages = append(*dayStages, models.ScheduleStage{StageID: stageID, Name: stages[stageID].Name})
+			j = len(*dayStages) - 1
+		}
+		(*dayStages)[j].Performances = append((*dayStages)[j].Performances, models.ScheduleEntry{
+			PerformanceID: performance.PerformanceID,
+			ArtistID:      performance.ArtistID,
+			ArtistName:    performance.Artist.Name,
+			StartTime:     performance.PublishedStartTime.In(loc),
+			EndTime:       performance.PublishedEndTime.In(loc),
+		})
+	}
+
+	for _, scheduleDay := range schedule {
+		sort.Slice(scheduleDay.Stages, func(a, b int) bool { return scheduleDay.Stages[a].Name < scheduleDay.Stages[b].Name })
+	}
+	return schedule
+}
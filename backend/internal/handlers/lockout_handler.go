@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"coachella-backend/internal/email"
+	"coachella-backend/internal/lockout"
+	"coachella-backend/internal/models"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockAccount lifts a login lockout
+// @Summary Unlock an account
+// @Description Lift the lockout an account gets after too many failed logins, and forget its failed attempts. Failures from the client's IP address are kept.
+// @Tags Authentication
+// @Security BearerAuth
+// @Param type path string true "Account type: user or admin"
+// @Param id path int true "Account ID"
+// @Produce json
+// @Success 200 {object} models.GenericResponse "Account unlocked"
+// @Failure 404 {object} models.GenericResponse "Account not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/accounts/{type}/{id}/unlock [post]
+func UnlockAccount(c *gin.Context) {
+	account, ok := findPathAccount(c)
+	if !ok {
+		return
+	}
+	if err := lockout.Unlock(c.Request.Context(), lockout.AccountKey(account.Type, account.Email)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to unlock account"})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Account unlocked"})
+}
+
+// beginLogin reserves a login attempt on the account key from the client's
+// IP address, responding 429 itself when it must wait. Every attempt it lets
+// through must end with loginFailed or loginSucceeded.
+func beginLogin(c *gin.Context, accountKey string) bool {
+	wait, err := lockout.Begin(c.Request.Context(), accountKey, lockout.IPKey(c.ClientIP()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to check login attempts"})
+		return false
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.GenericResponse{Error: "Too many failed login attempts, try again later"})
+		return false
+	}
+	return true
+}
+
+// loginFailed confirms a reserved attempt as failed and, when it locks the
+// account, emails the owner. Emails without an account are counted the same
+// but get no email.
+func loginFailed(c *gin.Context, accountType, address string) {
+	lockedUntil, err := lockout.Fail(c.Request.Context(), lockout.AccountKey(accountType, address), lockout.IPKey(c.ClientIP()))
+	if err != nil {
+		log.Printf("Failed to record failed login: %v\n", err)
+		return
+	}
+	if lockedUntil.IsZero() {
+		return
+	}
+
+	account, err := findAccountByEmail(accountType, address)
+	if err != nil {
+		return
+	}
+	if err := sendLockoutEmail(account, lockedUntil, c.ClientIP()); err != nil {
+		log.Printf("Failed to queue lockout email for %s %d: %v\n", account.Type, account.ID, err)
+	}
+}
+
+// loginSucceeded ends a reserved attempt that logged in with the given
+// address, forgetting the account's failed logins
+func loginSucceeded(c *gin.Context, accountType, address string) {
+	err := lockout.Succeed(c.Request.Context(), lockout.AccountKey(accountType, address), lockout.IPKey(c.ClientIP()))
+	if err != nil {
+		log.Printf("Failed to reset failed logins of %s %s: %v\n", accountType, address, err)
+	}
+}
+
+// sendLockoutEmail tells an account's owner that failed logins locked it
+func sendLockoutEmail(account account, lockedUntil time.Time, ip string) error {
+	rendered, err := email.Render("account_locked", account.Language, map[string]interface{}{
+		"name":           account.Name,
+		"locked_minutes": int(math.Ceil(time.Until(lockedUntil).Minutes())),
+		"ip_address":     ip,
+	})
+	if err != nil {
+		return err
+	}
+	return email.Enqueue(rendered.Message(account.Email))
+}
@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/signing"
+	"coachella-backend/internal/totp"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengeTTL    = 5 * time.Minute  // How long a login challenge can be completed
+	mfaMaxAttempts     = 5                // Wrong codes in a row before verification locks
+	mfaLockout         = 15 * time.Minute // How long verification stays locked
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 5 // Random bytes per code, shown as two groups of five hex digits
+)
+
+var (
+	errMFALocked      = errors.New("too many wrong codes; try again later")
+	errMFACodeInvalid = errors.New("invalid code")
+)
+
+// mfaRequired reports whether an account type must use two-factor
+// authentication. Admins must unless MFA_REQUIRED_FOR_ADMINS is "false".
+func mfaRequired(accountType string) bool {
+	return accountType == "admin" && envOrDefault("MFA_REQUIRED_FOR_ADMINS", "true") != "false"
+}
+
+// completeLogin finishes a password login: accounts with two-factor
+// authentication, or required to have it, get a challenge instead of tokens
+func completeLogin(c *gin.Context, account account) {
+	factor, err := findMFAFactor(account)
+	enrolled := err == nil && factor.ConfirmedAt != nil
+	if enrolled || mfaRequired(account.Type) {
+		challenge, err := mfaChallenge(account, !enrolled)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to start two-factor challenge"})
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	tokens, err := startSession(c, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to start session"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// ChallengeEnrollMFA starts authenticator enrollment during a login challenge
+// @Summary Enroll 2FA during login
+// @Description For a login challenge with enrollment_required, create an authenticator secret. Complete the login at /auth/mfa/verify with a code from the authenticator.
+// @Tags Two-factor authentication
+// @Accept json
+// @Produce json
+// @Param challenge body models.MFAChallengeRequest true "MFA token from the login"
+// @Success 200 {object} models.MFAEnrollmentResponse
+// @Failure 400 {object} models.GenericResponse "Invalid input"
+// @Failure 401 {object} models.GenericResponse "Invalid or expired MFA token"
+// @Failure 409 {object} models.GenericResponse "Already enrolled"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /auth/mfa/enroll [post]
+func ChallengeEnrollMFA(c *gin.Context) {
+	var request models.MFAChallengeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+	account, err := parseMFAToken(request.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid or expired MFA token"})
+		return
+	}
+	enrollMFA(c, account)
+}
+
+// VerifyMFA completes a login challenge with a second factor
+// @Summary Verify 2FA at login
+// @Description Complete a login challenge with a code from the authenticator, or one of the recovery codes. A challenge that enrolled an authenticator confirms it, and the response then carries the new recovery codes.
+// @Tags Two-factor authentication
+// @Accept json
+// @Produce json
+// @Param verification body models.MFAVerifyRequest true "MFA token and code or recovery code"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.GenericResponse "Invalid input"
+// @Failure 401 {object} models.GenericResponse "Invalid token or code"
+// @Failure 429 {object} models.GenericResponse "Too many wrong codes"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /auth/mfa/verify [post]
+func VerifyMFA(c *gin.Context) {
+	var request models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&request); err != nil || (request.Code == "") == (request.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Provide the mfa_token and either a code or a recovery_code"})
+		return
+	}
+	account, err := parseMFAToken(request.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid or expired MFA token"})
+		return
+	}
+	factor, err := findMFAFactor(account)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Enroll an authenticator first"})
+		return
+	}
+	if factor.ConfirmedAt == nil && request.Code == "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Confirm the new authenticator with a code"})
+		return
+	}
+	if !checkSecondFactor(c, &factor, request.Code, request.RecoveryCode) {
+		return
+	}
+
+	var recoveryCodes []string
+	if factor.ConfirmedAt == nil {
+		if recoveryCodes, err = confirmMFAFactor(factor); err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to confirm authenticator"})
+			return
+		}
+	}
+
+	tokens, err := startSession(c, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to start session"})
+		return
+	}
+	tokens.RecoveryCodes = recoveryCodes
+	c.JSON(http.StatusOK, tokens)
+}
+
+// GetMFAStatus describes the caller's two-factor authentication
+// @Summary Get 2FA status
+// @Description Whether two-factor authentication is on for the logged-in account, whether its role requires it, and how many recovery codes are left
+// @Tags Two-factor authentication
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.MFAStatusResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Router /user/mfa [get]
+// @Router /admin/mfa [get]
+func GetMFAStatus(c *gin.Context) {
+	account, ok := currentMFAAccount(c)
+	if !ok {
+		return
+	}
+
+	status := models.MFAStatusResponse{Required: mfaRequired(account.Type)}
+	if factor, err := findMFAFactor(account); err == nil && factor.ConfirmedAt != nil {
+		status.Enabled = true
+		var remaining int64
+		config.DB.Model(&models.RecoveryCode{}).
+			Where("account_type = ? AND account_id = ? AND used_at IS NULL", account.Type, account.ID).
+			Count(&remaining)
+		status.RecoveryCodesRemaining = int(remaining)
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollMFA starts authenticator enrollment for the caller
+// @Summary Enroll 2FA
+// @Description Create an authenticator secret for the logged-in account, as an otpauth URI and QR code. Two-factor authentication turns on once a code is confirmed at /mfa/confirm.
+// @Tags Two-factor authentication
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.MFAEnrollmentResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 409 {object} models.GenericResponse "Already enabled"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/mfa/enroll [post]
+// @Router /admin/mfa/enroll [post]
+func EnrollMFA(c *gin.Context) {
+	account, ok := currentMFAAccount(c)
+	if !ok {
+		return
+	}
+	enrollMFA(c, account)
+}
+
+// ConfirmMFA turns two-factor authentication on with a first code
+// @Summary Confirm 2FA enrollment
+// @Description Confirm the enrolled authenticator with a code from it, turning two-factor authentication on. Returns the recovery codes, which are shown only once.
+// @Tags Two-factor authentication
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param code body models.MFACodeRequest true "Code from the authenticator"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} models.GenericResponse "No enrollment to confirm"
+// @Failure 401 {object} models.GenericResponse "Invalid code"
+// @Failure 429 {object} models.GenericResponse "Too many wrong codes"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/mfa/confirm [post]
+// @Router /admin/mfa/confirm [post]
+func ConfirmMFA(c *gin.Context) {
+	account, ok := currentMFAAccount(c)
+	if !ok {
+		return
+	}
+	var request models.MFACodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	factor, err := findMFAFactor(account)
+	if err != nil || factor.ConfirmedAt != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "No authenticator enrollment to confirm"})
+		return
+	}
+	if !checkSecondFactor(c, &factor, request.Code, "") {
+		return
+	}
+	codes, err := confirmMFAFactor(factor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to confirm authenticator"})
+		return
+	}
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes
+// @Summary Regenerate recovery codes
+// @Description Replace every recovery code of the logged-in account with new ones, shown only once
+// @Tags Two-factor authentication
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param code body models.MFACodeRequest true "Code from the authenticator"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} models.GenericResponse "Two-factor authentication is off"
+// @Failure 401 {object} models.GenericResponse "Invalid code"
+// @Failure 429 {object} models.GenericResponse "Too many wrong codes"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/mfa/recovery-codes [post]
+// @Router /admin/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	account, ok := currentMFAAccount(c)
+	if !ok {
+		return
+	}
+	var request models.MFACodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	factor, err := findMFAFactor(account)
+	if err != nil || factor.ConfirmedAt == nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Two-factor authentication is not enabled"})
+		return
+	}
+	if !checkSecondFactor(c, &factor, request.Code, "") {
+		return
+	}
+	codes, err := replaceRecoveryCodes(config.DB, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to generate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA turns the caller's two-factor authentication off
+// @Summary Disable 2FA
+// @Description Turn two-factor authentication off for the logged-in account, removing its authenticator and recovery codes. Not allowed where the account's role requires it.
+// @Tags Two-factor authentication
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param confirmation body models.MFADisableRequest true "Password and a code or recovery code"
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Two-factor authentication is off"
+// @Failure 401 {object} models.GenericResponse "Invalid password or code"
+// @Failure 403 {object} models.GenericResponse "Required for the role"
+// @Failure 429 {object} models.GenericResponse "Too many wrong codes"
+// @Router /user/mfa/disable [post]
+// @Router /admin/mfa/disable [post]
+func DisableMFA(c *gin.Context) {
+	account, ok := currentMFAAccount(c)
+	if !ok {
+		return
+	}
+	if mfaRequired(account.Type) {
+		c.JSON(http.StatusForbidden, models.GenericResponse{Error: "Two-factor authentication is required for " + account.Type + " accounts"})
+		return
+	}
+	var request models.MFADisableRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	factor, err := findMFAFactor(account)
+	if err != nil || factor.ConfirmedAt == nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Two-factor authentication is not enabled"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(request.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid password"})
+		return
+	}
+	code, recoveryCode := request.Code, ""
+	if len(strings.TrimSpace(code)) != 6 {
+		code, recoveryCode = "", request.Code
+	}
+	if !checkSecondFactor(c, &factor, code, recoveryCode) {
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&factor).Error; err != nil {
+			return err
+		}
+		return tx.Where("account_type = ? AND account_id = ?", account.Type, account.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Two-factor authentication disabled"})
+}
+
+// enrollMFA replaces any unconfirmed authenticator of the account with a new secret
+func enrollMFA(c *gin.Context, account account) {
+	if factor, err := findMFAFactor(account); err == nil && factor.ConfirmedAt != nil {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to generate secret"})
+		return
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("account_type = ? AND account_id = ?", account.Type, account.ID).Delete(&models.MFAFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.MFAFactor{AccountType: account.Type, AccountID: account.ID, Secret: secret}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to save enrollment"})
+		return
+	}
+
+	uri := totp.URI(envOrDefault("MFA_ISSUER", "Coachella"), account.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to render QR code"})
+		return
+	}
+	c.JSON(http.StatusOK, models.MFAEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// checkSecondFactor verifies a TOTP code or, failing that, a recovery code,
+// counting wrong codes towards a lockout. It responds itself on failure.
+func checkSecondFactor(c *gin.Context, factor *models.MFAFactor, code, recoveryCode string) bool {
+	err := verifySecondFactor(factor, code, recoveryCode)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errMFALocked):
+		c.JSON(http.StatusTooManyRequests, models.GenericResponse{Error: "Too many wrong codes; try again later"})
+	case errors.Is(err, errMFACodeInvalid):
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid code"})
+	default:
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+	}
+	return false
+}
+
+func verifySecondFactor(factor *models.MFAFactor, code, recoveryCode string) error {
+	now := time.Now()
+	if factor.LockedUntil != nil && now.Before(*factor.LockedUntil) {
+		return errMFALocked
+	}
+
+	if code != "" {
+		// Accept each step once, even when two requests race with the same code
+		if step, ok := totp.Validate(factor.Secret, code, now); ok && step > factor.LastUsedStep {
+			result := config.DB.Model(factor).Where("last_used_step < ?", step).
+				Updates(map[string]interface{}{"last_used_step": step, "failed_attempts": 0, "locked_until": nil})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 1 {
+				return nil
+			}
+		}
+	} else if recoveryCode != "" && factor.ConfirmedAt != nil {
+		result := config.DB.Model(&models.RecoveryCode{}).
+			Where("account_type = ? AND account_id = ? AND code_hash = ? AND used_at IS NULL",
+				factor.AccountType, factor.AccountID, hashToken(normalizeRecoveryCode(recoveryCode))).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			config.DB.Model(factor).Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil})
+			return nil
+		}
+	}
+
+	changes := map[string]interface{}{"failed_attempts": gorm.Expr("failed_attempts + 1")}
+	if factor.FailedAttempts+1 >= mfaMaxAttempts {
+		changes = map[string]interface{}{"failed_attempts": 0, "locked_until": now.Add(mfaLockout)}
+	}
+	if err := config.DB.Model(factor).Updates(changes).Error; err != nil {
+		return err
+	}
+	return errMFACodeInvalid
+}
+
+// confirmMFAFactor turns an enrolled authenticator on and issues recovery codes
+func confirmMFAFactor(factor models.MFAFactor) ([]string, error) {
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&factor).Update("confirmed_at", time.Now()).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, account{Type: factor.AccountType, ID: factor.AccountID})
+		return err
+	})
+	return codes, err
+}
+
+// replaceRecoveryCodes deletes an account's recovery codes and stores new ones
+func replaceRecoveryCodes(db *gorm.DB, account account) ([]string, error) {
+	if err := db.Where("account_type = ? AND account_id = ?", account.Type, account.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		random, err := randomToken(recoveryCodeLength)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = random[:5] + "-" + random[5:]
+		rows[i] = models.RecoveryCode{AccountType: account.Type, AccountID: account.ID, CodeHash: hashToken(random)}
+	}
+	return codes, db.Create(&rows).Error
+}
+
+// normalizeRecoveryCode accepts recovery codes typed with or without the dash
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
+
+func findMFAFactor(account account) (models.MFAFactor, error) {
+	var factor models.MFAFactor
+	err := config.DB.Where("account_type = ? AND account_id = ?", account.Type, account.ID).First(&factor).Error
+	return factor, err
+}
+
+// currentMFAAccount loads the caller's account, responding itself on failure
+func currentMFAAccount(c *gin.Context) (account, bool) {
+	accountType, id, ok := currentAccount(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return account{}, false
+	}
+	account, err := findAccount(accountType, id)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Account not found"})
+		return account, false
+	}
+	return account, true
+}
+
+// mfaChallenge signs the token of a login waiting for a second factor
+func mfaChallenge(account account, enrollmentRequired bool) (models.MFAChallengeResponse, error) {
+	token, err := signing.Sign(signing.AudienceMFA, jwt.MapClaims{
+		"id":   account.ID,
+		"type": account.Type,
+		"exp":  time.Now().Add(mfaChallengeTTL).Unix(),
+	})
+	return models.MFAChallengeResponse{
+		MFARequired:        true,
+		EnrollmentRequired: enrollmentRequired,
+		MFAToken:           token,
+		ExpiresIn:          int(mfaChallengeTTL.Seconds()),
+	}, err
+}
+
+// parseMFAToken checks a login challenge token and loads its account
+func parseMFAToken(tokenString string) (account, error) {
+	token, err := signing.Parse(tokenString, signing.AudienceMFA)
+	if err != nil {
+		return account{}, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return account{}, errors.New("not an MFA token")
+	}
+	id, _ := claims["id"].(float64)
+	accountType, _ := claims["type"].(string)
+	return findAccount(accountType, uint(id))
+}
@@ -2,65 +2,191 @@ package handlers
 
 import (
 	"coachella-backend/config"
-	"coachella-backend/internal/email"
 	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"fmt"
 	"log"
 	"net/http"
-	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetNotifications retrieves all notifications for a user
-// @Summary Retrieve notifications for a user
-// @Description Get all notifications for a specific user
+// GetNotifications retrieves the current user's notifications
+// @Summary Retrieve my notifications
+// @Description Get a page of the logged-in user's notifications, newest first
 // @Tags Notifications
-// @Param user_id query int true "User ID"
+// @Security BearerAuth
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Notifications per page (default 20, max 100)"
+// @Param unread query bool false "Only unread notifications"
 // @Produce json
-// @Success 200 {array} models.Notification
-// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Success 200 {object} models.NotificationPage
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
 // @Router /notifications [get]
 func GetNotifications(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "user_id is required"})
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
 		return
 	}
 
-	var notifications []models.Notification
-	result := config.DB.Where("user_id = ?", userID).Find(&notifications)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	result := models.NotificationPage{Page: page, PerPage: perPage}
+	if err := query.Count(&result.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	err = query.Order("created_at DESC, notification_id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&result.Notifications).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, notifications)
+	c.JSON(http.StatusOK, result)
 }
 
-// MarkNotificationAsRead marks a notification as read
+// GetUnreadNotificationCount counts the current user's unread notifications
+// @Summary Count unread notifications
+// @Description Get the number of unread notifications of the logged-in user
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.UnreadCountResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /notifications/unread-count [get]
+func GetUnreadNotificationCount(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var response models.UnreadCountResponse
+	err := config.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&response.UnreadCount).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// MarkNotificationAsRead marks one of the current user's notifications as read
 // @Summary Mark a notification as read
-// @Description Update a notification's status to "read"
+// @Description Set a notification's read time. Marking an already read notification keeps its original read time.
 // @Tags Notifications
+// @Security BearerAuth
 // @Param id path int true "Notification ID"
 // @Produce json
 // @Success 200 {object} models.Notification
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
 // @Failure 404 {object} models.GenericResponse "Notification not found"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
 // @Router /notifications/{id} [patch]
 func MarkNotificationAsRead(c *gin.Context) {
-	notificationID := c.Param("id")
-	var notification models.Notification
+	notification, ok := findUserNotification(c)
+	if !ok {
+		return
+	}
+
+	if notification.ReadAt == nil {
+		notification.ReadAt = ptr(time.Now())
+		if err := config.DB.Model(&notification).Update("read_at", notification.ReadAt).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, notification)
+}
 
-	result := config.DB.First(&notification, notificationID)
+// MarkAllNotificationsAsRead marks all of the current user's notifications as read
+// @Summary Mark all notifications as read
+// @Description Set the read time of every unread notification of the logged-in user
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /notifications/read-all [post]
+func MarkAllNotificationsAsRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	result := config.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Notification not found"})
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: fmt.Sprintf("%d notifications marked as read", result.RowsAffected)})
+}
 
-	config.DB.Save(&notification)
-	c.JSON(http.StatusOK, notification)
+// DeleteNotification deletes one of the current user's notifications
+// @Summary Delete a notification
+// @Description Delete a notification of the logged-in user
+// @Tags Notifications
+// @Security BearerAuth
+// @Param id path int true "Notification ID"
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 404 {object} models.GenericResponse "Notification not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /notifications/{id} [delete]
+func DeleteNotification(c *gin.Context) {
+	notification, ok := findUserNotification(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&notification).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Notification deleted successfully"})
+}
+
+// findUserNotification loads the notification in the path if it belongs to the
+// current user. Other users' notifications are reported as not found.
+func findUserNotification(c *gin.Context) (models.Notification, bool) {
+	var notification models.Notification
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return notification, false
+	}
+
+	err := config.DB.Where("user_id = ?", userID).First(&notification, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Notification not found"})
+		return notification, false
+	}
+	return notification, true
 }
 
 func NotifyWaitlistedUsers(ticketID uint) {
@@ -73,46 +199,53 @@ func NotifyWaitlistedUsers(ticketID uint) {
 	}
 
 	for _, entry := range waitlist {
-		// Prepare notification content
-		content := "Tickets are now available for the event you were waiting for!"
-
-		notification := models.Notification{
-			UserID:         entry.UserID,
-			NotificationType: "Update", // Or "Reminder" if relevant
-			Content:        content,
-			CreatedAt:      time.Now(),
-		}
-
-		// Save notification to the database
-		if err := config.DB.Create(&notification).Error; err != nil {
-			log.Printf("Failed to create notification for user %d: %v\n", entry.UserID, err)
-			continue
-		}
-
 		// Prepare email content
 		templateData := map[string]interface{}{
 			"name":       entry.User.Name,
 			"ticket_id":  ticketID,
-			"ticket_url": "http://example.com/tickets/" + string(ticketID), // Replace with actual URL
+			"ticket_url": "http://example.com/tickets/" + strconv.FormatUint(uint64(ticketID), 10), // Replace with actual URL
 		}
 
-		// Render email template
-		templatePath := filepath.Join("..", "templates", "emails", "waitlist_notification.html")
-		body, err := email.RenderTemplate(templatePath, templateData)
+		// Notify the user on the channels they allow
+		err := notify.Send(notify.Message{
+			UserID:  entry.UserID,
+			Type:    notify.TypeWaitlistOffer,
+			Content: "Tickets are now available for the event you were waiting for!",
+			Email:   &notify.Email{Template: "waitlist_notification", Data: templateData},
+		})
 		if err != nil {
-			log.Printf("Failed to render waitlist email for user %d: %v\n", entry.UserID, err)
-			continue
-		}
-
-		// Send email
-		err = email.SendEmail(entry.User.Email, "Tickets Now Available!", body)
-		if err != nil {
-			log.Printf("Failed to send waitlist email to user %d: %v\n", entry.UserID, err)
+			log.Printf("Failed to notify waitlisted user %d: %v\n", entry.UserID, err)
 		} else {
-			log.Printf("Waitlist email sent to user %d for ticket %d\n", entry.UserID, ticketID)
+			log.Printf("Waitlisted user %d notified for ticket %d\n", entry.UserID, ticketID)
 		}
 	}
 
 	// Optional: Clear waitlist entries for the notified ticket
 	config.DB.Where("ticket_id = ?", ticketID).Delete(&models.Waitlist{})
 }
+
+// eventTicketHolderIDs returns the users holding Paid tickets for an event
+func eventTicketHolderIDs(eventID uint) ([]uint, error) {
+	var userIDs []uint
+	err := config.DB.Model(&models.Transaction{}).
+		Distinct("transactions.user_id").
+		Joins("JOIN tickets ON tickets.ticket_id = transactions.ticket_id").
+		Where("tickets.event_id = ? AND transactions.payment_status = ?", eventID, "Paid").
+		Pluck("transactions.user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// notifyTicketHolders sends an in-app notification to every Paid ticket holder of an event
+func notifyTicketHolders(eventID uint, notificationType, content string) {
+	userIDs, err := eventTicketHolderIDs(eventID)
+	if err != nil {
+		log.Printf("Error fetching ticket holders for event %d: %v\n", eventID, err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := notify.Send(notify.Message{UserID: userID, Type: notificationType, Content: content}); err != nil {
+			log.Printf("Failed to notify user %d of event %d: %v\n", userID, eventID, err)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/email"
+	"coachella-backend/internal/lockout"
+	"coachella-backend/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// passwordResetTTL is how long a password reset link stays valid
+const passwordResetTTL = time.Hour
+
+// errResetTokenInvalid is returned for unknown, used and expired reset tokens alike
+var errResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// account is the part of a User or Admin the password flows need
+type account struct {
+	Type     string // "user" or "admin", as in the JWT type claim
+	ID       uint
+	Name     string
+	Email    string
+	Password string
+	Language string
+}
+
+// ForgotPassword emails a user a password reset link
+// @Summary Forgot password
+// @Description Email a single-use password reset link to a user. The response is the same whether or not the address is registered.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param account body models.EmailRequest true "Account email"
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Invalid input"
+// @Router /auth/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	forgotPassword(c, "user")
+}
+
+// AdminForgotPassword emails an admin a password reset link
+// @Summary Admin forgot password
+// @Description Email a single-use password reset link to an admin. The response is the same whether or not the address is registered.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param account body models.EmailRequest true "Admin email"
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Invalid input"
+// @Router /auth/admin-forgot-password [post]
+func AdminForgotPassword(c *gin.Context) {
+	forgotPassword(c, "admin")
+}
+
+func forgotPassword(c *gin.Context, accountType string) {
+	var request models.EmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	if account, err := findAccountByEmail(accountType, strings.TrimSpace(request.Email)); err == nil {
+		if err := sendPasswordReset(account); err != nil {
+			log.Printf("Failed to send password reset to %s %d: %v\n", account.Type, account.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "If the account exists, a password reset email has been sent"})
+}
+
+var resetPasswordPage = template.Must(template.New("reset").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Reset password</title></head>
+<body>
+    {{if .Done}}
+    <p>Your password has been changed. You can now log in with your new password.</p>
+    {{else}}
+    {{if .Error}}<p style="color:#d0442b;">{{.Error}}</p>{{end}}
+    <form method="post">
+        <input type="hidden" name="token" value="{{.Token}}">
+        <label>New password <input type="password" name="password" autocomplete="new-password" required></label>
+        <button type="submit">Reset password</button>
+    </form>
+    {{end}}
+</body>
+</html>`))
+
+// ShowResetPassword shows the form of a password reset link
+// @Summary Reset password page
+// @Description Show a form for choosing a new password with the reset token from the email
+// @Tags Authentication
+// @Param token query string true "Reset token from the email"
+// @Produce html
+// @Success 200 {string} string "Reset form"
+// @Router /auth/reset-password [get]
+func ShowResetPassword(c *gin.Context) {
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	resetPasswordPage.Execute(c.Writer, gin.H{"Token": c.Query("token")})
+}
+
+// ResetPassword sets a new password with a reset token
+// @Summary Reset password
+// @Description Set a new password with a single-use reset token, for users and admins alike. Every token issued for the account before is invalidated, and any login lockout is lifted. Accepts JSON, or the form of the reset page.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param reset body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Invalid or expired token, or weak password"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /auth/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	form := c.ContentType() == "application/x-www-form-urlencoded"
+	fail := func(status int, message string, token string) {
+		if form {
+			c.Status(status)
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			resetPasswordPage.Execute(c.Writer, gin.H{"Token": token, "Error": message})
+			return
+		}
+		c.JSON(status, models.GenericResponse{Error: message})
+	}
+
+	var request models.ResetPasswordRequest
+	if err := c.ShouldBind(&request); err != nil {
+		fail(http.StatusBadRequest, "Invalid input", request.Token)
+		return
+	}
+	if message := validatePassword(request.Password); message != "" {
+		fail(http.StatusBadRequest, message, request.Token)
+		return
+	}
+
+	var reset models.PasswordResetToken
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(request.Token), now).
+			First(&reset).Error
+		if err != nil {
+			return errResetTokenInvalid
+		}
+
+		// Use the token up first. The update only matches while it is unused,
+		// so of concurrent resets with the same token exactly one gets the row
+		// and the others find it used.
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("reset_token_id = ? AND used_at IS NULL", reset.ResetTokenID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errResetTokenInvalid
+		}
+
+		// Then invalidate every other token issued for the account
+		err = tx.Model(&models.PasswordResetToken{}).
+			Where("account_type = ? AND account_id = ? AND used_at IS NULL", reset.AccountType, reset.AccountID).
+			Update("used_at", now).Error
+		if err != nil {
+			return err
+		}
+		return setPassword(tx, reset.AccountType, reset.AccountID, request.Password)
+	})
+	if errors.Is(err, errResetTokenInvalid) {
+		fail(http.StatusBadRequest, "Invalid or expired reset link", "")
+		return
+	}
+	if err != nil {
+		fail(http.StatusInternalServerError, "Failed to reset password", request.Token)
+		return
+	}
+
+	// Proving control of the mailbox also lifts a login lockout
+	if account, err := findAccount(reset.AccountType, reset.AccountID); err == nil {
+		if err := lockout.Unlock(c.Request.Context(), lockout.AccountKey(account.Type, account.Email)); err != nil {
+			log.Printf("Failed to unlock %s %d after a password reset: %v\n", account.Type, account.ID, err)
+		}
+	}
+
+	if form {
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		resetPasswordPage.Execute(c.Writer, gin.H{"Done": true})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Password has been reset"})
+}
+
+// ChangePassword replaces the logged-in account's password
+// @Summary Change password
+// @Description Replace the password of the logged-in user or admin. Every session is revoked, so tokens for a new session are returned.
+// @Tags Authentication
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param passwords body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} models.TokenResponse "Tokens for a new session"
+// @Failure 400 {object} models.GenericResponse "Weak password"
+// @Failure 401 {object} models.GenericResponse "Current password is incorrect"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/change-password [post]
+// @Router /admin/change-password [post]
+func ChangePassword(c *gin.Context) {
+	accountType, id, ok := currentAccount(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var request models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	account, err := findAccount(accountType, id)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Account not found"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(request.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Current password is incorrect"})
+		return
+	}
+	if message := validatePassword(request.NewPassword); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	if err := setPassword(config.DB, account.Type, account.ID, request.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to change password"})
+		return
+	}
+	tokens, err := startSession(c, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to start session"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// findAccountByEmail loads the user or admin with an email address
+func findAccountByEmail(accountType, address string) (account, error) {
+	return loadAccount(accountType, config.DB.Where("email = ?", address))
+}
+
+// findAccount loads a user or admin by ID
+func findAccount(accountType string, id uint) (account, error) {
+	return loadAccount(accountType, config.DB.Where(map[string]interface{}{accountType + "_id": id}))
+}
+
+func loadAccount(accountType string, query *gorm.DB) (account, error) {
+	switch accountType {
+	case "user":
+		var user models.User
+		if err := query.First(&user).Error; err != nil {
+			return account{}, err
+		}
+		return account{Type: "user", ID: user.UserID, Name: user.Name, Email: user.Email, Password: user.Password, Language: user.Language}, nil
+	case "admin":
+		var admin models.Admin
+		if err := query.First(&admin).Error; err != nil {
+			return account{}, err
+		}
+		return account{Type: "admin", ID: admin.AdminID, Name: admin.Name, Email: admin.Email, Password: admin.Password, Language: "en"}, nil
+	}
+	return account{}, errors.New("unknown account type " + accountType)
+}
+
+// setPassword hashes and stores a new password and revokes every session of
+// the account, signing all its devices out
+func setPassword(db *gorm.DB, accountType string, id uint, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if accountType == "admin" {
+		err = db.Model(&models.Admin{}).Where("admin_id = ?", id).Update("password", string(hash)).Error
+	} else {
+		err = db.Model(&models.User{}).Where("user_id = ?", id).Update("password", string(hash)).Error
+	}
+	if err != nil {
+		return err
+	}
+	return revokeSessions(db, accountType, id, "password_changed")
+}
+
+// sendPasswordReset stores a new reset token for the account and emails its link
+func sendPasswordReset(account account) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	reset := models.PasswordResetToken{
+		AccountType: account.Type,
+		AccountID:   account.ID,
+		TokenHash:   hashToken(token),
+		ExpiresAt:   time.Now().Add(passwordResetTTL),
+	}
+	if err := config.DB.Create(&reset).Error; err != nil {
+		return err
+	}
+
+	resetURL := envOrDefault("PASSWORD_RESET_URL", config.BaseURL()+"/auth/reset-password")
+	rendered, err := email.Render("password_reset", account.Language, map[string]interface{}{
+		"name":            account.Name,
+		"reset_url":       resetURL + "?token=" + url.QueryEscape(token),
+		"expires_minutes": int(passwordResetTTL.Minutes()),
+	})
+	if err != nil {
+		return err
+	}
+	return email.Enqueue(rendered.Message(account.Email))
+}
+
+// hashToken is the stored form of a secret token, so a database leak doesn't reveal usable tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
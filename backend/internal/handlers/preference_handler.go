@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationSettings retrieves the current user's notification preferences
+// @Summary Retrieve notification preferences
+// @Description Get which channels (InApp, Email, SMS, Push) are on for each notification type, and the quiet hours during which non-urgent notifications are held back
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.NotificationSettings
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/notification-preferences [get]
+func GetNotificationSettings(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	settings, err := notificationSettings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateNotificationSettings changes the current user's notification preferences
+// @Summary Update notification preferences
+// @Description Turn channels on or off per notification type and set quiet hours. Only the listed preferences change. Null quiet hours turn them off.
+// @Tags Notifications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param settings body models.NotificationSettings true "Preferences and quiet hours"
+// @Success 200 {object} models.NotificationSettings
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/notification-preferences [put]
+func UpdateNotificationSettings(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var request models.NotificationSettings
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+	if message := validateNotificationSettings(request); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	for _, preference := range request.Preferences {
+		if err := notify.SetPreference(userID, preference.NotificationType, preference.Channel, preference.Enabled); err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	updates := map[string]interface{}{
+		"quiet_hours_start": request.QuietHoursStart,
+		"quiet_hours_end":   request.QuietHoursEnd,
+	}
+	if request.Timezone != "" {
+		updates["timezone"] = request.Timezone
+	}
+	if err := config.DB.Model(&models.User{}).Where("user_id = ?", userID).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	settings, err := notificationSettings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// unsubscribePage confirms an unsubscribe link. Opening the link only shows the
+// form, so mail scanners that follow links do not unsubscribe anyone.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Unsubscribe</title></head>
+<body>
+    {{if .Done}}<h1>You have been unsubscribed</h1>
+    <p>You will no longer receive {{.Type}} emails. You can turn them back on in your notification preferences.</p>
+    {{else}}<h1>Unsubscribe</h1>
+    <p>Stop receiving {{.Type}} emails?</p>
+    <form method="post"><button type="submit">Unsubscribe</button></form>
+    {{end}}
+</body>
+</html>`))
+
+// ShowUnsubscribe shows the confirmation page of an unsubscribe link
+// @Summary Unsubscribe page
+// @Description Show a page confirming that the recipient wants to stop receiving emails of the type in the signed token
+// @Tags Notifications
+// @Param token query string true "Signed unsubscribe token from the email"
+// @Produce html
+// @Success 200 {string} string "Confirmation page"
+// @Failure 400 {object} models.GenericResponse "Invalid unsubscribe link"
+// @Router /unsubscribe [get]
+func ShowUnsubscribe(c *gin.Context) {
+	_, notificationType, ok := notify.ParseUnsubscribeToken(c.Query("token"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid unsubscribe link"})
+		return
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(c.Writer, gin.H{"Type": notificationType, "Done": false})
+}
+
+// Unsubscribe turns off email for the user and type in a signed token
+// @Summary Unsubscribe
+// @Description Turn off emails of one notification type. Serves both the confirmation form and RFC 8058 one-click unsubscribe (List-Unsubscribe=One-Click).
+// @Tags Notifications
+// @Param token query string true "Signed unsubscribe token from the email"
+// @Produce html
+// @Success 200 {string} string "Unsubscribed"
+// @Failure 400 {object} models.GenericResponse "Invalid unsubscribe link"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /unsubscribe [post]
+func Unsubscribe(c *gin.Context) {
+	userID, notificationType, ok := notify.ParseUnsubscribeToken(c.Query("token"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid unsubscribe link"})
+		return
+	}
+	if err := notify.SetPreference(userID, notificationType, notify.ChannelEmail, false); err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(c.Writer, gin.H{"Type": notificationType, "Done": true})
+}
+
+// notificationSettings loads a user's preference matrix and quiet hours
+func notificationSettings(userID uint) (models.NotificationSettings, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return models.NotificationSettings{}, err
+	}
+	preferences, err := notify.Preferences(userID)
+	if err != nil {
+		return models.NotificationSettings{}, err
+	}
+	return models.NotificationSettings{
+		Preferences:     preferences,
+		QuietHoursStart: user.QuietHoursStart,
+		QuietHoursEnd:   user.QuietHoursEnd,
+		Timezone:        user.Timezone,
+	}, nil
+}
+
+// validateNotificationSettings returns a message describing the first invalid field, if any
+func validateNotificationSettings(settings models.NotificationSettings) string {
+	for _, preference := range settings.Preferences {
+		if !notify.ValidType(preference.NotificationType) {
+			return "unknown notification_type " + preference.NotificationType
+		}
+		if !notify.ValidChannel(preference.Channel) {
+			return "unknown channel " + preference.Channel
+		}
+	}
+	if (settings.QuietHoursStart == nil) != (settings.QuietHoursEnd == nil) {
+		return "quiet_hours_start and quiet_hours_end must be set together"
+	}
+	if settings.QuietHoursStart != nil {
+		if _, err := notify.ParseClock(*settings.QuietHoursStart); err != nil {
+			return "quiet_hours_start must be an HH:MM time"
+		}
+		if _, err := notify.ParseClock(*settings.QuietHoursEnd); err != nil {
+			return "quiet_hours_end must be an HH:MM time"
+		}
+	}
+	if settings.Timezone != "" {
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			return "unknown timezone " + settings.Timezone
+		}
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/email"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/signing"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// verifyEmailTTL is how long an email verification link stays valid
+const verifyEmailTTL = 48 * time.Hour
+
+// Register creates a user account and emails a verification link
+// @Summary Register
+// @Description Create a user account. The password needs at least 8 characters including a letter and a digit. A verification link is emailed to the address, and tickets can only be bought once it is verified.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param account body models.RegisterRequest true "Account details"
+// @Success 201 {object} models.User
+// @Failure 400 {object} models.GenericResponse "Invalid input or weak password"
+// @Failure 409 {object} models.GenericResponse "Email already registered"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /auth/register [post]
+func Register(c *gin.Context) {
+	var request models.RegisterRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	address, err := mail.ParseAddress(strings.TrimSpace(request.Email))
+	if err != nil || address.Name != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid email address"})
+		return
+	}
+	if message := validatePassword(request.Password); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+	language := "en"
+	if request.Language != "" {
+		language = email.NormalizeLocale(request.Language)
+		if !email.SupportsLocale(language) {
+			c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Unsupported language " + request.Language})
+			return
+		}
+	}
+
+	// Deleted accounts keep their address, so look past the soft delete
+	var existing int64
+	config.DB.Unscoped().Model(&models.User{}).Where("email = ?", address.Address).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Email already registered"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to hash password"})
+		return
+	}
+
+	user := models.User{
+		Name:     strings.TrimSpace(request.Name),
+		Email:    address.Address,
+		Password: string(hash),
+		Language: language,
+		Timezone: "UTC",
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		// A concurrent registration can still win the unique index
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Email already registered"})
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to queue verification email for user %d: %v\n", user.UserID, err)
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// VerifyEmail confirms a user's email address from the emailed link
+// @Summary Verify email address
+// @Description Confirm the email address a verification link was sent to
+// @Tags Authentication
+// @Param token query string true "Verification token from the email"
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Invalid or expired link"
+// @Router /auth/verify-email [get]
+func VerifyEmail(c *gin.Context) {
+	userID, address, err := parseVerificationToken(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid or expired verification link"})
+		return
+	}
+
+	// The address must still match, so a link sent before an email change is void
+	var user models.User
+	if err := config.DB.Where("email = ?", address).First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid or expired verification link"})
+		return
+	}
+	if user.EmailVerifiedAt == nil {
+		if err := config.DB.Model(&user).Update("email_verified_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Email address verified"})
+}
+
+// ResendVerification emails a new verification link
+// @Summary Resend verification email
+// @Description Send a new verification link to an unverified account. The response is the same whether or not the address is registered.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param account body models.EmailRequest true "Account email"
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Invalid input"
+// @Router /auth/resend-verification [post]
+func ResendVerification(c *gin.Context) {
+	var request models.EmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	var user models.User
+	err := config.DB.Where("email = ? AND email_verified_at IS NULL", strings.TrimSpace(request.Email)).First(&user).Error
+	if err == nil {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("Failed to queue verification email for user %d: %v\n", user.UserID, err)
+		}
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "If the account exists and is unverified, a verification email has been sent"})
+}
+
+// validatePassword checks the password strength rules, returning a message
+// describing the first rule broken or "" when the password is acceptable
+func validatePassword(password string) string {
+	if len(password) < 8 {
+		return "Password must be at least 8 characters"
+	}
+	// bcrypt only uses the first 72 bytes
+	if len(password) > 72 {
+		return "Password must be at most 72 bytes"
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	if !letter || !digit {
+		return "Password must contain a letter and a digit"
+	}
+	return ""
+}
+
+// sendVerificationEmail queues a verification link for the user's current address
+func sendVerificationEmail(user models.User) error {
+	token, err := verificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := email.Render("verify_email", user.Language, map[string]interface{}{
+		"name":          user.Name,
+		"verify_url":    config.BaseURL() + "/auth/verify-email?token=" + url.QueryEscape(token),
+		"expires_hours": int(verifyEmailTTL.Hours()),
+	})
+	if err != nil {
+		return err
+	}
+	return email.Enqueue(rendered.Message(user.Email))
+}
+
+// verificationToken signs an email verification token for the user's current address
+func verificationToken(user models.User) (string, error) {
+	return signing.Sign(signing.AudienceVerifyEmail, jwt.MapClaims{
+		"id":    user.UserID,
+		"email": user.Email,
+		"exp":   time.Now().Add(verifyEmailTTL).Unix(),
+	})
+}
+
+// parseVerificationToken checks an email verification token and returns the
+// user and address it was issued for
+func parseVerificationToken(tokenString string) (uint, string, error) {
+	token, err := signing.Parse(tokenString, signing.AudienceVerifyEmail)
+	if err != nil {
+		return 0, "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", errors.New("not an email verification token")
+	}
+	id, ok := claims["id"].(float64)
+	address, _ := claims["email"].(string)
+	if !ok || address == "" {
+		return 0, "", errors.New("malformed email verification token")
+	}
+	return uint(id), address, nil
+}
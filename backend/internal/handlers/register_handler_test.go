@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/signing"
+	"coachella-backend/internal/testdb"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"horse-42", true},
+		{"correct-horse-battery-staple-42", true},
+		{"kuda-lari-7ä", true},
+		{"short-1", false},
+		{"no-digits-here", false},
+		{"1234567890", false},
+		{strings.Repeat("a", 72) + "1", false},
+		{strings.Repeat("a", 71) + "1", true},
+	}
+	for _, tt := range tests {
+		if message := validatePassword(tt.password); (message == "") != tt.valid {
+			t.Errorf("validatePassword(%q) = %q, want valid %v", tt.password, message, tt.valid)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+	useTestKeys(t)
+	mailer := useTestMailer(t)
+
+	deleted := models.User{Name: "Budi", Email: "budi@example.com", Password: "x"}
+	testdb.Create(t, &deleted)
+	config.DB.Delete(&deleted)
+
+	router := gin.New()
+	router.POST("/auth/register", Register)
+	register := func(address, password string) int {
+		return serve(router, http.MethodPost, "/auth/register", map[string]string{"name": "Ana", "email": address, "password": password}).Code
+	}
+
+	if code := register("ana@example.com", "horse-42"); code != http.StatusCreated {
+		t.Fatalf("first registration: status = %d, want %d", code, http.StatusCreated)
+	}
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "ana@example.com" || !strings.Contains(messages[0].TextBody, "/auth/verify-email?token=") {
+		t.Errorf("sent %+v, want one verification link to ana@example.com", messages)
+	}
+
+	tests := []struct {
+		name     string
+		address  string
+		password string
+		wantCode int
+	}{
+		{"duplicate email", "ana@example.com", "horse-43", http.StatusConflict},
+		{"email of a deleted account", "budi@example.com", "horse-42", http.StatusConflict},
+		{"weak password", "citra@example.com", "horses", http.StatusBadRequest},
+		{"invalid email", "Citra <citra@example.com>", "horse-42", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := register(tt.address, tt.password); code != tt.wantCode {
+				t.Errorf("status = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+	var count int64
+	config.DB.Unscoped().Model(&models.User{}).Count(&count)
+	if count != 2 {
+		t.Errorf("%d users stored, want 2", count)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+	useTestKeys(t)
+
+	user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x"}
+	testdb.Create(t, &user)
+	valid, err := verificationToken(user)
+	if err != nil {
+		t.Fatalf("verificationToken: %v", err)
+	}
+	expired, _ := signing.Sign(signing.AudienceVerifyEmail, jwt.MapClaims{"id": user.UserID, "email": user.Email, "exp": time.Now().Add(-time.Minute).Unix()})
+	mfaToken, _ := signing.Sign(signing.AudienceMFA, jwt.MapClaims{"id": user.UserID, "email": user.Email, "exp": time.Now().Add(time.Hour).Unix()})
+	accessToken, _ := generateJWT(user.UserID, user.Email, "user", 1, time.Hour)
+	otherAddress, _ := verificationToken(models.User{UserID: user.UserID, Email: "old@example.com"})
+
+	router := gin.New()
+	router.GET("/auth/verify-email", VerifyEmail)
+	verify := func(token string) int {
+		return serve(router, http.MethodGet, "/auth/verify-email?token="+url.QueryEscape(token), nil).Code
+	}
+
+	for name, token := range map[string]string{"expired": expired, "MFA challenge": mfaToken, "access token": accessToken, "previous address": otherAddress, "garbage": "not-a-token"} {
+		t.Run(name, func(t *testing.T) {
+			if code := verify(token); code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", code, http.StatusBadRequest)
+			}
+		})
+	}
+	config.DB.First(&user, user.UserID)
+	if user.EmailVerifiedAt != nil {
+		t.Fatal("a rejected token verified the address")
+	}
+
+	if code := verify(valid); code != http.StatusOK {
+		t.Fatalf("valid token: status = %d, want %d", code, http.StatusOK)
+	}
+	config.DB.First(&user, user.UserID)
+	if user.EmailVerifiedAt == nil {
+		t.Error("valid token did not verify the address")
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+	useTestKeys(t)
+
+	verified := models.User{Name: "Ana", Email: "ana@example.com", Password: "x", EmailVerifiedAt: ptr(time.Now())}
+	unverified := models.User{Name: "Budi", Email: "budi@example.com", Password: "x"}
+	testdb.Create(t, &verified, &unverified)
+
+	router := gin.New()
+	router.POST("/auth/resend-verification", ResendVerification)
+
+	tests := []struct {
+		address  string
+		wantSent int
+	}{
+		{"ana@example.com", 0},
+		{"budi@example.com", 1},
+		{"nobody@example.com", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			mailer := useTestMailer(t)
+			recorder := serve(router, http.MethodPost, "/auth/resend-verification", map[string]string{"email": tt.address})
+			if recorder.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusOK)
+			}
+			if got := len(mailer.Messages()); got != tt.wantSent {
+				t.Errorf("sent %d emails, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
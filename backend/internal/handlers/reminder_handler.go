@@ -4,24 +4,71 @@ import (
 	"coachella-backend/config"
 	"coachella-backend/internal/email"
 	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"fmt"
 	"log"
-	"path/filepath"
+	"net/http"
 	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
-// SendEventReminders checks for upcoming events and sends reminders to users
+// reminderCatchUp is how late a reminder may still go out, e.g. after downtime.
+// Older reminders are skipped rather than sent out of context.
+const reminderCatchUp = 6 * time.Hour
+
+// defaultReminderRules apply to events without any configured rules
+var defaultReminderRules = []models.ReminderRule{
+	{OffsetMinutes: 24 * 60, Template: "event_reminder", Enabled: true},
+}
+
+// SendEventReminders sends every reminder that has come due. Each event's
+// rules are measured back from its doors opening, and only holders of Paid
+// tickets are reminded. The reminder log makes repeated runs send nothing new.
 func SendEventReminders() {
+	now := time.Now()
+
+	var events []models.Event
+	result := config.DB.
+		Where("archived_at IS NULL AND start_date >= ?", now.UTC().AddDate(0, 0, -1).Format("2006-01-02")).
+		Find(&events)
+	if result.Error != nil {
+		log.Printf("Error fetching events for reminders: %v\n", result.Error)
+		return
+	}
+
+	for _, event := range events {
+		var rules []models.ReminderRule
+		if err := config.DB.Where("event_id = ?", event.EventID).Find(&rules).Error; err != nil {
+			log.Printf("Error fetching reminder rules for event %d: %v\n", event.EventID, err)
+			continue
+		}
+		if len(rules) == 0 {
+			rules = defaultReminderRules
+		}
+
+		startsAt := event.StartsAt()
+		for _, rule := range rules {
+			dueAt := startsAt.Add(-time.Duration(rule.OffsetMinutes) * time.Minute)
+			if !rule.Enabled || now.Before(dueAt) || now.Sub(dueAt) > reminderCatchUp || !now.Before(startsAt) {
+				continue
+			}
+			sendEventReminder(event, rule)
+		}
+	}
+}
+
+// sendEventReminder emails a rule's reminder to every Paid ticket holder who hasn't had it yet
+func sendEventReminder(event models.Event, rule models.ReminderRule) {
 	var transactions []models.Transaction
 
-	// Find transactions for events happening tomorrow
-	tomorrow := time.Now().Add(24 * time.Hour).Format("2006-01-02")
 	result := config.DB.
 		Preload("User").
 		Preload("Ticket").
-		Preload("Ticket.Event").
 		Joins("JOIN tickets ON tickets.ticket_id = transactions.ticket_id").
-		Joins("JOIN events ON events.event_id = tickets.event_id").
-		Where("events.start_date = ?", tomorrow).
+		Where("tickets.event_id = ? AND transactions.payment_status = ?", event.EventID, "Paid").
+		Where("transactions.transaction_id NOT IN (?)",
+			config.DB.Model(&models.ReminderLog{}).Select("transaction_id").Where("offset_minutes = ?", rule.OffsetMinutes)).
 		Find(&transactions)
 
 	if result.Error != nil {
@@ -30,29 +77,164 @@ func SendEventReminders() {
 	}
 
 	for _, transaction := range transactions {
+		// Claim the reminder first; the unique index stops a concurrent run from sending it too
+		entry := models.ReminderLog{TransactionID: transaction.TransactionID, OffsetMinutes: rule.OffsetMinutes, SentAt: time.Now()}
+		if rule.RuleID != 0 {
+			entry.RuleID = &rule.RuleID
+		}
+		if err := config.DB.Omit("Transaction").Create(&entry).Error; err != nil {
+			continue
+		}
+
 		// Prepare email content
 		templateData := map[string]interface{}{
 			"name":        transaction.User.Name,
-			"event_name":  transaction.Ticket.Event.Name,
-			"event_date":  transaction.Ticket.Event.StartDate.Format("02-01-2006"),
+			"event_name":  event.Name,
+			"event_date":  event.StartDate.Format("02-01-2006"),
 			"ticket_type": transaction.Ticket.Type,
 			"quantity":    transaction.Quantity,
 		}
+		if event.DoorsOpenAt != nil {
+			templateData["doors_open"] = event.DoorsOpenAt.Format("15:04 MST")
+		}
 
-		// Render email template
-		templatePath := filepath.Join("..", "templates", "emails", "event_reminder.html")
-		body, err := email.RenderTemplate(templatePath, templateData)
+		// Notify the holder on the channels they allow; a rule subject replaces the template's
+		err := notify.Send(notify.Message{
+			UserID:  transaction.UserID,
+			Type:    notify.TypeReminder,
+			Content: fmt.Sprintf("Reminder: %s starts on %s.", event.Name, event.StartDate.Format("02-01-2006")),
+			Email:   &notify.Email{Template: rule.Template, Data: templateData, Subject: rule.Subject},
+		})
 		if err != nil {
-			log.Printf("Failed to render reminder email for user %d: %v\n", transaction.UserID, err)
+			// Release the claim so the next run retries
+			config.DB.Delete(&entry)
+			log.Printf("Failed to send reminder to user %d: %v\n", transaction.UserID, err)
 			continue
 		}
+		log.Printf("Reminder sent to user %d for event %s\n", transaction.UserID, event.Name)
+	}
+}
 
-		// Send email
-		err = email.SendEmail(transaction.User.Email, "Event Reminder: "+transaction.Ticket.Event.Name, body)
-		if err != nil {
-			log.Printf("Failed to send reminder email to user %d: %v\n", transaction.UserID, err)
-		} else {
-			log.Printf("Reminder email sent to user %d for event %s\n", transaction.UserID, transaction.Ticket.Event.Name)
-		}
+// GetReminderRules retrieves an event's reminder rules
+// @Summary Retrieve reminder rules
+// @Description Get the reminder rules of an event. Events without rules get a single email one day before doors open.
+// @Tags Reminders
+// @Security BearerAuth
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {array} models.ReminderRule
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/events/{id}/reminder-rules [get]
+func GetReminderRules(c *gin.Context) {
+	var rules []models.ReminderRule
+	if err := config.DB.Where("event_id = ?", c.Param("id")).Order("offset_minutes DESC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateReminderRule adds a reminder rule to an event
+// @Summary Create a reminder rule
+// @Description Schedule a reminder email offset_minutes before the event's doors open, using the named email template
+// @Tags Reminders
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param rule body models.ReminderRule true "Reminder rule"
+// @Success 201 {object} models.ReminderRule
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Router /admin/events/{id}/reminder-rules [post]
+func CreateReminderRule(c *gin.Context) {
+	var event models.Event
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+		return
+	}
+
+	rule := models.ReminderRule{Enabled: true}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	rule.RuleID = 0
+	rule.EventID = event.EventID
+	if message := validateReminderRule(rule); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	if err := config.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateReminderRule updates a reminder rule
+// @Summary Update a reminder rule
+// @Description Change a rule's offset, template, subject or enabled state
+// @Tags Reminders
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Rule ID"
+// @Param rule body models.ReminderRule true "Updated reminder rule"
+// @Success 200 {object} models.ReminderRule
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Rule not found"
+// @Router /admin/reminder-rules/{id} [put]
+func UpdateReminderRule(c *gin.Context) {
+	var rule models.ReminderRule
+	if err := config.DB.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Rule not found"})
+		return
+	}
+
+	ruleID, eventID := rule.RuleID, rule.EventID
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	rule.RuleID, rule.EventID = ruleID, eventID
+	if message := validateReminderRule(rule); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	if err := config.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteReminderRule deletes a reminder rule
+// @Summary Delete a reminder rule
+// @Description Remove a reminder rule. Reminders already sent stay in the log.
+// @Tags Reminders
+// @Security BearerAuth
+// @Param id path int true "Rule ID"
+// @Success 200 {object} models.GenericResponse "Rule deleted successfully"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/reminder-rules/{id} [delete]
+func DeleteReminderRule(c *gin.Context) {
+	if err := config.DB.Delete(&models.ReminderRule{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Rule deleted successfully"})
+}
+
+// validateReminderRule returns a message describing the first invalid field, if any
+func validateReminderRule(rule models.ReminderRule) string {
+	if rule.OffsetMinutes <= 0 {
+		return "offset_minutes must be positive"
+	}
+	if !email.HasTemplate(rule.Template) {
+		return "template " + rule.Template + " does not exist"
 	}
+	return ""
 }
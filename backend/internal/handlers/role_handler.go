@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/rbac"
+	"errors"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+var errLastSuperAdmin = errors.New("the last super_admin cannot be removed")
+
+// GetPermissions lists every permission
+// @Summary List permissions
+// @Description Get every permission roles can grant
+// @Tags Roles
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Permission
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/permissions [get]
+func GetPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	if err := config.DB.Order("name").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, permissions)
+}
+
+// GetRoles lists every role and its permissions
+// @Summary List roles
+// @Description Get every role with the permissions it grants
+// @Tags Roles
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Role
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/roles [get]
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("account_type, name").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole creates a custom role
+// @Summary Create a role
+// @Description Create a role granting a set of permissions to user or admin accounts
+// @Tags Roles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param role body models.RoleRequest true "Role"
+// @Success 201 {object} models.Role
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 409 {object} models.GenericResponse "Role name taken"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/roles [post]
+func CreateRole(c *gin.Context) {
+	var request models.RoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+	if !roleNamePattern.MatchString(request.Name) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "name must be lowercase letters, digits and underscores"})
+		return
+	}
+	if request.AccountType != "user" && request.AccountType != "admin" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "account_type must be user or admin"})
+		return
+	}
+	permissions, ok := findPermissions(c, request.Permissions)
+	if !ok {
+		return
+	}
+
+	role := models.Role{Name: request.Name, AccountType: request.AccountType, Description: request.Description, Permissions: permissions}
+	if err := config.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "A role named " + request.Name + " already exists"})
+		return
+	}
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes a role's description and permissions
+// @Summary Update a role
+// @Description Replace a role's description and permissions. The name and account type are fixed, and super_admin always holds every permission.
+// @Tags Roles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param role body models.RoleRequest true "Description and permissions"
+// @Success 200 {object} models.Role
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Role not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/roles/{id} [put]
+func UpdateRole(c *gin.Context) {
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Role not found"})
+		return
+	}
+	if role.Name == rbac.SuperAdmin {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "super_admin always holds every permission"})
+		return
+	}
+
+	var request models.RoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+	permissions, ok := findPermissions(c, request.Permissions)
+	if !ok {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Update("description", request.Description).Error; err != nil {
+			return err
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	role.Permissions = permissions
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role
+// @Summary Delete a role
+// @Description Delete a custom role, removing it from every account. Built-in roles can't be deleted.
+// @Tags Roles
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Built-in role"
+// @Failure 404 {object} models.GenericResponse "Role not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/roles/{id} [delete]
+func DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Role not found"})
+		return
+	}
+	if role.BuiltIn {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Built-in roles can't be deleted"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Role deleted"})
+}
+
+// GetAccountRoles lists the roles assigned to an account
+// @Summary List an account's roles
+// @Description Get the roles assigned to a user or admin. Users also always hold the customer role.
+// @Tags Roles
+// @Security BearerAuth
+// @Param type path string true "Account type (user or admin)"
+// @Param id path int true "Account ID"
+// @Produce json
+// @Success 200 {array} models.AccountRole
+// @Failure 404 {object} models.GenericResponse "Account not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/accounts/{type}/{id}/roles [get]
+func GetAccountRoles(c *gin.Context) {
+	account, ok := findPathAccount(c)
+	if !ok {
+		return
+	}
+
+	var assignments []models.AccountRole
+	err := config.DB.Preload("Role").
+		Where("account_type = ? AND account_id = ?", account.Type, account.ID).
+		Find(&assignments).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assignments)
+}
+
+// AssignRole gives an account a role
+// @Summary Assign a role
+// @Description Give a user or admin a role meant for its account type
+// @Tags Roles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param type path string true "Account type (user or admin)"
+// @Param id path int true "Account ID"
+// @Param role body models.AssignRoleRequest true "Role name"
+// @Success 201 {object} models.AccountRole
+// @Failure 400 {object} models.GenericResponse "Role doesn't apply to the account type"
+// @Failure 404 {object} models.GenericResponse "Account or role not found"
+// @Failure 409 {object} models.GenericResponse "Already assigned"
+// @Router /admin/accounts/{type}/{id}/roles [post]
+func AssignRole(c *gin.Context) {
+	account, ok := findPathAccount(c)
+	if !ok {
+		return
+	}
+	var request models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.Where("name = ?", request.Role).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Role not found"})
+		return
+	}
+	if role.AccountType != account.Type {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Role " + role.Name + " is for " + role.AccountType + " accounts"})
+		return
+	}
+
+	assignment := models.AccountRole{AccountType: account.Type, AccountID: account.ID, RoleID: role.RoleID, Role: role}
+	if err := config.DB.Omit("Role").Create(&assignment).Error; err != nil {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Role already assigned"})
+		return
+	}
+	c.JSON(http.StatusCreated, assignment)
+}
+
+// RemoveRole takes a role away from an account
+// @Summary Remove a role
+// @Description Take a role away from a user or admin. The last super_admin can't be removed.
+// @Tags Roles
+// @Security BearerAuth
+// @Param type path string true "Account type (user or admin)"
+// @Param id path int true "Account ID"
+// @Param role_id path int true "Role ID"
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Last super_admin"
+// @Failure 404 {object} models.GenericResponse "Assignment not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/accounts/{type}/{id}/roles/{role_id} [delete]
+func RemoveRole(c *gin.Context) {
+	account, ok := findPathAccount(c)
+	if !ok {
+		return
+	}
+
+	var assignment models.AccountRole
+	err := config.DB.Preload("Role").
+		Where("account_type = ? AND account_id = ? AND role_id = ?", account.Type, account.ID, c.Param("role_id")).
+		First(&assignment).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Role not assigned to the account"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&assignment).Error; err != nil {
+			return err
+		}
+		if assignment.Role.Name != rbac.SuperAdmin {
+			return nil
+		}
+		var remaining int64
+		if err := tx.Model(&models.AccountRole{}).Where("role_id = ?", assignment.RoleID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return errLastSuperAdmin
+		}
+		return nil
+	})
+	if errors.Is(err, errLastSuperAdmin) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Role removed"})
+}
+
+// CreateStaff creates a staff account with roles
+// @Summary Create a staff account
+// @Description Create an admin account holding the given staff roles, e.g. gate_staff for a ticket scanner
+// @Tags Roles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param staff body models.StaffRequest true "Staff account"
+// @Success 201 {object} models.Admin
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 409 {object} models.GenericResponse "Email already registered"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/staff [post]
+func CreateStaff(c *gin.Context) {
+	var request models.StaffRequest
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.Roles) == 0 {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+	address, err := mail.ParseAddress(strings.TrimSpace(request.Email))
+	if err != nil || address.Name != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid email address"})
+		return
+	}
+	if message := validatePassword(request.Password); message != "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: message})
+		return
+	}
+
+	var roles []models.Role
+	if err := config.DB.Where("name IN ? AND account_type = ?", request.Roles, "admin").Find(&roles).Error; err != nil || len(roles) != len(request.Roles) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "roles must be existing staff roles"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to hash password"})
+		return
+	}
+	admin := models.Admin{Name: request.Name, Email: address.Address, Password: string(hash)}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := tx.Create(&models.AccountRole{AccountType: "admin", AccountID: admin.AdminID, RoleID: role.RoleID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Email already registered"})
+		return
+	}
+	c.JSON(http.StatusCreated, admin)
+}
+
+// findPermissions loads permissions by name, responding itself if any is unknown
+func findPermissions(c *gin.Context, names []string) ([]models.Permission, bool) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, true
+	}
+	if err := config.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return nil, false
+	}
+	if len(permissions) != len(names) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Unknown permission; see /admin/permissions"})
+		return nil, false
+	}
+	return permissions, true
+}
+
+// findPathAccount loads the account named by the type and id path parameters
+func findPathAccount(c *gin.Context) (account, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	accountType := c.Param("type")
+	if err != nil || (accountType != "user" && accountType != "admin") {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Account not found"})
+		return account{}, false
+	}
+	account, err := findAccount(accountType, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Account not found"})
+		return account, false
+	}
+	return account, true
+}
+
+// findPathUser loads the user named by the id path parameter, responding 404 itself
+func findPathUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || config.DB.First(&user, id).Error != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "User not found"})
+		return user, false
+	}
+	return user, true
+}
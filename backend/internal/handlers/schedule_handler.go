@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"bytes"
+	"coachella-backend/config"
+	"coachella-backend/internal/calendar"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// favoriteReminderLead is how long before a favorited set its reminder is sent
+const favoriteReminderLead = 30 * time.Minute
+
+// GetFavorites retrieves the authenticated user's favorited performances
+// @Summary Retrieve favorites
+// @Description Get the performances the authenticated user has favorited
+// @Tags Personal Schedule
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Favorite
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/favorites [get]
+func GetFavorites(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var favorites []models.Favorite
+	if err := config.DB.Where("user_id = ?", userID).Find(&favorites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, favorites)
+}
+
+// AddFavorite adds a performance to the authenticated user's schedule
+// @Summary Favorite a performance
+// @Description Add a published performance to the personal schedule. Only ticket holders of the event can favorite its sets.
+// @Tags Personal Schedule
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param favorite body models.FavoriteRequest true "Performance to favorite"
+// @Success 201 {object} models.Favorite
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 403 {object} models.GenericResponse "No ticket for this event"
+// @Failure 404 {object} models.GenericResponse "Performance not found"
+// @Failure 409 {object} models.GenericResponse "Already favorited"
+// @Router /user/favorites [post]
+func AddFavorite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var request models.FavoriteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	var performance models.Performance
+	err := config.DB.Where("published_start_time IS NOT NULL").First(&performance, request.PerformanceID).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Performance not found"})
+		return
+	}
+	if !userHoldsEventTicket(userID, performance.EventID) {
+		c.JSON(http.StatusForbidden, models.GenericResponse{Error: "You need a ticket for this event to build a schedule"})
+		return
+	}
+
+	favorite := models.Favorite{UserID: userID, PerformanceID: performance.PerformanceID, Remind: request.Remind}
+	if err := config.DB.Omit("User", "Performance").Create(&favorite).Error; err != nil {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Performance is already in your schedule"})
+		return
+	}
+	c.JSON(http.StatusCreated, favorite)
+}
+
+// UpdateFavorite changes the reminder setting of a favorite
+// @Summary Update a favorite
+// @Description Turn the "starting in 30 minutes" notification on or off
+// @Tags Personal Schedule
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Favorite ID"
+// @Param favorite body models.FavoriteUpdateRequest true "Reminder setting"
+// @Success 200 {object} models.Favorite
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Favorite not found"
+// @Router /user/favorites/{id} [patch]
+func UpdateFavorite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var favorite models.Favorite
+	if err := config.DB.Where("user_id = ?", userID).First(&favorite, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Favorite not found"})
+		return
+	}
+
+	var request models.FavoriteUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	favorite.Remind = request.Remind
+	config.DB.Model(&favorite).Update("remind", favorite.Remind)
+	c.JSON(http.StatusOK, favorite)
+}
+
+// RemoveFavorite removes a performance from the authenticated user's schedule
+// @Summary Remove a favorite
+// @Description Remove a performance from the personal schedule
+// @Tags Personal Schedule
+// @Security BearerAuth
+// @Param id path int true "Favorite ID"
+// @Success 200 {object} models.GenericResponse "Favorite removed"
+// @Failure 404 {object} models.GenericResponse "Favorite not found"
+// @Router /user/favorites/{id} [delete]
+func RemoveFavorite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	result := config.DB.Where("user_id = ?", userID).Delete(&models.Favorite{}, c.Param("id"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Favorite not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Favorite removed"})
+}
+
+// GetPersonalSchedule retrieves the authenticated user's timeline for an event
+// @Summary Retrieve personal schedule
+// @Description Get favorited sets per day, overlapping sets (clashes) and alternative set times that avoid them
+// @Tags Personal Schedule
+// @Security BearerAuth
+// @Param event_id query int true "Event ID"
+// @Produce json
+// @Success 200 {object} models.PersonalSchedule
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/schedule [get]
+func GetPersonalSchedule(c *gin.Context) {
+	schedule, ok := loadPersonalSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// ExportPersonalSchedule downloads the authenticated user's timeline for an event
+// @Summary Export personal schedule
+// @Description Download the personal schedule of an event as CSV or as an iCalendar file in the venue's time zone
+// @Tags Personal Schedule
+// @Security BearerAuth
+// @Param event_id query int true "Event ID"
+// @Param format query string false "csv (default) or ics"
+// @Produce text/csv,text/calendar
+// @Success 200 {file} file "Personal schedule"
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/schedule/export [get]
+func ExportPersonalSchedule(c *gin.Context) {
+	schedule, ok := loadPersonalSchedule(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("format") == "ics" {
+		var event models.Event
+		if err := config.DB.First(&event, schedule.EventID).Error; err != nil {
+			c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Event not found"})
+			return
+		}
+
+		cal := calendar.Calendar{Name: event.Name + " - My Schedule", Location: event.Location()}
+		for _, day := range schedule.Days {
+			for _, entry := range day.Performances {
+				cal.Events = append(cal.Events, calendar.Event{
+					UID:      fmt.Sprintf("performance-%d@coachella.com", entry.PerformanceID),
+					Summary:  entry.ArtistName,
+					Place:    entry.StageName + ", " + event.Name,
+					Start:    entry.StartTime,
+					End:      entry.EndTime,
+					Location: event.Location(),
+				})
+			}
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=schedule-event-%d.ics", schedule.EventID))
+		c.Data(http.StatusOK, calendar.ContentType, cal.Bytes())
+		return
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write([]string{"Day", "Start", "End", "Artist", "Stage", "Clash"})
+	for _, day := range schedule.Days {
+		for _, entry := range day.Performances {
+			writer.Write([]string{
+				day.Day,
+				entry.StartTime.Format("15:04"),
+				entry.EndTime.Format("15:04"),
+				entry.ArtistName,
+				entry.StageName,
+				strconv.FormatBool(entry.Clash),
+			})
+		}
+	}
+	writer.Flush()
+
+	filename := fmt.Sprintf("schedule-event-%d.csv", schedule.EventID)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buffer.Bytes())
+}
+
+// SendFavoriteReminders notifies users whose favorited sets start soon
+func SendFavoriteReminders() {
+	var favorites []models.Favorite
+	now := time.Now()
+	result := config.DB.
+		Preload("Performance").
+		Preload("Performance.Artist").
+		Joins("JOIN performances ON performances.performance_id = favorites.performance_id").
+		Where("favorites.remind = ? AND favorites.reminder_sent_at IS NULL", true).
+		Where("performances.published_start_time > ? AND performances.published_start_time <= ?", now, now.Add(favoriteReminderLead)).
+		Find(&favorites)
+	if result.Error != nil {
+		log.Printf("Error fetching favorites for reminders: %v\n", result.Error)
+		return
+	}
+
+	for _, favorite := range favorites {
+		performance := favorite.Performance
+		var stage models.Stage
+		config.DB.First(&stage, *performance.PublishedStageID)
+		minutes := int(performance.PublishedStartTime.Sub(now).Round(time.Minute).Minutes())
+		err := notify.Send(notify.Message{
+			UserID:  favorite.UserID,
+			Type:    notify.TypeReminder,
+			Content: fmt.Sprintf("%s starts in %d minutes at %s.", performance.Artist.Name, minutes, stage.Name),
+		})
+		if err != nil {
+			log.Printf("Failed to send set reminder to user %d: %v\n", favorite.UserID, err)
+			continue
+		}
+		config.DB.Model(&favorite).Update("reminder_sent_at", now)
+	}
+}
+
+// loadPersonalSchedule builds the schedule for the event_id query parameter,
+// writing an error response and returning false on failure
+func loadPersonalSchedule(c *gin.Context) (models.PersonalSchedule, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return models.PersonalSchedule{}, false
+	}
+	eventID, err := strconv.ParseUint(c.Query("event_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "event_id is required"})
+		return models.PersonalSchedule{}, false
+	}
+
+	schedule, err := buildPersonalSchedule(userID, uint(eventID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return models.PersonalSchedule{}, false
+	}
+	return schedule, true
+}
+
+// buildPersonalSchedule lays out a user's favorited sets for an event by day,
+// flags overlapping sets and suggests other set times by the same artists
+func buildPersonalSchedule(userID, eventID uint) (models.PersonalSchedule, error) {
+	schedule := models.PersonalSchedule{EventID: eventID, Days: []models.PersonalScheduleDay{}, Clashes: []models.ScheduleClash{}}
+
+	var favorites []models.Favorite
+	err := config.DB.
+		Preload("Performance").
+		Preload("Performance.Artist").
+		Joins("JOIN performances ON performances.performance_id = favorites.performance_id").
+		Where("favorites.user_id = ? AND performances.event_id = ? AND performances.published_start_time IS NOT NULL", userID, eventID).
+		Find(&favorites).Error
+	if err != nil {
+		return schedule, err
+	}
+	stages, err := eventStages(eventID)
+	if err != nil {
+		return schedule, err
+	}
+	var event models.Event
+	if err := config.DB.First(&event, eventID).Error; err != nil {
+		return schedule, err
+	}
+	loc := event.Location()
+
+	entries := make([]models.PersonalScheduleEntry, len(favorites))
+	favorited := make(map[uint]bool, len(favorites))
+	for i, favorite := range favorites {
+		entries[i] = scheduleEntry(favorite.Performance, stages, loc)
+		entries[i].FavoriteID = favorite.FavoriteID
+		entries[i].Remind = favorite.Remind
+		favorited[favorite.PerformanceID] = true
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StartTime.Before(entries[j].StartTime) })
+
+	for i := range entries {
+		for j := i + 1; j < len(entries) && entries[j].StartTime.Before(entries[i].EndTime); j++ {
+			entries[i].Clash, entries[j].Clash = true, true
+			overlapEnd := entries[i].EndTime
+			if entries[j].EndTime.Before(overlapEnd) {
+				overlapEnd = entries[j].EndTime
+			}
+			alternatives, err := clashAlternatives(eventID, entries, i, j, favorited, stages, loc)
+			if err != nil {
+				return schedule, err
+			}
+			schedule.Clashes = append(schedule.Clashes, models.ScheduleClash{
+				PerformanceIDs: []uint{entries[i].PerformanceID, entries[j].PerformanceID},
+				OverlapMinutes: int(overlapEnd.Sub(entries[j].StartTime).Minutes()),
+				Alternatives:   alternatives,
+			})
+		}
+	}
+
+	for _, entry := range entries {
+		day := entry.StartTime.Format("2006-01-02")
+		if n := len(schedule.Days); n == 0 || schedule.Days[n-1].Day != day {
+			schedule.Days = append(schedule.Days, models.PersonalScheduleDay{Day: day})
+		}
+		last := &schedule.Days[len(schedule.Days)-1]
+		last.Performances = append(last.Performances, entry)
+	}
+	return schedule, nil
+}
+
+// clashAlternatives finds other published sets by the artists of the clashing
+// entries i and j that don't overlap the rest of the personal schedule
+func clashAlternatives(eventID uint, entries []models.PersonalScheduleEntry, i, j int, favorited map[uint]bool, stages map[uint]models.Stage, loc *time.Location) ([]models.PersonalScheduleEntry, error) {
+	var performances []models.Performance
+	err := config.DB.Preload("Artist").
+		Where("event_id = ? AND published_start_time IS NOT NULL", eventID).
+		Where("artist_id IN ?", []uint{entries[i].ArtistID, entries[j].ArtistID}).
+		Order("published_start_time").
+		Find(&performances).Error
+	if err != nil {
+		return nil, err
+	}
+
+	alternatives := []models.PersonalScheduleEntry{}
+	for _, performance := range performances {
+		if favorited[performance.PerformanceID] {
+			continue
+		}
+		candidate := scheduleEntry(performance, stages, loc)
+		fits := true
+		for k, entry := range entries {
+			// The alternative replaces the clashing set by the same artist
+			if (k == i || k == j) && entry.ArtistID == candidate.ArtistID {
+				continue
+			}
+			if candidate.StartTime.Before(entry.EndTime) && entry.StartTime.Before(candidate.EndTime) {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			alternatives = append(alternatives, candidate)
+		}
+	}
+	return alternatives, nil
+}
+
+// scheduleEntry converts a published performance into a timeline entry in the venue's time zone
+func scheduleEntry(performance models.Performance, stages map[uint]models.Stage, loc *time.Location) models.PersonalScheduleEntry {
+	stageID := *performance.PublishedStageID
+	return models.PersonalScheduleEntry{
+		PerformanceID: performance.PerformanceID,
+		ArtistID:      performance.ArtistID,
+		ArtistName:    performance.Artist.Name,
+		StageID:       stageID,
+		StageName:     stages[stageID].Name,
+		StartTime:     performance.PublishedStartTime.In(loc),
+		EndTime:       performance.PublishedEndTime.In(loc),
+	}
+}
+
+// userHoldsEventTicket reports whether the user has a Paid ticket for the event
+func userHoldsEventTicket(userID, eventID uint) bool {
+	var count int64
+	config.DB.Model(&models.Transaction{}).
+		Joins("JOIN tickets ON tickets.ticket_id = transactions.ticket_id").
+		Where("transactions.user_id = ? AND tickets.event_id = ? AND transactions.payment_status = ?", userID, eventID, "Paid").
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// transactionTimeout is how long a pending purchase, and the seats held for it, stay reserved
+const transactionTimeout = 15 * time.Minute
+
+// CreateVenueSection adds a seated section to an event's venue layout
+// @Summary Create a venue section
+// @Description Add a section with its rows and seats. Each seat references the Ticket that sets its price category.
+// @Tags Seating
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param section body models.VenueSection true "Section with seats"
+// @Success 201 {object} models.VenueSection
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/venue-sections [post]
+func CreateVenueSection(c *gin.Context) {
+	var section models.VenueSection
+	if err := c.ShouldBindJSON(&section); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	// Every seat must be priced by a ticket of the same event
+	for _, seat := range section.Seats {
+		var ticket models.Ticket
+		if err := config.DB.Where("event_id = ?", section.EventID).First(&ticket, seat.TicketID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Seat price category must be a ticket of the same event"})
+			return
+		}
+	}
+
+	if err := config.DB.Create(&section).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, section)
+}
+
+// DeleteVenueSection removes a section and its seats
+// @Summary Delete a venue section
+// @Description Remove a section and all of its seats from the layout
+// @Tags Seating
+// @Security BearerAuth
+// @Param id path int true "Section ID"
+// @Success 200 {object} models.GenericResponse "Section deleted successfully"
+// @Failure 409 {object} models.GenericResponse "Section has held or sold seats"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/venue-sections/{id} [delete]
+func DeleteVenueSection(c *gin.Context) {
+	id := c.Param("id")
+
+	var held int64
+	config.DB.Model(&models.SeatHold{}).
+		Where("seat_id IN (?)", config.DB.Model(&models.Seat{}).Select("seat_id").Where("section_id = ?", id)).
+		Count(&held)
+	if held > 0 {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Section has held or sold seats"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("section_id = ?", id).Delete(&models.Seat{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.VenueSection{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Section deleted successfully"})
+}
+
+// GetSeatMap retrieves the seat availability map of an event
+// @Summary Retrieve seat map
+// @Description Get every section and seat of an event with its price category and status (available, held or sold)
+// @Tags Seating
+// @Param id path int true "Event ID"
+// @Produce json
+// @Success 200 {array} models.SeatMapSection
+// @Failure 404 {object} models.GenericResponse "Event not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /events/{id}/seat-map [get]
+func GetSeatMap(c *gin.Context) {
+	event, ok := findPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	var sections []models.VenueSection
+	err := config.DB.
+		Preload("Seats", func(db *gorm.DB) *gorm.DB { return db.Order("`row`, number") }).
+		Preload("Seats.Ticket").
+		Where("event_id = ?", event.EventID).
+		Order("name").
+		Find(&sections).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	// Load the live holds for the event's seats in one query
+	var seatIDs []uint
+	for _, section := range sections {
+		for _, seat := range section.Seats {
+			seatIDs = append(seatIDs, seat.SeatID)
+		}
+	}
+	var holds []models.SeatHold
+	if len(seatIDs) > 0 {
+		config.DB.Where("seat_id IN ? AND (transaction_id IS NOT NULL OR expires_at > ?)", seatIDs, time.Now()).Find(&holds)
+	}
+	status := make(map[uint]string, len(holds))
+	for _, hold := range holds {
+		if hold.TransactionID != nil {
+			status[hold.SeatID] = "sold"
+		} else {
+			status[hold.SeatID] = "held"
+		}
+	}
+
+	seatMap := make([]models.SeatMapSection, 0, len(sections))
+	for _, section := range sections {
+		mapSection := models.SeatMapSection{SectionID: section.SectionID, Name: section.Name, Seats: []models.SeatMapSeat{}}
+		for _, seat := range section.Seats {
+			seatStatus, ok := status[seat.SeatID]
+			if !ok {
+				seatStatus = "available"
+			}
+			mapSection.Seats = append(mapSection.Seats, models.SeatMapSeat{
+				SeatID:        seat.SeatID,
+				Row:           seat.Row,
+				Number:        seat.Number,
+				TicketID:      seat.TicketID,
+				PriceCategory: seat.Ticket.Type,
+				Price:         seat.Ticket.Price,
+				Status:        seatStatus,
+			})
+		}
+		seatMap = append(seatMap, mapSection)
+	}
+
+	c.JSON(http.StatusOK, seatMap)
+}
+
+// HoldSeats locks seats for the authenticated user
+// @Summary Hold seats
+// @Description Lock specific seats for the length of the transaction timeout. Seats held or sold to someone else cannot be held.
+// @Tags Seating
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.SeatHoldRequest true "Seats to hold"
+// @Success 201 {array} models.SeatHold
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 409 {object} models.GenericResponse "Seat unavailable"
+// @Router /user/seat-holds [post]
+func HoldSeats(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var request models.SeatHoldRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.Seat{}).Where("seat_id IN ?", request.SeatIDs).Count(&count)
+	if int(count) != len(request.SeatIDs) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "One or more seats do not exist"})
+		return
+	}
+
+	now := time.Now()
+	holds := make([]models.SeatHold, len(request.SeatIDs))
+	for i, seatID := range request.SeatIDs {
+		holds[i] = models.SeatHold{SeatID: seatID, UserID: userID, ExpiresAt: now.Add(transactionTimeout)}
+	}
+
+	// Lapsed holds are cleared first; the unique index on seat_id then makes
+	// a concurrent hold on the same seat fail instead of double-booking it
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("seat_id IN ? AND transaction_id IS NULL AND expires_at <= ?", request.SeatIDs, now).
+			Delete(&models.SeatHold{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&holds).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "One or more seats are no longer available"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, holds)
+}
+
+// ReleaseSeatHold releases a seat the authenticated user is holding
+// @Summary Release a seat hold
+// @Description Release a hold that has not been bound to a transaction
+// @Tags Seating
+// @Security BearerAuth
+// @Param id path int true "Hold ID"
+// @Success 200 {object} models.GenericResponse "Seat released"
+// @Failure 404 {object} models.GenericResponse "Hold not found"
+// @Router /user/seat-holds/{id} [delete]
+func ReleaseSeatHold(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	result := config.DB.Where("user_id = ? AND transaction_id IS NULL", userID).Delete(&models.SeatHold{}, c.Param("id"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Hold not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Seat released"})
+}
+
+// bindSeatHolds attaches the user's live holds on seatIDs to a transaction,
+// keeping them until the transaction times out. Every seat must be held by
+// the user and priced by the purchased ticket.
+func bindSeatHolds(tx *gorm.DB, transaction models.Transaction, seatIDs []uint) bool {
+	var count int64
+	tx.Model(&models.Seat{}).Where("seat_id IN ? AND ticket_id = ?", seatIDs, transaction.TicketID).Count(&count)
+	if int(count) != len(seatIDs) {
+		return false
+	}
+
+	result := tx.Model(&models.SeatHold{}).
+		Where("seat_id IN ? AND user_id = ? AND transaction_id IS NULL AND expires_at > ?", seatIDs, transaction.UserID, time.Now()).
+		Updates(map[string]interface{}{
+			"transaction_id": transaction.TransactionID,
+			"expires_at":     transaction.Timeout,
+		})
+	return result.Error == nil && int(result.RowsAffected) == len(seatIDs)
+}
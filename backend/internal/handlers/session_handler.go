@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// accessTokenTTL is the lifetime of access tokens (ACCESS_TOKEN_TTL, default 15m)
+func accessTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(envOrDefault("ACCESS_TOKEN_TTL", "15m"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// refreshTokenTTL is how long a session lasts without being refreshed
+// (REFRESH_TOKEN_TTL, default 720h)
+func refreshTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(envOrDefault("REFRESH_TOKEN_TTL", "720h"))
+	if err != nil || ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return ttl
+}
+
+// RefreshSession exchanges a refresh token for a new access and refresh token
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token works once: presenting a used one again revokes its session, since the token must have been stolen.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.GenericResponse "Invalid input"
+// @Failure 401 {object} models.GenericResponse "Invalid, expired or reused refresh token"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /auth/refresh [post]
+func RefreshSession(c *gin.Context) {
+	var request models.RefreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	now := time.Now()
+	var refresh models.RefreshToken
+	if err := config.DB.Preload("Session").Where("token_hash = ?", hashToken(request.RefreshToken)).First(&refresh).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid refresh token"})
+		return
+	}
+	session := refresh.Session
+	if session.RevokedAt != nil || !now.Before(session.ExpiresAt) || !now.Before(refresh.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Session expired, please log in again"})
+		return
+	}
+
+	// Use the token up; if it already was, whoever holds the newer token may be an attacker
+	result := config.DB.Model(&refresh).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		config.DB.Model(&session).Where("revoked_at IS NULL").
+			Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": "refresh_reuse"})
+		log.Printf("Refresh token reuse on session %d; session revoked\n", session.SessionID)
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Refresh token already used; the session has been revoked"})
+		return
+	}
+
+	account, err := findAccount(session.AccountType, session.AccountID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Account not found"})
+		return
+	}
+	// Sessions from before two-factor authentication became required can't be extended
+	if mfaRequired(account.Type) {
+		if factor, err := findMFAFactor(account); err != nil || factor.ConfirmedAt == nil {
+			c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Two-factor authentication is required; please log in again"})
+			return
+		}
+	}
+
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(refreshTokenTTL())
+	if err := config.DB.Model(&session).Select("last_used_at", "expires_at").Updates(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	tokens, err := issueTokens(session, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to issue tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the caller's session
+// @Summary Logout
+// @Description Revoke the session of the access token, along with its refresh token
+// @Tags Authentication
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	sessionID, ok := currentSessionID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	err := config.DB.Model(&models.Session{}).Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": "logout"}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Logged out"})
+}
+
+// LogoutAll revokes every session of the caller's account
+// @Summary Logout from all devices
+// @Description Revoke every session of the logged-in account, including the current one
+// @Tags Authentication
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Router /auth/logout-all [post]
+func LogoutAll(c *gin.Context) {
+	accountType, accountID, ok := currentAccount(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	if err := revokeSessions(config.DB, accountType, accountID, "logout_all"); err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Logged out from all devices"})
+}
+
+// GetSessions lists the caller's active sessions
+// @Summary List my sessions
+// @Description Get the devices the logged-in account is signed in on, most recently used first
+// @Tags Authentication
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Session
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/sessions [get]
+// @Router /admin/sessions [get]
+func GetSessions(c *gin.Context) {
+	accountType, accountID, ok := currentAccount(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	var sessions []models.Session
+	err := config.DB.
+		Where("account_type = ? AND account_id = ? AND revoked_at IS NULL AND expires_at > ?", accountType, accountID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+
+	currentID, _ := currentSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SessionID == currentID
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession signs one of the caller's sessions out
+// @Summary Revoke a session
+// @Description Sign the logged-in account out of one device
+// @Tags Authentication
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Produce json
+// @Success 200 {object} models.GenericResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 404 {object} models.GenericResponse "Session not found"
+// @Router /user/sessions/{id} [delete]
+// @Router /admin/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	accountType, accountID, ok := currentAccount(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	result := config.DB.Model(&models.Session{}).
+		Where("session_id = ? AND account_type = ? AND account_id = ? AND revoked_at IS NULL", c.Param("id"), accountType, accountID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": "revoked"})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Session revoked"})
+}
+
+// startSession opens a session for an account that has just authenticated
+func startSession(c *gin.Context, account account) (models.TokenResponse, error) {
+	now := time.Now()
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := models.Session{
+		AccountType: account.Type,
+		AccountID:   account.ID,
+		UserAgent:   userAgent,
+		IPAddress:   c.ClientIP(),
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(refreshTokenTTL()),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		return models.TokenResponse{}, err
+	}
+	return issueTokens(session, account)
+}
+
+// issueTokens creates a session's next refresh token and an access token naming the session
+func issueTokens(session models.Session, account account) (models.TokenResponse, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	refresh := models.RefreshToken{
+		SessionID: session.SessionID,
+		TokenHash: hashToken(token),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := config.DB.Create(&refresh).Error; err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	ttl := accessTokenTTL()
+	accessToken, err := generateJWT(account.ID, account.Email, account.Type, session.SessionID, ttl)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	return models.TokenResponse{
+		Token:        accessToken,
+		ExpiresIn:    int(ttl.Seconds()),
+		RefreshToken: token,
+	}, nil
+}
+
+// revokeSessions revokes every active session of an account
+func revokeSessions(db *gorm.DB, accountType string, accountID uint, reason string) error {
+	return db.Model(&models.Session{}).
+		Where("account_type = ? AND account_id = ? AND revoked_at IS NULL", accountType, accountID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
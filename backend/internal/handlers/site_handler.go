@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetSites retrieves the site inventory
+// @Summary Retrieve sites
+// @Description Get camping and accommodation sites, optionally filtered by event, category, zone and availability. Sites of archived events are left out.
+// @Tags Sites
+// @Param event_id query int false "Event ID"
+// @Param category query string false "Site category"
+// @Param zone query string false "Zone"
+// @Param available query bool false "Only return sites that are not allocated"
+// @Produce json
+// @Success 200 {array} models.Site
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /sites [get]
+func GetSites(c *gin.Context) {
+	query := config.DB.Model(&models.Site{}).Where("event_id IN (?)", publicEventIDs())
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if zone := c.Query("zone"); zone != "" {
+		query = query.Where("zone = ?", zone)
+	}
+	if c.Query("available") == "true" {
+		query = query.Where("site_id NOT IN (?)", config.DB.Model(&models.SiteAllocation{}).Select("site_id"))
+	}
+
+	var sites []models.Site
+	if err := query.Order("zone, site_number").Find(&sites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sites)
+}
+
+// CreateSite adds a site to the inventory
+// @Summary Create a site
+// @Description Add a camping or accommodation site to an event's inventory
+// @Tags Sites
+// @Accept json
+// @Produce json
+// @Param site body models.Site true "Site details"
+// @Success 201 {object} models.Site
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/sites [post]
+func CreateSite(c *gin.Context) {
+	var site models.Site
+	if err := c.ShouldBindJSON(&site); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	if err := config.DB.Create(&site).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, site)
+}
+
+// UpdateSite updates an existing site
+// @Summary Update a site
+// @Description Modify the zone, number, capacity or attributes of a site
+// @Tags Sites
+// @Accept json
+// @Produce json
+// @Param id path int true "Site ID"
+// @Param site body models.Site true "Updated site details"
+// @Success 200 {object} models.Site
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Site not found"
+// @Router /admin/sites/{id} [put]
+func UpdateSite(c *gin.Context) {
+	var site models.Site
+	if err := config.DB.First(&site, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Site not found"})
+		return
+	}
+	siteID := site.SiteID
+	if err := c.ShouldBindJSON(&site); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	// The ID can't be changed through the payload
+	site.SiteID = siteID
+	if err := config.DB.Save(&site).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, site)
+}
+
+// DeleteSite removes a site from the inventory
+// @Summary Delete a site
+// @Description Remove a site that has not been allocated
+// @Tags Sites
+// @Param id path int true "Site ID"
+// @Success 200 {object} models.GenericResponse "Site deleted successfully"
+// @Failure 409 {object} models.GenericResponse "Site is allocated"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/sites/{id} [delete]
+func DeleteSite(c *gin.Context) {
+	id := c.Param("id")
+
+	var allocated int64
+	config.DB.Model(&models.SiteAllocation{}).Where("site_id = ?", id).Count(&allocated)
+	if allocated > 0 {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Site is allocated, reassign it before deleting"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.Site{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.GenericResponse{Message: "Site deleted successfully"})
+}
+
+// GetTransactionSites retrieves the sites allocated to a purchase
+// @Summary Retrieve allocated sites
+// @Description Get the sites allocated to one of the authenticated user's transactions
+// @Tags Sites
+// @Security BearerAuth
+// @Param id path int true "Transaction ID"
+// @Produce json
+// @Success 200 {array} models.SiteAllocation
+// @Failure 404 {object} models.GenericResponse "Transaction not found"
+// @Router /user/transactions/{id}/sites [get]
+func GetTransactionSites(c *gin.Context) {
+	transaction, ok := findUserTransaction(c)
+	if !ok {
+		return
+	}
+
+	var allocations []models.SiteAllocation
+	if err := config.DB.Preload("Site").Where("transaction_id = ?", transaction.TransactionID).Find(&allocations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, allocations)
+}
+
+// AllocateSites assigns sites to a purchase
+// @Summary Allocate sites
+// @Description Allocate one site per pass in a transaction, either chosen by the buyer or assigned automatically
+// @Tags Sites
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID"
+// @Param request body models.SiteAllocationRequest false "Chosen sites or auto-assignment preferences"
+// @Success 201 {array} models.SiteAllocation
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Transaction not found"
+// @Failure 409 {object} models.GenericResponse "Sites unavailable"
+// @Router /user/transactions/{id}/sites [post]
+func AllocateSites(c *gin.Context) {
+	transaction, ok := findUserTransaction(c)
+	if !ok {
+		return
+	}
+
+	var request models.SiteAllocationRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	ticket := transaction.Ticket
+	if ticket.SiteCategory == "" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "This ticket does not include a site"})
+		return
+	}
+	if transaction.PaymentStatus == "Failed" || transaction.PaymentStatus == "Expired" {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Transaction is no longer active"})
+		return
+	}
+
+	var allocated int64
+	config.DB.Model(&models.SiteAllocation{}).Where("transaction_id = ?", transaction.TransactionID).Count(&allocated)
+	needed := transaction.Quantity - int(allocated)
+	if needed <= 0 {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "All sites for this purchase have already been allocated"})
+		return
+	}
+
+	var sites []models.Site
+	assignedBy := "Buyer"
+	if len(request.SiteIDs) > 0 {
+		if len(request.SiteIDs) > needed {
+			c.JSON(http.StatusBadRequest, models.GenericResponse{Error: fmt.Sprintf("Only %d site(s) left to allocate", needed)})
+			return
+		}
+		config.DB.Where("site_id IN ? AND event_id = ? AND category = ?", request.SiteIDs, ticket.EventID, ticket.SiteCategory).Find(&sites)
+		if len(sites) != len(request.SiteIDs) {
+			c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "One or more sites are not valid for this ticket"})
+			return
+		}
+		if request.Adjacent && !sitesAdjacent(sites) {
+			c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Selected sites are not adjacent"})
+			return
+		}
+	} else {
+		assignedBy = "Auto"
+		found, err := findFreeSites(ticket, request, needed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+			return
+		}
+		if found == nil {
+			message := "Not enough sites available"
+			if request.Adjacent {
+				message = "No adjacent sites available for this group"
+			}
+			c.JSON(http.StatusConflict, models.GenericResponse{Error: message})
+			return
+		}
+		sites = found
+	}
+
+	allocations := make([]models.SiteAllocation, len(sites))
+	for i, site := range sites {
+		allocations[i] = models.SiteAllocation{
+			SiteID:        site.SiteID,
+			TransactionID: transaction.TransactionID,
+			UserID:        transaction.UserID,
+			AssignedBy:    assignedBy,
+		}
+	}
+
+	// The unique index on site_id rejects sites taken by a concurrent request
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.Omit("Site").Create(&allocations).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "One or more sites have already been allocated"})
+		return
+	}
+
+	for i := range allocations {
+		allocations[i].Site = sites[i]
+	}
+	c.JSON(http.StatusCreated, allocations)
+}
+
+// GetSiteAllocations retrieves all site allocations
+// @Summary Retrieve site allocations
+// @Description Get all site allocations, optionally filtered by event
+// @Tags Sites
+// @Security BearerAuth
+// @Param event_id query int false "Event ID"
+// @Produce json
+// @Success 200 {array} models.SiteAllocation
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/site-allocations [get]
+func GetSiteAllocations(c *gin.Context) {
+	query := config.DB.Preload("Site")
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("site_id IN (?)", config.DB.Model(&models.Site{}).Select("site_id").Where("event_id = ?", eventID))
+	}
+
+	var allocations []models.SiteAllocation
+	if err := query.Find(&allocations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, allocations)
+}
+
+// ReassignSite moves an allocation to a different site
+// @Summary Reassign a site
+// @Description Move an existing allocation to another free site of the same event and category
+// @Tags Sites
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Allocation ID"
+// @Param request body models.SiteReassignRequest true "New site"
+// @Success 200 {object} models.SiteAllocation
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "Allocation not found"
+// @Failure 409 {object} models.GenericResponse "Site already allocated"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/site-allocations/{id} [put]
+func ReassignSite(c *gin.Context) {
+	var allocation models.SiteAllocation
+	if err := config.DB.Preload("Site").First(&allocation, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Allocation not found"})
+		return
+	}
+
+	var request models.SiteReassignRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+		return
+	}
+
+	var site models.Site
+	if err := config.DB.First(&site, request.SiteID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Site not found"})
+		return
+	}
+	if site.EventID != allocation.Site.EventID || site.Category != allocation.Site.Category {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Site belongs to a different event or category"})
+		return
+	}
+
+	// Omit the preloaded site, which GORM would otherwise save back over site_id
+	err := config.DB.Model(&allocation).Omit("Site").Updates(map[string]interface{}{
+		"site_id":     site.SiteID,
+		"assigned_by": "Admin",
+	}).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// site_id is the only unique key besides the primary key
+		c.JSON(http.StatusConflict, models.GenericResponse{Error: "Site is already allocated"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to reassign site"})
+		return
+	}
+
+	allocation.SiteID = site.SiteID
+	allocation.Site = site
+	allocation.AssignedBy = "Admin"
+	c.JSON(http.StatusOK, allocation)
+}
+
+// findUserTransaction loads the transaction in the :id path parameter and
+// makes sure it belongs to the authenticated user
+func findUserTransaction(c *gin.Context) (models.Transaction, bool) {
+	var transaction models.Transaction
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return transaction, false
+	}
+
+	err := config.DB.Preload("Ticket").Where("user_id = ?", userID).First(&transaction, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Transaction not found"})
+		return transaction, false
+	}
+	return transaction, true
+}
+
+// findFreeSites picks count unallocated sites that match the ticket and the
+// request filters. It returns nil when there are not enough matching sites.
+func findFreeSites(ticket models.Ticket, request models.SiteAllocationRequest, count int) ([]models.Site, error) {
+	query := config.DB.
+		Where("event_id = ? AND category = ?", ticket.EventID, ticket.SiteCategory).
+		Where("site_id NOT IN (?)", config.DB.Model(&models.SiteAllocation{}).Select("site_id"))
+	if request.Zone != "" {
+		query = query.Where("zone = ?", request.Zone)
+	}
+	if request.IsRV {
+		query = query.Where("is_rv = ?", true)
+	}
+	if request.IsTent {
+		query = query.Where("is_tent = ?", true)
+	}
+	if request.IsAccessible {
+		query = query.Where("is_accessible = ?", true)
+	}
+
+	var free []models.Site
+	if err := query.Order("zone, site_number").Find(&free).Error; err != nil {
+		return nil, err
+	}
+
+	if !request.Adjacent {
+		if len(free) < count {
+			return nil, nil
+		}
+		return free[:count], nil
+	}
+
+	// Slide a window over the sorted sites looking for a consecutive run
+	for start := 0; start+count <= len(free); start++ {
+		if run := free[start : start+count]; sitesAdjacent(run) {
+			return run, nil
+		}
+	}
+	return nil, nil
+}
+
+// sitesAdjacent reports whether the sites share a zone and have consecutive numbers
+func sitesAdjacent(sites []models.Site) bool {
+	sorted := append([]models.Site(nil), sites...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].SiteNumber < sorted[j].SiteNumber })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Zone != sorted[0].Zone || sorted[i].SiteNumber != sorted[i-1].SiteNumber+1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/testdb"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestReassignSite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+
+	event := models.Event{Name: "Coachella Weekend 1", Timezone: "America/Los_Angeles"}
+	testdb.Create(t, &event)
+	ticket := models.Ticket{EventID: event.EventID, Batch: 1, Type: "Car Camping", Price: 149}
+	user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x"}
+	testdb.Create(t, &ticket, &user)
+	transaction := models.Transaction{UserID: user.UserID, TicketID: ticket.TicketID, Quantity: 2, PaymentStatus: "Paid", Timeout: time.Now()}
+	testdb.Create(t, &transaction)
+	var sites []models.Site
+	for number := 1; number <= 3; number++ {
+		site := models.Site{EventID: event.EventID, Category: "car-camping", Zone: "C", SiteNumber: number, Capacity: 4}
+		testdb.Create(t, &site)
+		sites = append(sites, site)
+	}
+	for _, site := range sites[:2] {
+		testdb.Create(t, &models.SiteAllocation{SiteID: site.SiteID, TransactionID: transaction.TransactionID, UserID: user.UserID, AssignedBy: "Auto"})
+	}
+
+	router := gin.New()
+	router.PUT("/admin/site-allocations/:id", ReassignSite)
+	reassign := func(siteID uint) *httptest.ResponseRecorder {
+		return serve(router, http.MethodPut, "/admin/site-allocations/1", map[string]interface{}{"site_id": siteID})
+	}
+
+	if recorder := reassign(sites[1].SiteID); recorder.Code != http.StatusConflict {
+		t.Errorf("allocated site: status = %d, want %d: %s", recorder.Code, http.StatusConflict, recorder.Body.String())
+	}
+
+	// Other database failures are not conflicts
+	failUpdates := func(db *gorm.DB) { db.AddError(errors.New("connection reset")) }
+	config.DB.Callback().Update().Before("gorm:update").Register("test:fail", failUpdates)
+	if recorder := reassign(sites[2].SiteID); recorder.Code != http.StatusInternalServerError {
+		t.Errorf("database failure: status = %d, want %d: %s", recorder.Code, http.StatusInternalServerError, recorder.Body.String())
+	}
+	config.DB.Callback().Update().Remove("test:fail")
+
+	if recorder := reassign(sites[2].SiteID); recorder.Code != http.StatusOK {
+		t.Fatalf("free site: status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+	}
+	var allocation models.SiteAllocation
+	config.DB.First(&allocation, 1)
+	if allocation.SiteID != sites[2].SiteID || allocation.AssignedBy != "Admin" {
+		t.Errorf("allocation = %+v, want site %d assigned by Admin", allocation, sites[2].SiteID)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/middleware"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/realtime"
+	"coachella-backend/internal/signing"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	streamHeartbeat = 25 * time.Second // Below common proxy idle timeouts
+	streamReplayMax = 500              // Notifications replayed on reconnect
+	streamRetry     = 5000             // Reconnect delay suggested to clients, in milliseconds
+	streamTicketTTL = time.Minute      // How long a stream ticket can open a stream
+)
+
+// CreateStreamTicket issues a ticket for opening the notification stream
+// @Summary Get a notification stream ticket
+// @Description Issue a single-purpose ticket that opens /notifications/stream within a minute. Browsers' EventSource cannot set headers, so the stream takes this ticket in its URL instead of the access token, which would end up in access logs. Fetch a new ticket for every reconnect.
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.StreamTicketResponse
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /notifications/stream-ticket [post]
+func CreateStreamTicket(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+
+	ticket, err := signing.Sign(signing.AudienceStream, jwt.MapClaims{
+		"id":    principal.ID,
+		"email": principal.Email,
+		"type":  principal.AccountType,
+		"sid":   principal.SessionID, // The stream ends when the session is revoked
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(streamTicketTTL).Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to issue stream ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, models.StreamTicketResponse{Ticket: ticket, ExpiresIn: int(streamTicketTTL.Seconds())})
+}
+
+// StreamNotifications returns a handler that streams the current user's new
+// notifications as Server-Sent Events
+// @Summary Stream notifications
+// @Description Push the logged-in user's notifications as Server-Sent Events as they are created. Each event is named after the notification type (Confirmation, Reminder, Update, WaitlistOffer, PaymentStatus or ScheduleChange), has the notification ID as its id, and carries the notification as JSON. The stream is opened with a ticket from /notifications/stream-ticket, and closes when the session is revoked. On reconnect, notifications after Last-Event-ID are replayed.
+// @Tags Notifications
+// @Param ticket query string true "Ticket from /notifications/stream-ticket"
+// @Param Last-Event-ID header int false "ID of the last notification received"
+// @Produce text/event-stream
+// @Success 200 {string} string "Event stream"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Router /notifications/stream [get]
+func StreamNotifications(hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := middleware.CurrentPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+			return
+		}
+		userID := principal.ID
+
+		// Subscribe before replaying so nothing created in between is lost
+		subscription := hub.Subscribe(userID)
+		defer subscription.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+		c.Status(http.StatusOK)
+		fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry)
+
+		// Replay what the client missed while disconnected
+		replayed := map[uint]bool{}
+		if lastID := lastEventID(c); lastID > 0 {
+			var missed []models.Notification
+			config.DB.Where("user_id = ? AND notification_id > ?", userID, lastID).
+				Order("notification_id").Limit(streamReplayMax).Find(&missed)
+			for _, notification := range missed {
+				if writeNotificationEvent(c.Writer, notification) != nil {
+					return
+				}
+				replayed[notification.NotificationID] = true
+			}
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case notification, open := <-subscription.C:
+				if !open {
+					// Fell behind; the client reconnects and replays from its last ID
+					return
+				}
+				// IDs are not published in order, since transactions commit
+				// out of order, so skip only what the replay already sent
+				if replayed[notification.NotificationID] {
+					continue
+				}
+				if writeNotificationEvent(c.Writer, notification) != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-heartbeat.C:
+				// Logging out or changing the password ends open streams too
+				if !middleware.SessionActive(principal) {
+					return
+				}
+				if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// writeNotificationEvent writes a notification as one SSE event
+func writeNotificationEvent(w io.Writer, notification models.Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", notification.NotificationID, notification.NotificationType, data)
+	return err
+}
+
+// lastEventID reads the reconnection point from the Last-Event-ID header, or
+// the last_event_id query parameter for clients that cannot set headers
+func lastEventID(c *gin.Context) uint {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
@@ -9,16 +9,21 @@ import (
 
 // GetTickets retrieves all tickets
 // @Summary Retrieve all tickets
-// @Description Get a list of all available tickets along with their associated event details
+// @Description Get a list of all available tickets along with their associated event details. Tickets of archived events are left out.
 // @Tags Tickets
+// @Param event_id query int false "Only return tickets for this event"
 // @Produce json
 // @Success 200 {array} models.Ticket "List of tickets"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
 // @Router /tickets [get]
 func GetTickets(c *gin.Context) {
 	var tickets []models.Ticket
+	query := config.DB.Preload("Event").Where("event_id IN (?)", publicEventIDs())
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
 	// Use Preload to load the Event relationship
-	result := config.DB.Preload("Event").Find(&tickets)
+	result := query.Find(&tickets)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
 		return
@@ -28,7 +33,7 @@ func GetTickets(c *gin.Context) {
 
 // GetTicketByID retrieves a single ticket by ID
 // @Summary Retrieve a ticket by ID
-// @Description Get a single ticket's details using its ID, including event information
+// @Description Get a single ticket's details using its ID, including event information. Tickets of archived events are not found.
 // @Tags Tickets
 // @Param id path int true "Ticket ID"
 // @Produce json
@@ -40,7 +45,7 @@ func GetTicketByID(c *gin.Context) {
 	var ticket models.Ticket
 
 	// Use Preload to load the associated Event
-	result := config.DB.Preload("Event").First(&ticket, id)
+	result := config.DB.Preload("Event").Where("event_id IN (?)", publicEventIDs()).First(&ticket, id)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Ticket not found"})
 		return
@@ -57,15 +62,19 @@ func GetTicketByID(c *gin.Context) {
 // @Produce json
 // @Param ticket body models.Ticket true "Ticket details"
 // @Success 201 {object} models.Ticket "The newly created ticket"
-// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 400 {object} models.GenericResponse "Bad Request or invalid event_id"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
-// @Router /tickets [post]
+// @Router /admin/tickets [post]
 func CreateTicket(c *gin.Context) {
 	var ticket models.Ticket
 	if err := c.ShouldBindJSON(&ticket); err != nil {
 		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
 		return
 	}
+	if !eventAcceptsTickets(ticket.EventID) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "event_id must reference an existing, unarchived event"})
+		return
+	}
 	result := config.DB.Create(&ticket)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
@@ -85,7 +94,7 @@ func CreateTicket(c *gin.Context) {
 // @Success 200 {object} models.Ticket "The updated ticket"
 // @Failure 400 {object} models.GenericResponse "Bad Request"
 // @Failure 404 {object} models.GenericResponse "Ticket not found"
-// @Router /tickets/{id} [put]
+// @Router /admin/tickets/{id} [put]
 func UpdateTicket(c *gin.Context) {
 	id := c.Param("id")
 	var ticket models.Ticket
@@ -98,6 +107,10 @@ func UpdateTicket(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: err.Error()})
 		return
 	}
+	if !eventAcceptsTickets(ticket.EventID) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "event_id must reference an existing, unarchived event"})
+		return
+	}
 	config.DB.Save(&ticket)
 	c.JSON(http.StatusOK, ticket)
 }
@@ -109,7 +122,7 @@ func UpdateTicket(c *gin.Context) {
 // @Param id path int true "Ticket ID"
 // @Success 200 {object} models.GenericResponse "Ticket deleted successfully"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
-// @Router /tickets/{id} [delete]
+// @Router /admin/tickets/{id} [delete]
 func DeleteTicket(c *gin.Context) {
 	id := c.Param("id")
 	result := config.DB.Delete(&models.Ticket{}, id)
@@ -123,3 +136,10 @@ func DeleteTicket(c *gin.Context) {
 		Message: "Ticket deleted successfully",
 	})
 }
+
+// eventAcceptsTickets reports whether eventID references an event that is not archived
+func eventAcceptsTickets(eventID uint) bool {
+	var count int64
+	config.DB.Model(&models.Event{}).Scopes(models.Unarchived).Where("event_id = ?", eventID).Count(&count)
+	return count > 0
+}
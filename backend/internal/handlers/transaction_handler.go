@@ -2,15 +2,28 @@ package handlers
 
 import (
 	"coachella-backend/config"
+	"coachella-backend/internal/calendar"
+	"coachella-backend/internal/document"
 	"coachella-backend/internal/email"
 	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"log"
+	"math"
+	"gorm.io/gorm"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"time"
 )
 
+var (
+	errSeatsNotHeld = errors.New("seats are not held by the buyer")
+	errNotPending   = errors.New("transaction is not pending")
+	errNotPaid      = errors.New("transaction is not paid")
+)
+
 func ptr(t time.Time) *time.Time {
 	return &t
 }
@@ -62,23 +75,46 @@ func GetTransactionByID(c *gin.Context) {
 	c.JSON(http.StatusOK, transaction)
 }
 
-// GetUserTransactions retrieves transactions for a specific user
-// @Summary Retrieve user transactions
-// @Description Get all transactions for a specific user, including user and ticket details
+// GetUserTransactions retrieves the current user's transactions
+// @Summary Retrieve your transactions
+// @Description Get all transactions of the logged-in user, including user and ticket details
 // @Tags Transactions
-// @Param user_id query int true "User ID"
+// @Security BearerAuth
 // @Produce json
 // @Success 200 {array} models.Transaction
-// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
-// @Router /user-transactions [get]
+// @Router /user/transactions [get]
 func GetUserTransactions(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "user_id is required"})
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+	listTransactions(c, userID)
+}
+
+// GetTransactionsForUser retrieves a user's transactions on behalf of staff
+// @Summary Retrieve a user's transactions
+// @Description Get all transactions of the user in the path, including user and ticket details
+// @Tags Transactions
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Produce json
+// @Success 200 {array} models.Transaction
+// @Failure 404 {object} models.GenericResponse "User not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/users/{id}/transactions [get]
+func GetTransactionsForUser(c *gin.Context) {
+	user, ok := findPathUser(c)
+	if !ok {
 		return
 	}
+	listTransactions(c, user.UserID)
+}
 
+// listTransactions responds with every transaction of a user
+func listTransactions(c *gin.Context, userID uint) {
 	var transactions []models.Transaction
 	result := config.DB.
 		Preload("User").         // Load User details
@@ -95,19 +131,55 @@ func GetUserTransactions(c *gin.Context) {
 	c.JSON(http.StatusOK, transactions)
 }
 
-// CreateTransaction creates a new transaction and sends a confirmation email
+// CreateTransaction reserves tickets for the logged-in user until payment
 // @Summary Create a transaction
-// @Description Create a new transaction for ticket purchase, send a confirmation email, and generate a notification
+// @Description Reserve tickets as the logged-in user. The purchase stays Pending until its payment is confirmed; the tickets, invoice and confirmation email follow then. A user_id, unit_price or total_price in the body is ignored; prices come from the ticket.
 // @Tags Transactions
+// @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param transaction body models.Transaction true "Transaction Details"
 // @Success 201 {object} models.Transaction
 // @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 403 {object} models.GenericResponse "Email address not verified"
 // @Failure 404 {object} models.GenericResponse "Not Found"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
 // @Router /user/transactions [post]
 func CreateTransaction(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+		return
+	}
+	createTransaction(c, userID)
+}
+
+// CreateTransactionForUser buys tickets on behalf of a user
+// @Summary Create a transaction for a user
+// @Description Reserve tickets for the user in the path, for example at the box office. The buyer is always the path user; a user_id, unit_price or total_price in the body is ignored. The confirmation goes to the user once the payment is confirmed.
+// @Tags Transactions
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param transaction body models.Transaction true "Transaction Details"
+// @Success 201 {object} models.Transaction
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 403 {object} models.GenericResponse "Email address not verified"
+// @Failure 404 {object} models.GenericResponse "Not Found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/users/{id}/transactions [post]
+func CreateTransactionForUser(c *gin.Context) {
+	user, ok := findPathUser(c)
+	if !ok {
+		return
+	}
+	createTransaction(c, user.UserID)
+}
+
+// createTransaction reserves tickets for a user; they are issued once the payment is confirmed
+func createTransaction(c *gin.Context, userID uint) {
 	var transaction models.Transaction
 
 	// Bind JSON payload
@@ -116,10 +188,18 @@ func CreateTransaction(c *gin.Context) {
 		return
 	}
 
+	// The buyer comes from the caller, never from the body
+	transaction.UserID = userID
+
+	if transaction.Quantity < 1 {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "quantity must be at least 1"})
+		return
+	}
+
 	// Set default transaction values
 	transaction.PaymentStatus = "Pending"
 	transaction.PaymentGateway = "Midtrans" // Placeholder for future integration
-	transaction.Timeout = time.Now().Add(15 * time.Minute)
+	transaction.Timeout = time.Now().Add(transactionTimeout)
 
 	// Fetch the associated ticket
 	var ticket models.Ticket
@@ -128,72 +208,174 @@ func CreateTransaction(c *gin.Context) {
 		return
 	}
 
+	// Archived events are off sale, like they are hidden from the public listings
+	if ticket.Event.ArchivedAt != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Ticket not found"})
+		return
+	}
+
+	// Sale windows are calendar days at the venue
+	if !ticket.OnSale(time.Now(), ticket.Event.Location()) {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Ticket is not on sale"})
+		return
+	}
+
 	// Check if enough tickets are available
 	if ticket.QuantityAvailable < transaction.Quantity {
 		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Not enough tickets available"})
 		return
 	}
 
-	// Decrement ticket quantity
-	ticket.QuantityAvailable -= transaction.Quantity
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to update ticket quantity"})
+	if len(transaction.AttendeeNames) > transaction.Quantity {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "More attendee names than tickets"})
 		return
 	}
 
-	// Save the transaction in the database
-	if err := config.DB.Create(&transaction).Error; err != nil {
-		// Rollback ticket quantity if transaction fails
-		ticket.QuantityAvailable += transaction.Quantity
-		config.DB.Save(&ticket)
-		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to create transaction"})
-		return
-	}
+	// Prices come from the ticket, never from the body; the invoice is issued from them
+	transaction.UnitPrice = ticket.Price
+	transaction.TotalPrice = math.Round(ticket.Price*float64(transaction.Quantity)*100) / 100
 
-	// Fetch the user details for email and notification
+	// Fetch the user details for the tickets, email and notification
 	var user models.User
 	if err := config.DB.First(&user, transaction.UserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "User not found"})
 		return
 	}
+	if user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, models.GenericResponse{Error: "Verify your email address before buying tickets"})
+		return
+	}
 
-	// Prepare email content
-	templateData := map[string]interface{}{
-		"name":        user.Name,
-		"event_name":  ticket.Event.Name,
-		"ticket_type": ticket.Type,
-		"quantity":    transaction.Quantity,
-		"total_price": strconv.FormatFloat(transaction.TotalPrice, 'f', 2, 64),
-		"event_date":  ticket.Event.StartDate.Format("02-01-2006"),
+	// Reserved seating tickets need one held seat per ticket
+	var seatCount int64
+	config.DB.Model(&models.Seat{}).Where("ticket_id = ?", ticket.TicketID).Count(&seatCount)
+	if seatCount > 0 && len(transaction.SeatIDs) != transaction.Quantity {
+		c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Select one held seat per ticket"})
+		return
 	}
 
-	// Render the email template
-	templatePath := filepath.Join("..", "templates", "emails", "purchase_confirmation.html")
-	body, err := email.RenderTemplate(templatePath, templateData)
+	// Decrement ticket quantity, save the transaction and bind held seats atomically
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		ticket.QuantityAvailable -= transaction.Quantity
+		if err := tx.Save(&ticket).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+		if seatCount > 0 && !bindSeatHolds(tx, transaction, transaction.SeatIDs) {
+			return errSeatsNotHeld
+		}
+		return nil
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to render email template"})
+		if errors.Is(err, errSeatsNotHeld) {
+			c.JSON(http.StatusConflict, models.GenericResponse{Error: "Seats are not held by you or do not match the ticket"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to create transaction"})
 		return
 	}
 
-	// Send confirmation email
-	err = email.SendEmail(user.Email, "Ticket Purchase Confirmation", body)
+	// Tickets and the invoice wait for payment; the purchase has committed, so
+	// a failed notification is logged rather than reported
+	err = notify.Send(notify.Message{
+		UserID:  user.UserID,
+		Type:    notify.TypePaymentStatus,
+		Content: fmt.Sprintf("Your reservation #%d for %s (%s) is held until %s. Your tickets and invoice are sent once payment is received.", transaction.TransactionID, ticket.Event.Name, ticket.Type, transaction.Timeout.In(ticket.Event.Location()).Format("15:04 MST")),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to send email"})
-		return
+		log.Printf("Failed to notify reservation of transaction %d: %v\n", transaction.TransactionID, err)
 	}
 
-	// Save a notification to the database
-	notification := models.Notification{
-		UserID:          user.UserID,
-		NotificationType: "Confirmation",
-		Content:         "Your purchase for " + ticket.Event.Name + " (" + ticket.Type + ") has been confirmed.",
-		SentAt:          ptr(time.Now()),
+	// Respond with the created transaction
+	c.JSON(http.StatusCreated, transaction)
+}
+
+// ConfirmPayment marks a pending transaction as paid and issues its tickets
+// @Summary Confirm a payment
+// @Description Record that a pending purchase has been paid. Its tickets are issued, and the buyer is sent the confirmation with the e-tickets, the tax invoice and a calendar file.
+// @Tags Transactions
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Transaction ID"
+// @Success 200 {object} models.Transaction
+// @Failure 404 {object} models.GenericResponse "Transaction not found"
+// @Failure 409 {object} models.GenericResponse "Transaction is not pending or its reservation has expired"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /transactions/{id}/confirm-payment [post]
+func ConfirmPayment(c *gin.Context) {
+	var transaction models.Transaction
+	if err := config.DB.Preload("User").Preload("Ticket.Event").First(&transaction, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Transaction not found"})
+		return
 	}
-	if err := config.DB.Create(&notification).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to create notification"})
+
+	// Only the first of two confirmations finds the reservation still pending
+	now := time.Now()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Transaction{}).
+			Where("transaction_id = ? AND payment_status = ? AND timeout > ?", transaction.TransactionID, "Pending", now).
+			Updates(map[string]interface{}{"payment_status": "Paid", "paid_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotPending
+		}
+		transaction.PaymentStatus, transaction.PaidAt = "Paid", &now
+		return issueTickets(tx, transaction, transaction.User.Name)
+	})
+	if err != nil {
+		if errors.Is(err, errNotPending) {
+			c.JSON(http.StatusConflict, models.GenericResponse{Error: "Transaction is not pending or its reservation has expired"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: "Failed to confirm payment"})
 		return
 	}
 
-	// Respond with the created transaction
-	c.JSON(http.StatusCreated, transaction)
+	// The payment has committed, so failures from here on are logged rather
+	// than reported. The tickets and invoice stay downloadable, and the outbox
+	// retries emails.
+	if err := sendPurchaseConfirmation(transaction); err != nil {
+		log.Printf("Failed to send confirmation of transaction %d: %v\n", transaction.TransactionID, err)
+	}
+	c.JSON(http.StatusOK, transaction)
+}
+
+// sendPurchaseConfirmation sends the buyer of a paid transaction their e-tickets, invoice and calendar file
+func sendPurchaseConfirmation(transaction models.Transaction) error {
+	user, ticket := transaction.User, transaction.Ticket
+	templateData := map[string]interface{}{
+		"name":        user.Name,
+		"event_name":  ticket.Event.Name,
+		"ticket_type": ticket.Type,
+		"quantity":    transaction.Quantity,
+		"total_price": strconv.FormatFloat(transaction.TotalPrice, 'f', 2, 64),
+		"event_date":  ticket.Event.StartDate.Format("02-01-2006"),
+	}
+	if ticket.Event.DoorsOpenAt != nil {
+		templateData["doors_open"] = ticket.Event.DoorsOpenAt.Format("15:04 MST")
+	}
+
+	var attachments []email.Attachment
+	if ticketsPDF, err := transactionTicketsPDF(transaction.TransactionID); err == nil {
+		attachments = append(attachments, email.Attachment{Filename: fmt.Sprintf("tickets-%d.pdf", transaction.TransactionID), ContentType: document.ContentType, Data: ticketsPDF})
+	} else {
+		log.Printf("Failed to render tickets of transaction %d: %v\n", transaction.TransactionID, err)
+	}
+	if invoicePDF, err := transactionInvoicePDF(transaction.TransactionID); err == nil {
+		attachments = append(attachments, email.Attachment{Filename: invoiceNumber(transaction) + ".pdf", ContentType: document.ContentType, Data: invoicePDF})
+	} else {
+		log.Printf("Failed to render invoice of transaction %d: %v\n", transaction.TransactionID, err)
+	}
+	attachments = append(attachments, email.Attachment{Filename: fmt.Sprintf("event-%d.ics", ticket.Event.EventID), ContentType: calendar.ContentType, Data: eventCalendarAttachment(ticket.Event)})
+
+	return notify.Send(notify.Message{
+		UserID:  user.UserID,
+		Type:    notify.TypeConfirmation,
+		Content: "Your purchase for " + ticket.Event.Name + " (" + ticket.Type + ") has been confirmed.",
+		Email:   &notify.Email{Template: "purchase_confirmation", Data: templateData, Attachments: attachments},
+	})
 }
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/email"
+	"coachella-backend/internal/middleware"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/testdb"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateTransactionArchivedEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := email.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	email.UseOutbox(email.NewMailerOutbox(email.NewMemoryMailer()))
+	defer email.UseOutbox(email.NewDBOutbox())
+
+	tests := []struct {
+		name       string
+		archivedAt *time.Time
+		wantCode   int
+	}{
+		{name: "live event", wantCode: http.StatusCreated},
+		{name: "archived event", archivedAt: ptr(time.Now()), wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testdb.Use(t)
+			event := models.Event{Name: "Coachella Weekend 1", Timezone: "America/Los_Angeles", ArchivedAt: tt.archivedAt}
+			testdb.Create(t, &event)
+			ticket := models.Ticket{EventID: event.EventID, Batch: 1, Type: "GA", Price: 499, QuantityAvailable: 10}
+			user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x", EmailVerifiedAt: ptr(time.Now())}
+			testdb.Create(t, &ticket, &user)
+
+			router := gin.New()
+			router.POST("/users/:id/transactions", CreateTransactionForUser)
+			recorder := serve(router, http.MethodPost, "/users/1/transactions", map[string]interface{}{"ticket_id": ticket.TicketID, "quantity": 2})
+
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantCode, recorder.Body.String())
+			}
+			if tt.wantCode != http.StatusCreated {
+				var count int64
+				config.DB.Model(&models.Transaction{}).Count(&count)
+				if count != 0 {
+					t.Errorf("created %d transactions, want none", count)
+				}
+				config.DB.First(&ticket, ticket.TicketID)
+				if ticket.QuantityAvailable != 10 {
+					t.Errorf("quantity available = %d, want 10", ticket.QuantityAvailable)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmPayment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := email.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	mailer := email.NewMemoryMailer()
+	email.UseOutbox(email.NewMailerOutbox(mailer))
+	defer email.UseOutbox(email.NewDBOutbox())
+	testdb.Use(t)
+
+	event := models.Event{Name: "Coachella Weekend 1", Timezone: "America/Los_Angeles"}
+	testdb.Create(t, &event)
+	ticket := models.Ticket{EventID: event.EventID, Batch: 1, Type: "GA", Price: 499, QuantityAvailable: 10}
+	user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x", EmailVerifiedAt: ptr(time.Now())}
+	testdb.Create(t, &ticket, &user)
+
+	router := gin.New()
+	router.POST("/users/:id/transactions", CreateTransactionForUser)
+	router.POST("/transactions/:id/confirm-payment", ConfirmPayment)
+	buyer := router.Group("/user", asPrincipal(middleware.Principal{AccountType: "user", ID: user.UserID}))
+	buyer.GET("/transactions/:id/tickets.pdf", GetTransactionTicketsPDF)
+	buyer.GET("/transactions/:id/invoice.pdf", GetTransactionInvoicePDF)
+
+	recorder := serve(router, http.MethodPost, "/users/1/transactions", map[string]interface{}{
+		"ticket_id": ticket.TicketID, "quantity": 2, "attendee_names": []string{"Ana", "Budi"},
+	})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d: %s", recorder.Code, http.StatusCreated, recorder.Body.String())
+	}
+
+	// Nothing admits or invoices before payment
+	var issued []models.IssuedTicket
+	config.DB.Find(&issued)
+	if len(issued) != 0 {
+		t.Errorf("issued %d tickets before payment, want none", len(issued))
+	}
+	if got := len(mailer.Messages()); got != 0 {
+		t.Errorf("sent %d emails before payment, want none", got)
+	}
+	for _, document := range []string{"tickets.pdf", "invoice.pdf"} {
+		if code := serve(router, http.MethodGet, "/user/transactions/1/"+document, nil).Code; code != http.StatusConflict {
+			t.Errorf("%s before payment: status = %d, want %d", document, code, http.StatusConflict)
+		}
+	}
+
+	if code := serve(router, http.MethodPost, "/transactions/1/confirm-payment", nil).Code; code != http.StatusOK {
+		t.Fatalf("confirm: status = %d, want %d", code, http.StatusOK)
+	}
+	config.DB.Order("issued_ticket_id").Find(&issued)
+	if len(issued) != 2 || issued[0].AttendeeName != "Ana" || issued[1].AttendeeName != "Budi" {
+		t.Errorf("issued tickets = %+v, want one each for Ana and Budi", issued)
+	}
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d emails after payment, want 1", len(messages))
+	}
+	if got := len(messages[0].Attachments); got != 3 {
+		t.Errorf("confirmation has %d attachments, want tickets, invoice and calendar file", got)
+	}
+	for _, document := range []string{"tickets.pdf", "invoice.pdf"} {
+		if code := serve(router, http.MethodGet, "/user/transactions/1/"+document, nil).Code; code != http.StatusOK {
+			t.Errorf("%s after payment: status = %d, want %d", document, code, http.StatusOK)
+		}
+	}
+
+	// A second confirmation issues nothing more
+	if code := serve(router, http.MethodPost, "/transactions/1/confirm-payment", nil).Code; code != http.StatusConflict {
+		t.Errorf("second confirm: status = %d, want %d", code, http.StatusConflict)
+	}
+	var count int64
+	config.DB.Model(&models.IssuedTicket{}).Count(&count)
+	if count != 2 {
+		t.Errorf("issued %d tickets after a second confirmation, want 2", count)
+	}
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
     "coachella-backend/config"
+    "coachella-backend/internal/email"
     "coachella-backend/internal/models"
     "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
     "net/http"
 )
 
@@ -30,21 +32,6 @@ func GetUserByID(c *gin.Context) {
     c.JSON(http.StatusOK, user)
 }
 
-// Create a new user
-func CreateUser(c *gin.Context) {
-    var user models.User
-    if err := c.ShouldBindJSON(&user); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-    result := config.DB.Create(&user)
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
-        return
-    }
-    c.JSON(http.StatusCreated, user)
-}
-
 // Update an existing user
 func UpdateUser(c *gin.Context) {
     id := c.Param("id")
@@ -62,13 +49,60 @@ func UpdateUser(c *gin.Context) {
     c.JSON(http.StatusOK, user)
 }
 
-// Delete a user
+// Delete a user and sign out all their sessions
 func DeleteUser(c *gin.Context) {
-    id := c.Param("id")
-    result := config.DB.Delete(&models.User{}, id)
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+    var user models.User
+    if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        return
+    }
+    err := config.DB.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Delete(&user).Error; err != nil {
+            return err
+        }
+        return revokeSessions(tx, "user", user.UserID, "account_deleted")
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
     c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
+
+// UpdateLanguage sets the language of the current user's emails
+// @Summary Set email language
+// @Description Choose the language emails are sent in. Supported languages are en (English) and id (Bahasa Indonesia).
+// @Tags Users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param language body models.LanguageRequest true "Language"
+// @Success 200 {object} models.GenericResponse
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /user/language [put]
+func UpdateLanguage(c *gin.Context) {
+    userID, ok := currentUserID(c)
+    if !ok {
+        c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Unauthorized"})
+        return
+    }
+
+    var request models.LanguageRequest
+    if err := c.ShouldBindJSON(&request); err != nil {
+        c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
+        return
+    }
+    language := email.NormalizeLocale(request.Language)
+    if !email.SupportsLocale(language) {
+        c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Unsupported language " + request.Language})
+        return
+    }
+
+    if err := config.DB.Model(&models.User{}).Where("user_id = ?", userID).Update("language", language).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: err.Error()})
+        return
+    }
+    c.JSON(http.StatusOK, models.GenericResponse{Message: "Language updated to " + language})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/middleware"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/testdb"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeletedAccountsLoseTheirSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+
+	user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x"}
+	admin := models.Admin{Name: "Gate", Email: "gate@example.com", Password: "x"}
+	testdb.Create(t, &user, &admin)
+	userSession := models.Session{AccountType: "user", AccountID: user.UserID, ExpiresAt: time.Now().Add(time.Hour)}
+	adminSession := models.Session{AccountType: "admin", AccountID: admin.AdminID, ExpiresAt: time.Now().Add(time.Hour)}
+	testdb.Create(t, &userSession, &adminSession)
+	userPrincipal := middleware.Principal{AccountType: "user", ID: user.UserID, SessionID: userSession.SessionID}
+	adminPrincipal := middleware.Principal{AccountType: "admin", ID: admin.AdminID, SessionID: adminSession.SessionID}
+
+	if !middleware.SessionActive(userPrincipal) || !middleware.SessionActive(adminPrincipal) {
+		t.Fatal("sessions are not active before the accounts are deleted")
+	}
+
+	t.Run("DeleteUser revokes sessions", func(t *testing.T) {
+		router := gin.New()
+		router.DELETE("/users/:id", DeleteUser)
+		if code := serve(router, http.MethodDelete, "/users/1", nil).Code; code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", code, http.StatusOK)
+		}
+		config.DB.First(&userSession, userSession.SessionID)
+		if userSession.RevokedAt == nil || userSession.RevokedReason != "account_deleted" {
+			t.Errorf("session revoked_at = %v, reason = %q, want revoked as account_deleted", userSession.RevokedAt, userSession.RevokedReason)
+		}
+		if middleware.SessionActive(userPrincipal) {
+			t.Error("session of a deleted user is still active")
+		}
+	})
+
+	t.Run("SessionActive needs the account", func(t *testing.T) {
+		// Deleted without going through a handler, so the session is not revoked
+		config.DB.Delete(&admin)
+		if middleware.SessionActive(adminPrincipal) {
+			t.Error("session of a deleted admin is still active")
+		}
+	})
+}
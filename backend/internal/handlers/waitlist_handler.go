@@ -7,24 +7,66 @@ import (
     "net/http"
 )
 
-// AddUserToWaitlist adds a user to the waitlist for a sold-out ticket
-// @Summary Add user to waitlist
-// @Description Allows a user to join the waitlist for a sold-out ticket
+// AddUserToWaitlist adds the current user to the waitlist for a sold-out ticket
+// @Summary Join waitlist
+// @Description Allows the logged-in user to join the waitlist for a sold-out ticket
 // @Tags Waitlist
+// @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param waitlist body models.Waitlist true "Waitlist Details"
+// @Param waitlist body models.WaitlistRequest true "Ticket to wait for"
 // @Success 201 {object} models.Waitlist
 // @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 401 {object} models.GenericResponse "Unauthorized"
+// @Failure 404 {object} models.GenericResponse "Ticket not found"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
-// @Router /waitlist [post]
+// @Router /user/waitlist [post]
 func AddUserToWaitlist(c *gin.Context) {
-    var waitlist models.Waitlist
-    if err := c.ShouldBindJSON(&waitlist); err != nil {
+    userID, ok := currentUserID(c)
+    if !ok {
+        c.JSON(http.StatusUnauthorized, models.GenericResponse{Error: "Invalid token claims"})
+        return
+    }
+    addToWaitlist(c, userID)
+}
+
+// AddToWaitlistForUser adds a user to the waitlist on behalf of staff
+// @Summary Add a user to a waitlist
+// @Description Put the user in the path on the waitlist for a sold-out ticket
+// @Tags Waitlist
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param waitlist body models.WaitlistRequest true "Ticket to wait for"
+// @Success 201 {object} models.Waitlist
+// @Failure 400 {object} models.GenericResponse "Bad Request"
+// @Failure 404 {object} models.GenericResponse "User or ticket not found"
+// @Failure 500 {object} models.GenericResponse "Internal Server Error"
+// @Router /admin/users/{id}/waitlist [post]
+func AddToWaitlistForUser(c *gin.Context) {
+    user, ok := findPathUser(c)
+    if !ok {
+        return
+    }
+    addToWaitlist(c, user.UserID)
+}
+
+// addToWaitlist puts a user on the waitlist of the ticket in the request body
+func addToWaitlist(c *gin.Context, userID uint) {
+    var request models.WaitlistRequest
+    if err := c.ShouldBindJSON(&request); err != nil {
         c.JSON(http.StatusBadRequest, models.GenericResponse{Error: "Invalid input"})
         return
     }
 
+    var ticket models.Ticket
+    if err := config.DB.First(&ticket, request.TicketID).Error; err != nil {
+        c.JSON(http.StatusNotFound, models.GenericResponse{Error: "Ticket not found"})
+        return
+    }
+
+    waitlist := models.Waitlist{UserID: userID, TicketID: ticket.TicketID}
     result := config.DB.Create(&waitlist)
     if result.Error != nil {
         c.JSON(http.StatusInternalServerError, models.GenericResponse{Error: result.Error.Error()})
@@ -38,12 +80,13 @@ func AddUserToWaitlist(c *gin.Context) {
 // @Summary Retrieve waitlist
 // @Description Get a list of users on the waitlist for a specific ticket
 // @Tags Waitlist
+// @Security BearerAuth
 // @Param ticket_id query int true "Ticket ID"
 // @Produce json
 // @Success 200 {array} models.Waitlist
 // @Failure 400 {object} models.GenericResponse "Bad Request"
 // @Failure 500 {object} models.GenericResponse "Internal Server Error"
-// @Router /waitlist [get]
+// @Router /admin/waitlist [get]
 func GetWaitlist(c *gin.Context) {
     ticketID := c.Query("ticket_id")
     if ticketID == "" {
@@ -0,0 +1,101 @@
+package lockout
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore keeps failed login state in the login_attempts table, so every
+// instance sharing the database sees the same failures and locks
+type DBStore struct{}
+
+// NewDBStore creates a DBStore on config.DB
+func NewDBStore() *DBStore {
+	return &DBStore{}
+}
+
+// Get returns the state of key
+func (s *DBStore) Get(ctx context.Context, key string) (State, error) {
+	var attempt models.LoginAttempt
+	err := config.DB.WithContext(ctx).Where("attempt_key = ?", key).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	return attemptState(attempt), nil
+}
+
+// Reserve counts an attempt on key unless wait delays it. The row is locked
+// from the check to the count, so concurrent attempts on several instances
+// each see the ones before them.
+func (s *DBStore) Reserve(ctx context.Context, key string, now time.Time, window time.Duration, wait func(State) time.Duration) (time.Duration, error) {
+	var delay time.Duration
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		first := models.LoginAttempt{AttemptKey: key, WindowStart: now, LastFailureAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&first).Error; err != nil {
+			return err
+		}
+		var attempt models.LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("attempt_key = ?", key).First(&attempt).Error; err != nil {
+			return err
+		}
+
+		if attempt.Failures > 0 && !now.Before(attempt.WindowStart.Add(window)) {
+			attempt.Failures = 0
+		}
+		if delay = wait(attemptState(attempt)); delay > 0 {
+			return nil
+		}
+		if attempt.Failures == 0 {
+			attempt.WindowStart = now
+		}
+		attempt.Failures++
+		attempt.LastFailureAt = now
+		return tx.Save(&attempt).Error
+	})
+	return delay, err
+}
+
+// Release takes back one reserved attempt
+func (s *DBStore) Release(ctx context.Context, key string) error {
+	return config.DB.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("attempt_key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+// Lock locks key until the given time unless it is already locked. The
+// condition is part of the update, so of concurrent callers only one locks.
+func (s *DBStore) Lock(ctx context.Context, key string, now, until time.Time) (bool, error) {
+	result := config.DB.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("attempt_key = ? AND (locked_until IS NULL OR locked_until <= ?)", key, now).
+		Updates(map[string]interface{}{"locked_until": until, "failures": 0})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Reset forgets key
+func (s *DBStore) Reset(ctx context.Context, key string) error {
+	return config.DB.WithContext(ctx).Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+// Prune forgets keys with no failure or lock after before
+func (s *DBStore) Prune(ctx context.Context, before time.Time) error {
+	return config.DB.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&models.LoginAttempt{}).Error
+}
+
+func attemptState(attempt models.LoginAttempt) State {
+	state := State{Failures: attempt.Failures, WindowStart: attempt.WindowStart, LastFailure: attempt.LastFailureAt}
+	if attempt.LockedUntil != nil {
+		state.LockedUntil = *attempt.LockedUntil
+	}
+	return state
+}
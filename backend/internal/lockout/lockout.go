@@ -0,0 +1,249 @@
+// Package lockout slows down and then temporarily locks out repeated failed
+// logins, tracked both per account and per client IP address.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is what a Store keeps about one account or IP address
+type State struct {
+	Failures    int       // Failed logins in the current window
+	WindowStart time.Time // First failure of the current window
+	LastFailure time.Time
+	LockedUntil time.Time // Zero when not locked
+}
+
+// Store keeps failed login state. The memory store suits a single instance;
+// several instances must share a store. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the state of key, or a zero State when nothing is tracked
+	Get(ctx context.Context, key string) (State, error)
+	// Reserve counts an attempt on key at now as a failure up front, unless
+	// wait returns a positive delay for the current state; then it changes
+	// nothing and returns the delay. Checking and counting are one atomic step,
+	// and a window that began more than window ago is restarted first.
+	Reserve(ctx context.Context, key string, now time.Time, window time.Duration, wait func(State) time.Duration) (time.Duration, error)
+	// Release takes back one reserved attempt
+	Release(ctx context.Context, key string) error
+	// Lock locks key until the given time and restarts its count, unless it
+	// is already locked at now. It reports whether it locked.
+	Lock(ctx context.Context, key string, now, until time.Time) (bool, error)
+	// Reset forgets key, lifting any lock
+	Reset(ctx context.Context, key string) error
+	// Prune forgets keys with no failure or lock after before
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Policy sets how failures are counted and punished
+type Policy struct {
+	AccountMaxFailures int           // Failures on one account before it locks
+	IPMaxFailures      int           // Failures from one IP address before it locks
+	Window             time.Duration // Failures older than this are forgotten
+	LockoutDuration    time.Duration // How long a lock lasts
+	FreeFailures       int           // Failures on an account before attempts are delayed; an IP address gets half its limit
+	BaseDelay          time.Duration // Delay after the first delayed failure, doubling with each one after
+	MaxDelay           time.Duration
+}
+
+// DefaultPolicy locks an account after 5 failures and an IP address after 50
+// within 15 minutes, for 15 minutes. From an account's third failure, the next
+// attempt must wait 1s, then 2s, 4s and so on up to 30s; an IP address is
+// delayed the same way from its 26th.
+var DefaultPolicy = Policy{
+	AccountMaxFailures: 5,
+	IPMaxFailures:      50,
+	Window:             15 * time.Minute,
+	LockoutDuration:    15 * time.Minute,
+	FreeFailures:       2,
+	BaseDelay:          time.Second,
+	MaxDelay:           30 * time.Second,
+}
+
+// PolicyFromEnv is DefaultPolicy with LOGIN_MAX_FAILURES, LOGIN_IP_MAX_FAILURES,
+// LOGIN_FAILURE_WINDOW and LOGIN_LOCKOUT_DURATION applied when set
+func PolicyFromEnv() Policy {
+	policy := DefaultPolicy
+	policy.AccountMaxFailures = count("LOGIN_MAX_FAILURES", policy.AccountMaxFailures)
+	policy.IPMaxFailures = count("LOGIN_IP_MAX_FAILURES", policy.IPMaxFailures)
+	policy.Window = duration("LOGIN_FAILURE_WINDOW", policy.Window)
+	policy.LockoutDuration = duration("LOGIN_LOCKOUT_DURATION", policy.LockoutDuration)
+	return policy
+}
+
+// delay is how long to wait after the last of failures before the next
+// attempt, when the first free failures cost nothing
+func (p Policy) delay(failures, free int) time.Duration {
+	if failures <= free {
+		return 0
+	}
+	shift := failures - free - 1
+	if shift >= 16 {
+		return p.MaxDelay
+	}
+	delay := p.BaseDelay << shift
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// NewStoreFromEnv builds the store selected by LOGIN_ATTEMPT_STORE: "memory"
+// (the default) or "database", which instances sharing the database also share
+func NewStoreFromEnv() (Store, error) {
+	switch name := os.Getenv("LOGIN_ATTEMPT_STORE"); name {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "database":
+		return NewDBStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown LOGIN_ATTEMPT_STORE %q", name)
+	}
+}
+
+var (
+	mu     sync.RWMutex
+	store  Store = NewMemoryStore()
+	policy       = DefaultPolicy
+)
+
+// Configure sets the store and policy used by the package functions
+func Configure(s Store, p Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	store, policy = s, p
+}
+
+func current() (Store, Policy) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return store, policy
+}
+
+// AccountKey identifies an account by type and email. Emails without an
+// account are tracked the same way, so lockouts don't reveal which exist.
+func AccountKey(accountType, email string) string {
+	return "account:" + accountType + ":" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey identifies a client IP address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// wait is how long an attempt on a key must wait, given its state, its
+// failure limit and the failures it may have before attempts are delayed
+func (p Policy) wait(now time.Time, limit, free int) func(State) time.Duration {
+	return func(state State) time.Duration {
+		if now.Before(state.LockedUntil) {
+			return state.LockedUntil.Sub(now)
+		}
+		if state.Failures == 0 {
+			return 0
+		}
+		// Attempts still in flight can take the count to the limit; whichever
+		// of them fails locks the key, so hold the rest back meanwhile
+		if state.Failures >= limit {
+			return time.Second
+		}
+		return state.LastFailure.Add(p.delay(state.Failures, free)).Sub(now)
+	}
+}
+
+// Begin reserves a login attempt on an account from an IP address before
+// the password is checked, so parallel guesses are counted as they start.
+// It returns how long the caller must wait instead when it may not go ahead.
+// Every attempt that goes ahead must end with Fail or Succeed.
+func Begin(ctx context.Context, accountKey, ipKey string) (time.Duration, error) {
+	store, policy := current()
+	now := time.Now()
+
+	wait, err := store.Reserve(ctx, accountKey, now, policy.Window, policy.wait(now, policy.AccountMaxFailures, policy.FreeFailures))
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	// Many people can share an IP address, so it may fail more before slowing down
+	wait, err = store.Reserve(ctx, ipKey, now, policy.Window, policy.wait(now, policy.IPMaxFailures, policy.IPMaxFailures/2))
+	if err != nil || wait > 0 {
+		if err := store.Release(ctx, accountKey); err != nil {
+			return 0, err
+		}
+	}
+	return wait, err
+}
+
+// Fail confirms a reserved attempt as a failed login, locking the account or
+// IP address once it reaches its limit. It returns when the account's lock
+// ends if this failure locked it, or the zero time.
+func Fail(ctx context.Context, accountKey, ipKey string) (time.Time, error) {
+	store, policy := current()
+	now := time.Now()
+	until := now.Add(policy.LockoutDuration)
+
+	if _, err := lockAtLimit(ctx, store, ipKey, policy.IPMaxFailures, now, until); err != nil {
+		return time.Time{}, err
+	}
+	// Only the failure that locks the account reports it, so the owner is told once
+	locked, err := lockAtLimit(ctx, store, accountKey, policy.AccountMaxFailures, now, until)
+	if err != nil || !locked {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// lockAtLimit locks key when its failures have reached limit
+func lockAtLimit(ctx context.Context, store Store, key string, limit int, now, until time.Time) (bool, error) {
+	state, err := store.Get(ctx, key)
+	if err != nil || state.Failures < limit {
+		return false, err
+	}
+	return store.Lock(ctx, key, now, until)
+}
+
+// Succeed ends a reserved attempt that logged in: the account's failures are
+// forgotten and the IP address gets its reserved attempt back. The IP address
+// keeps its other failures, so one known password can't reset the count of
+// guesses against other accounts.
+func Succeed(ctx context.Context, accountKey, ipKey string) error {
+	store, _ := current()
+	if err := store.Reset(ctx, accountKey); err != nil {
+		return err
+	}
+	return store.Release(ctx, ipKey)
+}
+
+// Unlock lifts an account's lock and forgets its failures
+func Unlock(ctx context.Context, accountKey string) error {
+	store, _ := current()
+	return store.Reset(ctx, accountKey)
+}
+
+// Prune forgets keys that have been quiet for longer than the window
+func Prune(ctx context.Context) error {
+	store, policy := current()
+	return store.Prune(ctx, time.Now().Add(-policy.Window))
+}
+
+// count reads a positive integer from the environment, falling back when unset or invalid
+func count(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// duration reads a duration from the environment, falling back when unset or invalid
+func duration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
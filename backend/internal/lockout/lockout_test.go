@@ -0,0 +1,192 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	tests := []struct {
+		failures, free int
+		want           time.Duration
+	}{
+		{0, 2, 0},
+		{2, 2, 0},
+		{3, 2, time.Second},
+		{4, 2, 2 * time.Second},
+		{7, 2, 16 * time.Second},
+		{8, 2, 30 * time.Second},
+		{100, 2, 30 * time.Second},
+		{26, 25, time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.failures, tt.free); got != tt.want {
+			t.Errorf("delay(%d, %d) = %v, want %v", tt.failures, tt.free, got, tt.want)
+		}
+	}
+}
+
+// testPolicy locks an account after 3 failures and an IP address after 5,
+// without delays in between
+var testPolicy = Policy{
+	AccountMaxFailures: 3,
+	IPMaxFailures:      5,
+	Window:             time.Minute,
+	LockoutDuration:    time.Hour,
+	FreeFailures:       1,
+}
+
+// useStore configures a fresh MemoryStore with policy for one test
+func useStore(t *testing.T, policy Policy) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	Configure(store, policy)
+	t.Cleanup(func() { Configure(NewMemoryStore(), DefaultPolicy) })
+	return store
+}
+
+// failLogin runs one attempt that fails, reporting whether it was let through
+func failLogin(t *testing.T, accountKey, ipKey string) (time.Time, bool) {
+	t.Helper()
+	ctx := context.Background()
+	wait, err := Begin(ctx, accountKey, ipKey)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if wait > 0 {
+		return time.Time{}, false
+	}
+	lockedUntil, err := Fail(ctx, accountKey, ipKey)
+	if err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	return lockedUntil, true
+}
+
+func TestAccountLocksAtLimit(t *testing.T) {
+	useStore(t, testPolicy)
+	account, ip := AccountKey("user", "Ana@Example.com "), IPKey("203.0.113.9")
+
+	for i := 1; i <= testPolicy.AccountMaxFailures; i++ {
+		lockedUntil, ok := failLogin(t, account, ip)
+		if !ok {
+			t.Fatalf("attempt %d was held back", i)
+		}
+		if locked := !lockedUntil.IsZero(); locked != (i == testPolicy.AccountMaxFailures) {
+			t.Fatalf("attempt %d: locked = %v", i, locked)
+		}
+	}
+
+	// The key is case and space insensitive, and other IP addresses are held back too
+	wait, err := Begin(context.Background(), AccountKey("user", "ana@example.com"), IPKey("198.51.100.7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait < testPolicy.LockoutDuration-time.Minute {
+		t.Errorf("wait = %v, want about %v", wait, testPolicy.LockoutDuration)
+	}
+
+	if err := Unlock(context.Background(), account); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := failLogin(t, account, ip); !ok {
+		t.Error("attempt after Unlock was held back")
+	}
+}
+
+func TestSucceedForgetsAccountFailures(t *testing.T) {
+	store := useStore(t, testPolicy)
+	ctx := context.Background()
+	account, ip := AccountKey("user", "ana@example.com"), IPKey("203.0.113.9")
+
+	failLogin(t, account, ip)
+	failLogin(t, account, ip)
+	if wait, err := Begin(ctx, account, ip); err != nil || wait > 0 {
+		t.Fatalf("Begin = %v, %v", wait, err)
+	}
+	if err := Succeed(ctx, account, ip); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _ := store.Get(ctx, account); state.Failures != 0 {
+		t.Errorf("account failures = %d, want 0", state.Failures)
+	}
+	// The IP address keeps the two real failures but gets the reservation back
+	if state, _ := store.Get(ctx, ip); state.Failures != 2 {
+		t.Errorf("IP failures = %d, want 2", state.Failures)
+	}
+}
+
+func TestIPLocksAcrossAccounts(t *testing.T) {
+	useStore(t, testPolicy)
+	ip := IPKey("203.0.113.9")
+	accounts := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}
+
+	for _, address := range accounts {
+		if _, ok := failLogin(t, AccountKey("user", address), ip); !ok {
+			t.Fatalf("attempt on %s was held back", address)
+		}
+	}
+	if _, ok := failLogin(t, AccountKey("user", "f@example.com"), ip); ok {
+		t.Error("attempt from a locked IP address was let through")
+	}
+	// Another IP address can still try the same accounts
+	if _, ok := failLogin(t, AccountKey("user", "f@example.com"), IPKey("198.51.100.7")); !ok {
+		t.Error("attempt from another IP address was held back")
+	}
+}
+
+func TestFailuresPastFreeAreDelayed(t *testing.T) {
+	policy := testPolicy
+	policy.FreeFailures = 1
+	policy.BaseDelay = time.Minute
+	policy.MaxDelay = time.Hour
+	useStore(t, policy)
+	account, ip := AccountKey("user", "ana@example.com"), IPKey("203.0.113.9")
+
+	if _, ok := failLogin(t, account, ip); !ok {
+		t.Fatal("first attempt was held back")
+	}
+	if _, ok := failLogin(t, account, ip); !ok {
+		t.Fatal("attempt after a free failure was held back")
+	}
+	wait, err := Begin(context.Background(), account, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > policy.BaseDelay {
+		t.Errorf("wait = %v, want up to %v", wait, policy.BaseDelay)
+	}
+}
+
+func TestBeginReservesConcurrentAttempts(t *testing.T) {
+	useStore(t, testPolicy)
+	account, ip := AccountKey("user", "ana@example.com"), IPKey("203.0.113.9")
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := Begin(context.Background(), account, ip)
+			if err != nil || wait > 0 {
+				return
+			}
+			mu.Lock()
+			allowed++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if allowed != testPolicy.AccountMaxFailures {
+		t.Errorf("%d parallel attempts let through, want %d", allowed, testPolicy.AccountMaxFailures)
+	}
+}
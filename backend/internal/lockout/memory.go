@@ -0,0 +1,91 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failed login state in process memory, for single-instance
+// deployments and tests
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+// Get returns the state of key
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[key], nil
+}
+
+// Reserve counts an attempt on key unless wait delays it
+func (s *MemoryStore) Reserve(ctx context.Context, key string, now time.Time, window time.Duration, wait func(State) time.Duration) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.states[key]
+	if state.Failures > 0 && !now.Before(state.WindowStart.Add(window)) {
+		state.Failures = 0
+	}
+	if delay := wait(state); delay > 0 {
+		return delay, nil
+	}
+	if state.Failures == 0 {
+		state.WindowStart = now
+	}
+	state.Failures++
+	state.LastFailure = now
+	s.states[key] = state
+	return 0, nil
+}
+
+// Release takes back one reserved attempt
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.states[key]; ok && state.Failures > 0 {
+		state.Failures--
+		s.states[key] = state
+	}
+	return nil
+}
+
+// Lock locks key until the given time unless it is already locked
+func (s *MemoryStore) Lock(ctx context.Context, key string, now, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.states[key]
+	if now.Before(state.LockedUntil) {
+		return false, nil
+	}
+	state.LockedUntil = until
+	state.Failures = 0
+	s.states[key] = state
+	return true, nil
+}
+
+// Reset forgets key
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// Prune forgets keys with no failure or lock after before
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, state := range s.states {
+		if state.LastFailure.Before(before) && state.LockedUntil.Before(before) {
+			delete(s.states, key)
+		}
+	}
+	return nil
+}
@@ -1,16 +1,17 @@
 package middleware
 
 import (
-	"fmt"
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/rbac"
+	"coachella-backend/internal/signing"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"strings"
-	"os"
+	"time"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
 // AuthMiddleware checks the validity of a JWT token and sets claims in the context
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -32,14 +33,10 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Remove "Bearer " prefix to extract the token
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 
-		// Parse and validate the JWT token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Ensure the token's signing method is HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtSecret, nil
-		})
+		// Parse and validate the JWT token against the key named in its kid
+		// header. Single-purpose tokens, such as email verification links,
+		// are for other audiences.
+		token, err := signing.Parse(tokenString, signing.AudienceAccess)
 
 		// Handle token parsing errors
 		if err != nil || !token.Valid {
@@ -56,27 +53,66 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set claims in the context for downstream handlers
-		if userID, ok := claims["id"]; ok {
-			c.Set("id", userID)
-		}
-		if email, ok := claims["email"]; ok {
-			c.Set("email", email)
+		principal, ok := principalFromClaims(claims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			c.Abort()
+			return
 		}
-		if role, ok := claims["type"]; ok {
-			c.Set("role", role)
+
+		// Logging out and changing the password revoke a token's session
+		if !SessionActive(principal) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+			c.Abort()
+			return
 		}
 
+		// Set the principal in the context for downstream handlers
+		c.Set(principalKey, principal)
+
 		// Proceed to the next handler
 		c.Next()
 	}
 }
 
+// StreamTicketMiddleware authenticates the notification stream with the
+// short-lived ticket in the ticket query parameter. Browser EventSource cannot
+// set headers, and an access token in the URL would end up in access logs.
+// Tickets have their own audience, so access tokens can't open a stream from
+// a URL.
+func StreamTicketMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := signing.Parse(c.Query("ticket"), signing.AudienceStream)
+		if err != nil || !token.Valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired stream ticket"})
+			c.Abort()
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired stream ticket"})
+			c.Abort()
+			return
+		}
+
+		principal, ok := principalFromClaims(claims)
+		if !ok || !SessionActive(principal) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
 // RoleMiddleware restricts access based on the user's role
 func RoleMiddleware(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Extract the user's role from the context (set by AuthMiddleware)
-		role, exists := c.Get("role")
+		// Extract the account type from the principal (set by AuthMiddleware)
+		principal, exists := CurrentPrincipal(c)
 		if !exists {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Role information missing"})
 			c.Abort()
@@ -84,7 +120,7 @@ func RoleMiddleware(requiredRole string) gin.HandlerFunc {
 		}
 
 		// Check if the user's role matches the required role
-		if role != requiredRole {
+		if principal.AccountType != requiredRole {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			c.Abort()
 			return
@@ -94,3 +130,50 @@ func RoleMiddleware(requiredRole string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SessionActive reports whether the principal's session exists for its
+// account, has not been revoked or expired, and its account still exists
+func SessionActive(principal Principal) bool {
+	// Deleted accounts are soft-deleted, which the model queries leave out
+	account := config.DB.Model(&models.User{}).Select("user_id").Where("user_id = ?", principal.ID)
+	if principal.IsAdmin() {
+		account = config.DB.Model(&models.Admin{}).Select("admin_id").Where("admin_id = ?", principal.ID)
+	}
+
+	var count int64
+	config.DB.Model(&models.Session{}).
+		Where("session_id = ? AND account_type = ? AND account_id = ?", principal.SessionID, principal.AccountType, principal.ID).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Where("EXISTS (?)", account).
+		Count(&count)
+	return count > 0
+}
+
+// RequirePermission allows the request only if the account holds the
+// permission through one of its roles. Must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Nested groups reuse the permissions looked up for the first check
+		permissions, _ := c.Get("permissions")
+		granted, ok := permissions.(map[string]bool)
+		if !ok {
+			principal, _ := CurrentPrincipal(c)
+
+			var err error
+			granted, err = rbac.PermissionsOf(principal.AccountType, principal.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+				c.Abort()
+				return
+			}
+			c.Set("permissions", granted)
+		}
+
+		if !granted[permission] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + permission})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
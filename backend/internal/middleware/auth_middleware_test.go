@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/signing"
+	"coachella-backend/internal/testdb"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokensOnlyWorkForTheirAudience(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testdb.Use(t)
+	t.Setenv("JWT_KEY_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err := signing.Load(); err != nil {
+		t.Fatalf("load signing keys: %v", err)
+	}
+
+	user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x"}
+	testdb.Create(t, &user)
+	session := models.Session{AccountType: "user", AccountID: user.UserID, ExpiresAt: time.Now().Add(time.Hour)}
+	testdb.Create(t, &session)
+
+	// Every token carries the claims of a valid access token, so only the
+	// audience tells them apart
+	tokens := make(map[string]string)
+	for _, audience := range []string{signing.AudienceAccess, signing.AudienceMFA, signing.AudienceVerifyEmail, signing.AudienceStream} {
+		token, err := signing.Sign(audience, jwt.MapClaims{
+			"id":    user.UserID,
+			"email": user.Email,
+			"type":  "user",
+			"sid":   session.SessionID,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		tokens[audience] = token
+	}
+
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/me", AuthMiddleware(), ok)
+	router.GET("/stream", StreamTicketMiddleware(), ok)
+
+	for audience, token := range tokens {
+		t.Run(audience, func(t *testing.T) {
+			bearer := httptest.NewRequest(http.MethodGet, "/me", nil)
+			bearer.Header.Set("Authorization", "Bearer "+token)
+			ticket := httptest.NewRequest(http.MethodGet, "/stream?ticket="+url.QueryEscape(token), nil)
+
+			for _, tt := range []struct {
+				request *http.Request
+				accepts string
+			}{
+				{bearer, signing.AudienceAccess},
+				{ticket, signing.AudienceStream},
+			} {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, tt.request)
+
+				want := http.StatusUnauthorized
+				if audience == tt.accepts {
+					want = http.StatusOK
+				}
+				if recorder.Code != want {
+					t.Errorf("%s: status = %d, want %d", tt.request.URL.Path, recorder.Code, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// principalKey is the context key AuthMiddleware stores the Principal under
+const principalKey = "principal"
+
+// Principal is the authenticated account behind a request. Handlers take the
+// caller's identity from it, never from query parameters or request bodies.
+type Principal struct {
+	AccountType string // "user" or "admin"
+	ID          uint
+	Email       string
+	SessionID   uint
+}
+
+// IsUser reports whether the principal is a user account
+func (p Principal) IsUser() bool {
+	return p.AccountType == "user"
+}
+
+// IsAdmin reports whether the principal is a staff account
+func (p Principal) IsAdmin() bool {
+	return p.AccountType == "admin"
+}
+
+// CurrentPrincipal returns the principal set in the context by AuthMiddleware
+func CurrentPrincipal(c *gin.Context) (Principal, bool) {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return Principal{}, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// principalFromClaims reads the principal from access token claims
+func principalFromClaims(claims jwt.MapClaims) (Principal, bool) {
+	// JWT numeric claims are decoded as float64
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return Principal{}, false
+	}
+	sessionID, ok := claims["sid"].(float64)
+	if !ok {
+		return Principal{}, false
+	}
+	accountType, _ := claims["type"].(string)
+	if accountType != "user" && accountType != "admin" {
+		return Principal{}, false
+	}
+	email, _ := claims["email"].(string)
+	return Principal{AccountType: accountType, ID: uint(id), Email: email, SessionID: uint(sessionID)}, true
+}
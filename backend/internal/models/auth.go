@@ -6,5 +6,21 @@ type LoginRequest struct {
 }
 
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token         string   `json:"token"`                    // Access token
+	ExpiresIn     int      `json:"expires_in"`               // Access token lifetime in seconds
+	RefreshToken  string   `json:"refresh_token"`            // Single use; exchange at /auth/refresh before the access token expires
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // Set once, when a login challenge completes 2FA enrollment
+}
+
+// RegisterRequest creates a user account
+type RegisterRequest struct {
+	Name     string `json:"name" binding:"required" example:"Jane Doe"`
+	Email    string `json:"email" binding:"required" example:"jane@example.com"`
+	Password string `json:"password" binding:"required" example:"correct-horse-42"` // At least 8 characters with a letter and a digit
+	Language string `json:"language" example:"en"`                                  // Email language: en (default) or id
+}
+
+// EmailRequest identifies an account by email address
+type EmailRequest struct {
+	Email string `json:"email" binding:"required" example:"jane@example.com"`
 }
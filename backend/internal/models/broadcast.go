@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Broadcast is an operations message to a segment of an event's attendees,
+// sent through the notification system at SendAt
+type Broadcast struct {
+	BroadcastID      uint       `gorm:"primaryKey" json:"broadcast_id"`
+	EventID          uint       `gorm:"not null;index" json:"event_id"`
+	Event            Event      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Segment          string     `gorm:"type:enum('Paid','TicketType','Batch','Waitlisted','CheckedIn');not null" json:"segment" example:"Paid"`
+	TicketID         *uint      `json:"ticket_id"` // Set for the TicketType segment, and optionally Waitlisted
+	Batch            *int       `json:"batch"`     // Set for the Batch segment
+	NotificationType string     `gorm:"type:varchar(50);not null" json:"notification_type" example:"Update"`
+	Title            string     `gorm:"type:varchar(255);not null" json:"title" example:"Gates open late"`
+	Content          string     `gorm:"type:text;not null" json:"content" example:"Gates open at 13:00 due to wind."`
+	Urgent           bool       `gorm:"not null;default:false" json:"urgent"` // Delivered during recipients' quiet hours
+	SendAt           time.Time  `gorm:"not null;index" json:"send_at"`
+	Status           string     `gorm:"type:enum('Scheduled','Sending','Sent','Cancelled');default:'Scheduled';not null;index" json:"status"`
+	RecipientCount   int        `gorm:"not null;default:0" json:"recipient_count"` // Fixed when sending starts
+	SentCount        int        `gorm:"not null;default:0" json:"sent_count"`
+	FailedCount      int        `gorm:"not null;default:0" json:"failed_count"` // Recipients given up on after repeated failures
+	CompletedAt      *time.Time `json:"completed_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// BroadcastRecipient is a user a broadcast resolved to, so a broadcast that
+// is rate limited across several runs notifies each user exactly once
+type BroadcastRecipient struct {
+	BroadcastRecipientID uint       `gorm:"primaryKey" json:"broadcast_recipient_id"`
+	BroadcastID          uint       `gorm:"not null;uniqueIndex:idx_broadcast_recipients_broadcast_user" json:"broadcast_id"`
+	Broadcast            Broadcast  `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	UserID               uint       `gorm:"not null;uniqueIndex:idx_broadcast_recipients_broadcast_user" json:"user_id"`
+	User                 User       `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	SentAt               *time.Time `json:"sent_at"`
+	Attempts             int        `gorm:"not null;default:0" json:"attempts"` // Failed attempts to notify the user
+	FailedAt             *time.Time `json:"failed_at"`                         // Set when no more attempts are made
+}
+
+// BroadcastRequest creates a broadcast or previews its audience
+type BroadcastRequest struct {
+	Segment          string     `json:"segment" binding:"required" example:"Paid"` // Paid, TicketType, Batch, Waitlisted or CheckedIn
+	TicketID         *uint      `json:"ticket_id"`
+	Batch            *int       `json:"batch"`
+	NotificationType string     `json:"notification_type" example:"Update"` // Update, ScheduleChange or Marketing; defaults to Update
+	Title            string     `json:"title" example:"Gates open late"`
+	Content          string     `json:"content" example:"Gates open at 13:00 due to wind."`
+	Urgent           bool       `json:"urgent"`                                 // Deliver during quiet hours instead of holding until they end; not for Marketing
+	SendAt           *time.Time `json:"send_at" example:"2025-04-11T12:00:00Z"` // Omit to send straight away
+}
+
+// BroadcastPreview is the audience a broadcast would reach if sent now
+type BroadcastPreview struct {
+	RecipientCount int64 `json:"recipient_count"`
+}
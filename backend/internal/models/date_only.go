@@ -74,3 +74,13 @@ func (DateOnly) SwaggerType() string {
 func (DateOnly) SwaggerFormat() string {
 	return "date"
 }
+
+// In returns midnight of the date in loc
+func (d DateOnly) In(loc *time.Location) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+}
+
+// SameDay reports whether both dates fall on the same calendar day
+func (d DateOnly) SameDay(other DateOnly) bool {
+	return d.Format("2006-01-02") == other.Format("2006-01-02")
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// DeviceToken is a mobile device registered for push notifications
+type DeviceToken struct {
+	DeviceTokenID uint      `gorm:"primaryKey" json:"device_token_id"`
+	UserID        uint      `gorm:"not null;index" json:"user_id"`
+	User          User      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Token         string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"token"`
+	Platform      string    `gorm:"type:enum('android','ios','web');not null" json:"platform" example:"android"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DeviceTokenRequest is the payload for registering a device
+type DeviceTokenRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Platform string `json:"platform" binding:"required" example:"android"`
+}
+
+// PhoneRequest is the payload for setting a user's SMS number
+type PhoneRequest struct {
+	Phone string `json:"phone" binding:"required" example:"+6281234567890"` // E.164
+}
+
+// NotificationDelivery is an SMS or push send of a notification, queued and
+// retried like outbox email. It doubles as the delivery receipt.
+type NotificationDelivery struct {
+	DeliveryID        uint          `gorm:"primaryKey" json:"delivery_id"`
+	NotificationID    *uint         `gorm:"index" json:"notification_id"` // Nil when the user has in-app notifications off
+	Notification      *Notification `gorm:"constraint:OnDelete:SET NULL;" json:"-"`
+	UserID            uint          `gorm:"not null;index" json:"user_id"`
+	User              User          `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	NotificationType  string        `gorm:"type:varchar(50);not null" json:"notification_type"`
+	Channel           string        `gorm:"type:enum('SMS','Push');not null" json:"channel"`
+	Destination       string        `gorm:"type:varchar(255);not null" json:"-"` // Phone number or device token
+	Title             string        `gorm:"type:varchar(255)" json:"title"`
+	Body              string        `gorm:"type:text" json:"body"`
+	Status            string        `gorm:"type:enum('Pending','Sending','Sent','Delivered','Failed');not null;index:idx_notification_deliveries_status_next" json:"status"`
+	Attempts          int           `gorm:"not null" json:"attempts"`
+	NextAttemptAt     time.Time     `gorm:"not null;index:idx_notification_deliveries_status_next" json:"next_attempt_at"`
+	LockedUntil       *time.Time    `json:"-"`
+	Provider          string        `gorm:"type:varchar(50)" json:"provider"`
+	ProviderMessageID string        `gorm:"type:varchar(255);index" json:"provider_message_id"`
+	LastError         string        `gorm:"type:text" json:"last_error"`
+	SentAt            *time.Time    `json:"sent_at"`
+	DeliveredAt       *time.Time    `json:"delivered_at"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
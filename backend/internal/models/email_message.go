@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// EmailMessage is an email in the outbox. Messages are delivered by the email
+// worker pool and retried with backoff until they are Sent or go Dead.
+type EmailMessage struct {
+	EmailID       uint              `gorm:"primaryKey" json:"email_id"`
+	To            string            `gorm:"type:varchar(255);not null" json:"to"`
+	Subject       string            `gorm:"type:varchar(255);not null" json:"subject"`
+	HTMLBody      string            `gorm:"type:mediumtext" json:"html_body"`
+	TextBody      string            `gorm:"type:mediumtext" json:"text_body"`
+	Headers       string            `gorm:"type:text" json:"-"` // Extra headers as a JSON object
+	Status        string            `gorm:"type:enum('Pending','Sending','Sent','Dead');not null;index:idx_email_messages_status_next" json:"status"`
+	Attempts      int               `gorm:"not null" json:"attempts"`
+	NextAttemptAt time.Time         `gorm:"not null;index:idx_email_messages_status_next" json:"next_attempt_at"`
+	LockedUntil   *time.Time        `json:"-"` // Claim expiry of the worker delivering the message
+	LastError     string            `gorm:"type:text" json:"last_error"`
+	SentAt        *time.Time        `json:"sent_at"`
+	Attachments   []EmailAttachment `gorm:"foreignKey:EmailID;constraint:OnDelete:CASCADE;" json:"attachments,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// EmailAttachment is a file attached to an outbox message
+type EmailAttachment struct {
+	AttachmentID uint   `gorm:"primaryKey" json:"attachment_id"`
+	EmailID      uint   `gorm:"not null;index" json:"email_id"`
+	Filename     string `gorm:"type:varchar(255);not null" json:"filename"`
+	ContentType  string `gorm:"type:varchar(255);not null" json:"content_type"`
+	Data         []byte `gorm:"type:longblob" json:"-"`
+}
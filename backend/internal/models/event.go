@@ -1,17 +1,134 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
 
+	"gorm.io/gorm"
+)
 
 type Event struct {
-	EventID         uint            `gorm:"primaryKey" json:"event_id"`
-	Name            string          `gorm:"type:varchar(255)" json:"name"`
-	Description     string          `gorm:"type:text" json:"description"`
-	LocationCity    string          `gorm:"type:varchar(255)" json:"location_city"`
-	LocationState   string          `gorm:"type:varchar(255)" json:"location_state"`
-	LocationCountry string          `gorm:"type:varchar(255)" json:"location_country"`
-	StartDate       DateOnly `json:"start_date"` // Indonesian date format
-	EndDate         DateOnly `json:"end_date"`
-	CreatedAt       time.Time       `json:"created_at"`
-	UpdatedAt       time.Time       `json:"updated_at"`
+	EventID         uint       `gorm:"primaryKey" json:"event_id"`
+	Name            string     `gorm:"type:varchar(255)" json:"name"`
+	Description     string     `gorm:"type:text" json:"description"`
+	LocationCity    string     `gorm:"type:varchar(255)" json:"location_city"`
+	LocationState   string     `gorm:"type:varchar(255)" json:"location_state"`
+	LocationCountry string     `gorm:"type:varchar(255)" json:"location_country"`
+	Timezone        string     `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone" example:"America/Los_Angeles"` // IANA zone of the venue
+	StartDate       DateOnly   `json:"start_date"`                                                                            // Legacy DD-MM-YYYY date, derived from DoorsOpenAt when omitted
+	EndDate         DateOnly   `json:"end_date"`                                                                              // Legacy DD-MM-YYYY date, derived from CurfewAt when omitted
+	DoorsOpenAt     *time.Time `json:"doors_open_at" example:"2025-04-11T11:00:00-07:00"`                                     // RFC 3339, shown in the venue's zone
+	FirstSetAt      *time.Time `json:"first_set_at" example:"2025-04-11T12:00:00-07:00"`
+	CurfewAt        *time.Time `json:"curfew_at" example:"2025-04-13T23:59:00-07:00"`
+	ArchivedAt      *time.Time `gorm:"index" json:"archived_at"` // Archived events are hidden from public listings
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Unarchived is a query scope that leaves out archived events
+func Unarchived(db *gorm.DB) *gorm.DB {
+	return db.Where("events.archived_at IS NULL")
+}
+
+// Location returns the venue's time zone, falling back to UTC when it is unset or unknown
+func (e Event) Location() *time.Location {
+	loc, err := time.LoadLocation(e.Timezone)
+	if err != nil || e.Timezone == "" {
+		return time.UTC
+	}
+	return loc
+}
+
+// Venue returns the event location as a single line, e.g. "Indio, California, United States"
+func (e Event) Venue() string {
+	var parts []string
+	for _, part := range []string{e.LocationCity, e.LocationState, e.LocationCountry} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BeforeSave fills the legacy dates from the timestamps when they are missing
+func (e *Event) BeforeSave(tx *gorm.DB) error {
+	if e.StartDate.IsZero() && e.DoorsOpenAt != nil {
+		e.StartDate = e.LocalDate(*e.DoorsOpenAt)
+	}
+	if e.EndDate.IsZero() && e.CurfewAt != nil {
+		e.EndDate = e.LocalDate(*e.CurfewAt)
+	}
+	return nil
+}
+
+// AfterSave presents the timestamps in the venue's time zone
+func (e *Event) AfterSave(tx *gorm.DB) error {
+	e.localizeTimes()
+	return nil
+}
+
+// AfterFind presents the timestamps in the venue's time zone
+func (e *Event) AfterFind(tx *gorm.DB) error {
+	e.localizeTimes()
+	return nil
+}
+
+func (e *Event) localizeTimes() {
+	loc := e.Location()
+	for _, field := range []**time.Time{&e.DoorsOpenAt, &e.FirstSetAt, &e.CurfewAt} {
+		if *field != nil {
+			local := (*field).In(loc)
+			*field = &local
+		}
+	}
+}
+
+// LocalDate returns the calendar date of t at the venue
+func (e Event) LocalDate(t time.Time) DateOnly {
+	local := t.In(e.Location())
+	return DateOnly{time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+// StartsAt returns when doors open, or midnight at the venue on the start date
+func (e Event) StartsAt() time.Time {
+	if e.DoorsOpenAt != nil {
+		return *e.DoorsOpenAt
+	}
+	return e.StartDate.In(e.Location())
+}
+
+// EndsAt returns the curfew, or midnight at the venue after the end date
+func (e Event) EndsAt() time.Time {
+	if e.CurfewAt != nil {
+		return *e.CurfewAt
+	}
+	end := e.EndDate
+	if end.IsZero() {
+		end = e.StartDate
+	}
+	return end.In(e.Location()).AddDate(0, 0, 1)
+}
+
+// GatesOpen reports whether now falls between doors and curfew on one of the
+// event's days, at the venue's clock. Doors and curfew on the first and last
+// day set the hours of every day; without them, whole days count.
+func (e Event) GatesOpen(now time.Time) bool {
+	if now.Before(e.StartsAt()) || now.After(e.EndsAt()) {
+		return false
+	}
+	if e.DoorsOpenAt == nil || e.CurfewAt == nil {
+		return true
+	}
+	loc := e.Location()
+	doors, curfew, clock := timeOfDay(e.DoorsOpenAt.In(loc)), timeOfDay(e.CurfewAt.In(loc)), timeOfDay(now.In(loc))
+	if doors < curfew {
+		return clock >= doors && clock <= curfew
+	}
+	// A curfew after midnight closes the night that began the day before
+	return clock >= doors || clock <= curfew
+}
+
+// timeOfDay returns how long after midnight t is on its clock
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
 }
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Favorite is a performance a user added to their personal schedule
+type Favorite struct {
+	FavoriteID     uint        `gorm:"primaryKey" json:"favorite_id"`
+	UserID         uint        `gorm:"not null;uniqueIndex:idx_favorites_user_performance" json:"user_id"`
+	User           User        `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	PerformanceID  uint        `gorm:"not null;uniqueIndex:idx_favorites_user_performance;index" json:"performance_id" example:"1"`
+	Performance    Performance `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Remind         bool        `json:"remind"`           // Send a "starting soon" notification
+	ReminderSentAt *time.Time  `json:"reminder_sent_at"` // Cleared when the set time changes
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// FavoriteRequest is the payload for favoriting a performance
+type FavoriteRequest struct {
+	PerformanceID uint `json:"performance_id" binding:"required" example:"1"`
+	Remind        bool `json:"remind"`
+}
+
+// FavoriteUpdateRequest is the payload for changing a favorite's reminder setting
+type FavoriteUpdateRequest struct {
+	Remind bool `json:"remind"`
+}
+
+// PersonalSchedule is a user's favorited sets for one event with detected clashes
+type PersonalSchedule struct {
+	EventID uint                  `json:"event_id"`
+	Days    []PersonalScheduleDay `json:"days"`
+	Clashes []ScheduleClash       `json:"clashes"`
+}
+
+// PersonalScheduleDay is the timeline of favorited sets on one day
+type PersonalScheduleDay struct {
+	Day          string                  `json:"day" example:"2025-04-11"`
+	Performances []PersonalScheduleEntry `json:"performances"`
+}
+
+// PersonalScheduleEntry is a set on a personal timeline or a suggested alternative
+type PersonalScheduleEntry struct {
+	FavoriteID    uint      `json:"favorite_id,omitempty"`
+	PerformanceID uint      `json:"performance_id"`
+	ArtistID      uint      `json:"artist_id"`
+	ArtistName    string    `json:"artist_name"`
+	StageID       uint      `json:"stage_id"`
+	StageName     string    `json:"stage_name"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Remind        bool      `json:"remind"`
+	Clash         bool      `json:"clash"`
+}
+
+// ScheduleClash describes two favorited sets that overlap
+type ScheduleClash struct {
+	PerformanceIDs []uint                  `json:"performance_ids"`
+	OverlapMinutes int                     `json:"overlap_minutes"`
+	Alternatives   []PersonalScheduleEntry `json:"alternatives"` // Other sets by the same artists that fit the schedule
+}
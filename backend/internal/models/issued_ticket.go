@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// IssuedTicket is a single admission from a purchase, identified at the gate
+// by the Code in its QR code. A ticket is only valid while its transaction is.
+type IssuedTicket struct {
+	IssuedTicketID uint        `gorm:"primaryKey" json:"issued_ticket_id"`
+	TransactionID  uint        `gorm:"not null;index" json:"transaction_id"`
+	Transaction    Transaction `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	TicketID       uint        `gorm:"not null;index" json:"ticket_id"`
+	Ticket         Ticket      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Code           string      `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	AttendeeName   string      `gorm:"type:varchar(255);not null" json:"attendee_name"`
+	SeatID         *uint       `json:"seat_id"` // Set for reserved seating
+	Seat           *Seat       `gorm:"constraint:OnDelete:SET NULL;" json:"-"`
+	CheckedInAt    *time.Time  `json:"checked_in_at"` // Set when scanned at the gate
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// CheckInRequest is a gate scan of an issued ticket's QR code
+type CheckInRequest struct {
+	Code    string `json:"code" binding:"required" example:"8f14e45fceea167a5a36dedd4bea2543"`
+	EventID uint   `json:"event_id" binding:"required" example:"1"` // The event the gate admits to
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Artist is a performer that can appear in event lineups
+type Artist struct {
+	ArtistID  uint      `gorm:"primaryKey" json:"artist_id"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name" example:"Lana Del Rey"`
+	Bio       string    `gorm:"type:text" json:"bio"`
+	ImageURL  string    `gorm:"type:varchar(500)" json:"image_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Stage is a performance area at an event
+type Stage struct {
+	StageID   uint      `gorm:"primaryKey" json:"stage_id"`
+	EventID   uint      `gorm:"not null;index" json:"event_id" example:"1"`
+	Event     Event     `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name" example:"Coachella Stage"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Performance is an artist's set on a stage. StartTime, EndTime and StageID
+// are the working copy edited by admins; the Published fields are what the
+// public schedule shows and are only updated when the schedule is published.
+type Performance struct {
+	PerformanceID      uint       `gorm:"primaryKey" json:"performance_id"`
+	EventID            uint       `gorm:"not null;index" json:"event_id" example:"1"`
+	Event              Event      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	ArtistID           uint       `gorm:"not null;index" json:"artist_id" example:"1"`
+	Artist             Artist     `gorm:"constraint:OnDelete:CASCADE;" json:"artist"`
+	StageID            uint       `gorm:"not null;index" json:"stage_id" example:"1"`
+	Stage              Stage      `gorm:"constraint:OnDelete:CASCADE;" json:"stage"`
+	StartTime          time.Time  `gorm:"not null" json:"start_time"`
+	EndTime            time.Time  `gorm:"not null" json:"end_time"`
+	PublishedStageID   *uint      `json:"published_stage_id"`
+	PublishedStartTime *time.Time `json:"published_start_time"`
+	PublishedEndTime   *time.Time `json:"published_end_time"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// ScheduleDay is one day of an event's public schedule
+type ScheduleDay struct {
+	Day    string          `json:"day" example:"2025-04-11"`
+	Stages []ScheduleStage `json:"stages"`
+}
+
+// ScheduleStage is one stage's running order within a schedule day
+type ScheduleStage struct {
+	StageID      uint            `json:"stage_id"`
+	Name         string          `json:"name"`
+	Performances []ScheduleEntry `json:"performances"`
+}
+
+// ScheduleEntry is a published set time
+type ScheduleEntry struct {
+	PerformanceID uint      `json:"performance_id"`
+	ArtistID      uint      `json:"artist_id"`
+	ArtistName    string    `json:"artist_name"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins on one account or from one IP
+// address, for the database lockout store shared by every instance
+type LoginAttempt struct {
+	LoginAttemptID uint      `gorm:"primaryKey"`
+	AttemptKey     string    `gorm:"type:varchar(300);not null;uniqueIndex"` // account:<type>:<email> or ip:<address>
+	Failures       int       `gorm:"not null;default:0"`
+	WindowStart    time.Time `gorm:"not null"`
+	LastFailureAt  time.Time `gorm:"not null;index"`
+	LockedUntil    *time.Time
+	UpdatedAt      time.Time
+}
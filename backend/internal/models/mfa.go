@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// MFAFactor is the TOTP authenticator of a user or admin. It only guards
+// logins once ConfirmedAt is set by a first valid code.
+type MFAFactor struct {
+	MFAFactorID    uint       `gorm:"primaryKey" json:"-"`
+	AccountType    string     `gorm:"type:enum('user','admin');not null;uniqueIndex:idx_mfa_factors_account" json:"-"`
+	AccountID      uint       `gorm:"not null;uniqueIndex:idx_mfa_factors_account" json:"-"`
+	Secret         string     `gorm:"type:varchar(64);not null" json:"-"` // Base32 TOTP secret
+	ConfirmedAt    *time.Time `json:"confirmed_at"`
+	LastUsedStep   int64      `gorm:"not null;default:0" json:"-"` // Codes of this step or earlier are rejected as replays
+	FailedAttempts int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil    *time.Time `json:"-"` // Set after too many wrong codes in a row
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode is a one-time code that stands in for a TOTP code when the
+// authenticator is lost. Only the SHA-256 hash of a code is stored.
+type RecoveryCode struct {
+	RecoveryCodeID uint       `gorm:"primaryKey"`
+	AccountType    string     `gorm:"type:enum('user','admin');not null;index:idx_recovery_codes_account"`
+	AccountID      uint       `gorm:"not null;index:idx_recovery_codes_account"`
+	CodeHash       string     `gorm:"type:char(64);not null"`
+	UsedAt         *time.Time
+	CreatedAt      time.Time
+}
+
+// MFAChallengeResponse is returned by a login that needs a second factor.
+// The MFA token completes the login at /auth/mfa/verify.
+type MFAChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required" example:"true"`
+	EnrollmentRequired bool   `json:"enrollment_required"` // Enroll an authenticator at /auth/mfa/enroll first
+	MFAToken           string `json:"mfa_token"`
+	ExpiresIn          int    `json:"expires_in"` // Seconds
+}
+
+// MFAEnrollmentResponse is a new authenticator secret to scan or type in
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/Coachella:jane@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Coachella"`
+	QRCode     string `json:"qr_code"` // PNG data URI of the otpauth URI
+}
+
+// MFAStatusResponse describes an account's two-factor authentication
+type MFAStatusResponse struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"` // Enforced for the account's role
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// MFACodeRequest carries a TOTP code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// MFAChallengeRequest carries the MFA token of a login challenge
+type MFAChallengeRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFAVerifyRequest completes a login challenge with a TOTP or recovery code
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"3f9a1-c07be"`
+}
+
+// MFADisableRequest turns two-factor authentication off
+type MFADisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"` // TOTP or recovery code
+}
+
+// RecoveryCodesResponse lists new recovery codes. They are shown only once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
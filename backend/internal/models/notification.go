@@ -2,13 +2,35 @@ package models
 
 import "time"
 
+// Notification is an in-app message for a user. It is unread until ReadAt is set.
+// Types are Confirmation, Reminder, Update, WaitlistOffer, PaymentStatus and ScheduleChange.
 type Notification struct {
-	NotificationID  uint      `gorm:"primaryKey" json:"notification_id"`
-	UserID          uint      `gorm:"not null" json:"user_id"` // Foreign key
-	User            User      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
-	NotificationType string   `gorm:"type:varchar(50);not null" json:"notification_type"`
-	Content         string    `gorm:"type:text" json:"content"`
-	SentAt          *time.Time `json:"sent_at"` // Timestamp when sent
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	NotificationID   uint       `gorm:"primaryKey" json:"notification_id"`
+	UserID           uint       `gorm:"not null;index:idx_notifications_user_read" json:"user_id"` // Foreign key
+	User             User       `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	NotificationType string     `gorm:"type:varchar(50);not null" json:"notification_type"`
+	Content          string     `gorm:"type:text" json:"content"`
+	SentAt           *time.Time `json:"sent_at"` // Timestamp when sent
+	ReadAt           *time.Time `gorm:"index:idx_notifications_user_read" json:"read_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// NotificationPage is one page of a user's notifications, newest first
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	Page          int            `json:"page" example:"1"`
+	PerPage       int            `json:"per_page" example:"20"`
+	Total         int64          `json:"total" example:"42"`
+}
+
+// UnreadCountResponse contains the number of unread notifications
+type UnreadCountResponse struct {
+	UnreadCount int64 `json:"unread_count" example:"3"`
+}
+
+// StreamTicketResponse carries a short-lived ticket for opening the notification stream
+type StreamTicketResponse struct {
+	Ticket    string `json:"ticket"`                  // Pass as the ticket query parameter of /notifications/stream
+	ExpiresIn int    `json:"expires_in" example:"60"` // Seconds left to open the stream with it
 }
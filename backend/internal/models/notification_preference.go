@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// NotificationPreference turns one channel on or off for one notification type.
+// Without a row the channel's default applies.
+type NotificationPreference struct {
+	PreferenceID     uint      `gorm:"primaryKey" json:"-"`
+	UserID           uint      `gorm:"not null;uniqueIndex:idx_notification_preferences_user_type_channel" json:"-"`
+	User             User      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	NotificationType string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_notification_preferences_user_type_channel" json:"notification_type" example:"Marketing"`
+	Channel          string    `gorm:"type:enum('InApp','Email','SMS','Push');not null;uniqueIndex:idx_notification_preferences_user_type_channel" json:"channel" example:"Email"`
+	Enabled          bool      `gorm:"not null" json:"enabled"`
+	UpdatedAt        time.Time `json:"-"`
+}
+
+// NotificationSettings is a user's full preference matrix and quiet hours
+type NotificationSettings struct {
+	Preferences     []NotificationPreference `json:"preferences"`
+	QuietHoursStart *string                  `json:"quiet_hours_start" example:"22:00"` // HH:MM; nil disables quiet hours
+	QuietHoursEnd   *string                  `json:"quiet_hours_end" example:"07:00"`
+	Timezone        string                   `json:"timezone" example:"Asia/Jakarta"` // IANA zone the quiet hours are in
+}
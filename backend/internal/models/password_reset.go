@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// PasswordResetToken is a single-use password reset link sent to a user or
+// admin. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ResetTokenID uint       `gorm:"primaryKey" json:"reset_token_id"`
+	AccountType  string     `gorm:"type:enum('user','admin');not null;index:idx_password_reset_tokens_account" json:"account_type"`
+	AccountID    uint       `gorm:"not null;index:idx_password_reset_tokens_account" json:"account_id"`
+	TokenHash    string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ResetPasswordRequest sets a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" form:"token" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required" example:"correct-horse-42"`
+}
+
+// ChangePasswordRequest replaces the password of the logged-in account
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required" example:"correct-horse-42"`
+}
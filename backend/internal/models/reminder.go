@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ReminderRule schedules a reminder email a fixed time before an event's doors open
+type ReminderRule struct {
+	RuleID        uint      `gorm:"primaryKey" json:"rule_id"`
+	EventID       uint      `gorm:"not null;index" json:"event_id" example:"1"`
+	Event         Event     `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	OffsetMinutes int       `gorm:"not null" json:"offset_minutes" example:"10080"`                           // Minutes before doors open
+	Template      string    `gorm:"type:varchar(100);not null" json:"template" example:"event_reminder_week"` // Email template name
+	Subject       string    `gorm:"type:varchar(255)" json:"subject" example:"One week to go!"`               // Replaces the template's subject when set
+	Enabled       bool      `gorm:"not null" json:"enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ReminderLog records a reminder sent for a purchase so it is never sent twice
+type ReminderLog struct {
+	LogID         uint        `gorm:"primaryKey" json:"log_id"`
+	TransactionID uint        `gorm:"not null;uniqueIndex:idx_reminder_logs_transaction_offset" json:"transaction_id"`
+	Transaction   Transaction `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	OffsetMinutes int         `gorm:"not null;uniqueIndex:idx_reminder_logs_transaction_offset" json:"offset_minutes"`
+	RuleID        *uint       `json:"rule_id"` // Nil for the built-in default reminder
+	SentAt        time.Time   `json:"sent_at"`
+}
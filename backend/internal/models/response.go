@@ -6,3 +6,8 @@ type GenericResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+
+// CalendarFeedResponse contains a calendar subscription URL
+type CalendarFeedResponse struct {
+	URL string `json:"url" example:"http://localhost:8080/calendar/3f9a.../events.ics"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Permission is a right checked by RequirePermission, named resource:action
+type Permission struct {
+	PermissionID uint   `gorm:"primaryKey" json:"permission_id"`
+	Name         string `gorm:"type:varchar(50);not null;uniqueIndex" json:"name" example:"checkin:scan"`
+	Description  string `gorm:"type:varchar(255)" json:"description"`
+}
+
+// Role is a named set of permissions for one type of account: staff roles
+// for admins, and roles for users
+type Role struct {
+	RoleID      uint         `gorm:"primaryKey" json:"role_id"`
+	Name        string       `gorm:"type:varchar(50);not null;uniqueIndex" json:"name" example:"gate_staff"`
+	AccountType string       `gorm:"type:enum('user','admin');not null" json:"account_type" example:"admin"`
+	Description string       `gorm:"type:varchar(255)" json:"description"`
+	BuiltIn     bool         `gorm:"not null;default:false" json:"built_in"` // Seeded roles can't be deleted
+	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// RoleGrantSeed records that the seed granted a built-in role one of its
+// permissions, so each grant is applied once: permissions added to a built-in
+// role in a later release reach existing databases, and grants an admin
+// removes stay removed
+type RoleGrantSeed struct {
+	RoleGrantSeedID uint      `gorm:"primaryKey" json:"role_grant_seed_id"`
+	RoleName        string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_role_grant_seeds_role_permission" json:"role_name"`
+	PermissionName  string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_role_grant_seeds_role_permission" json:"permission_name"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// AccountRole assigns a role to a user or admin
+type AccountRole struct {
+	AccountRoleID uint      `gorm:"primaryKey" json:"account_role_id"`
+	AccountType   string    `gorm:"type:enum('user','admin');not null;uniqueIndex:idx_account_roles_account_role" json:"account_type"`
+	AccountID     uint      `gorm:"not null;uniqueIndex:idx_account_roles_account_role" json:"account_id"`
+	RoleID        uint      `gorm:"not null;uniqueIndex:idx_account_roles_account_role" json:"role_id"`
+	Role          Role      `gorm:"constraint:OnDelete:CASCADE;" json:"role"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RoleRequest creates or updates a role
+type RoleRequest struct {
+	Name        string   `json:"name" example:"bar_staff"`
+	AccountType string   `json:"account_type" example:"admin"` // user or admin; fixed once created
+	Description string   `json:"description" example:"Scans tickets at the bar entrance"`
+	Permissions []string `json:"permissions" example:"checkin:scan"`
+}
+
+// AssignRoleRequest gives an account a role
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required" example:"gate_staff"`
+}
+
+// StaffRequest creates a staff (admin) account
+type StaffRequest struct {
+	Name     string   `json:"name" binding:"required" example:"Gate 3 Scanner"`
+	Email    string   `json:"email" binding:"required" example:"gate3@example.com"`
+	Password string   `json:"password" binding:"required" example:"correct-horse-42"`
+	Roles    []string `json:"roles" binding:"required" example:"gate_staff"`
+}
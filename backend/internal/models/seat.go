@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// VenueSection is a seated area in an event's venue layout
+type VenueSection struct {
+	SectionID uint      `gorm:"primaryKey" json:"section_id"`
+	EventID   uint      `gorm:"not null;index" json:"event_id" example:"1"`
+	Event     Event     `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name" example:"Orchestra Left"`
+	Seats     []Seat    `gorm:"foreignKey:SectionID" json:"seats,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Seat is a single reservable seat. Its price category is the Ticket it is sold as.
+type Seat struct {
+	SeatID    uint         `gorm:"primaryKey" json:"seat_id"`
+	SectionID uint         `gorm:"not null;uniqueIndex:idx_seats_section_row_number" json:"section_id"`
+	Section   VenueSection `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Row       string       `gorm:"type:varchar(10);not null;uniqueIndex:idx_seats_section_row_number" json:"row" example:"F"`
+	Number    int          `gorm:"not null;uniqueIndex:idx_seats_section_row_number" json:"number" example:"12"`
+	TicketID  uint         `gorm:"not null;index" json:"ticket_id" example:"3"` // Price category
+	Ticket    Ticket       `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// SeatHold locks a seat for one user while they complete a purchase.
+// Once TransactionID is set the seat is bound to that purchase.
+type SeatHold struct {
+	HoldID        uint      `gorm:"primaryKey" json:"hold_id"`
+	SeatID        uint      `gorm:"not null;uniqueIndex" json:"seat_id"` // At most one hold per seat
+	Seat          Seat      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	UserID        uint      `gorm:"not null;index" json:"user_id"`
+	User          User      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	TransactionID *uint     `gorm:"index" json:"transaction_id"`
+	ExpiresAt     time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SeatHoldRequest is the payload for holding seats
+type SeatHoldRequest struct {
+	SeatIDs []uint `json:"seat_ids" binding:"required,min=1"`
+}
+
+// SeatMapSection is a section of the availability map
+type SeatMapSection struct {
+	SectionID uint          `json:"section_id"`
+	Name      string        `json:"name"`
+	Seats     []SeatMapSeat `json:"seats"`
+}
+
+// SeatMapSeat is a seat with its price category and current status
+type SeatMapSeat struct {
+	SeatID        uint    `json:"seat_id"`
+	Row           string  `json:"row"`
+	Number        int     `json:"number"`
+	TicketID      uint    `json:"ticket_id"`
+	PriceCategory string  `json:"price_category" example:"VIP"`
+	Price         float64 `json:"price" example:"250.00"`
+	Status        string  `json:"status" example:"available"` // available, held or sold
+}
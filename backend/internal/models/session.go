@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Session is a login of a user or admin on one device. Access tokens name
+// their session, so revoking it cuts the device off before they expire.
+type Session struct {
+	SessionID     uint       `gorm:"primaryKey" json:"session_id"`
+	AccountType   string     `gorm:"type:enum('user','admin');not null;index:idx_sessions_account" json:"-"`
+	AccountID     uint       `gorm:"not null;index:idx_sessions_account" json:"-"`
+	UserAgent     string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IPAddress     string     `gorm:"type:varchar(45)" json:"ip_address"`
+	LastUsedAt    time.Time  `json:"last_used_at"` // Last login or refresh
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `gorm:"type:varchar(50)" json:"revoked_reason,omitempty"` // logout, logout_all, password_changed, refresh_reuse, revoked or account_deleted
+	Current       bool       `gorm:"-" json:"current"`                                 // Whether this is the caller's session
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// RefreshToken is one refresh token of a session. Each refresh uses up the
+// token and issues the next; only the SHA-256 hash of a token is stored.
+type RefreshToken struct {
+	RefreshTokenID uint       `gorm:"primaryKey" json:"refresh_token_id"`
+	SessionID      uint       `gorm:"not null;index" json:"session_id"`
+	Session        Session    `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	TokenHash      string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt         *time.Time `json:"used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// RefreshRequest exchanges a refresh token for new tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
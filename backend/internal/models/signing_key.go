@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SigningKey is a key pair for signing JWTs. The newest key whose
+// ActivatedAt has passed signs new tokens; every key until its ExpiresAt
+// verifies them and is published in the JWKS.
+type SigningKey struct {
+	SigningKeyID uint       `gorm:"primaryKey" json:"signing_key_id"`
+	KeyID        string     `gorm:"type:varchar(32);not null;uniqueIndex" json:"kid"`
+	Algorithm    string     `gorm:"type:varchar(10);not null" json:"alg" example:"EdDSA"` // EdDSA or RS256
+	PrivateKey   string     `gorm:"type:text;not null" json:"-"`                          // PKCS #8 PEM sealed with JWT_KEY_ENCRYPTION_KEY
+	PublicKey    string     `gorm:"type:text;not null" json:"public_key"`                 // PKIX PEM
+	ActivatedAt  time.Time  `gorm:"not null;index" json:"activated_at"`                   // Published ahead of this so verifiers can fetch it first
+	ExpiresAt    *time.Time `gorm:"index" json:"expires_at"`                              // Set once a newer key takes over
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Site represents a bookable camping or accommodation plot at an event
+type Site struct {
+	SiteID       uint      `gorm:"primaryKey" json:"site_id"`
+	EventID      uint      `gorm:"not null;uniqueIndex:idx_sites_event_zone_number" json:"event_id" example:"1"`
+	Event        Event     `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Category     string    `gorm:"type:varchar(50);not null;index" json:"category" example:"car-camping"` // Matches Ticket.SiteCategory
+	Zone         string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_sites_event_zone_number" json:"zone" example:"C"`
+	SiteNumber   int       `gorm:"not null;uniqueIndex:idx_sites_event_zone_number" json:"site_number" example:"42"`
+	Capacity     int       `gorm:"not null" json:"capacity" example:"4"` // Maximum number of campers
+	IsRV         bool      `json:"is_rv"`
+	IsTent       bool      `json:"is_tent"`
+	IsAccessible bool      `json:"is_accessible"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SiteAllocation binds a site to a ticket purchase
+type SiteAllocation struct {
+	AllocationID  uint        `gorm:"primaryKey" json:"allocation_id"`
+	SiteID        uint        `gorm:"not null;uniqueIndex" json:"site_id"` // A site can only be allocated once
+	Site          Site        `gorm:"constraint:OnDelete:CASCADE;" json:"site"`
+	TransactionID uint        `gorm:"not null;index" json:"transaction_id"` // Foreign key
+	Transaction   Transaction `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	UserID        uint        `gorm:"not null;index" json:"user_id"`
+	AssignedBy    string      `gorm:"type:enum('Buyer','Auto','Admin')" json:"assigned_by"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// SiteAllocationRequest is the payload for allocating sites to a purchase.
+// Leave SiteIDs empty to have sites assigned automatically.
+type SiteAllocationRequest struct {
+	SiteIDs      []uint `json:"site_ids"`
+	Zone         string `json:"zone" example:"C"` // Preferred zone for auto-assignment
+	Adjacent     bool   `json:"adjacent"`         // Keep the group's sites next to each other
+	IsRV         bool   `json:"is_rv"`
+	IsTent       bool   `json:"is_tent"`
+	IsAccessible bool   `json:"is_accessible"`
+}
+
+// SiteReassignRequest is the payload for moving an allocation to another site
+type SiteReassignRequest struct {
+	SiteID uint `json:"site_id" binding:"required"`
+}
@@ -11,15 +11,23 @@ type Ticket struct {
 	Description       string    `gorm:"type:text" json:"description" example:"VIP access to the main stage."`
 	Price             float64   `gorm:"type:decimal(10,2)" json:"price" example:"250.00"`
 	QuantityAvailable int       `gorm:"not null" json:"quantity_available" example:"100"`
-	// @swagger:ignore
-	StartDate         DateOnly  `json:"start_date"`
-	// @swagger:ignore
-	EndDate           DateOnly  `json:"end_date"`
+	SiteCategory      string    `gorm:"type:varchar(50)" json:"site_category" example:"car-camping"` // Passes that need a Site allocation
+	StartDate         DateOnly  `json:"start_date" example:"15-01-2025"`
+	EndDate           DateOnly  `json:"end_date" example:"28-02-2025"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
-	// Add replacement annotations for Swagger
-	StartDateString string `json:"start_date" example:"15-01-2025" swaggerignore:"true"`
-	EndDateString   string `json:"end_date" example:"28-02-2025" swaggerignore:"true"`
 }
 
 
+// OnSale reports whether today, in loc, falls within the ticket's sale window.
+// A missing start or end date leaves that side of the window open.
+func (t Ticket) OnSale(now time.Time, loc *time.Location) bool {
+	today := now.In(loc).Format("2006-01-02")
+	if !t.StartDate.IsZero() && today < t.StartDate.Format("2006-01-02") {
+		return false
+	}
+	if !t.EndDate.IsZero() && today > t.EndDate.Format("2006-01-02") {
+		return false
+	}
+	return true
+}
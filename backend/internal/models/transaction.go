@@ -9,10 +9,14 @@ type Transaction struct {
 	TicketID      uint      `gorm:"not null;index" json:"ticket_id"` // Foreign key
 	Ticket        Ticket    `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
 	Quantity      int       `gorm:"not null" json:"quantity"`
-	TotalPrice    float64   `gorm:"type:decimal(10,2)" json:"total_price"`
+	UnitPrice     float64   `gorm:"type:decimal(10,2)" json:"unit_price"` // Ticket price at purchase, set by the server
+	TotalPrice    float64   `gorm:"type:decimal(10,2)" json:"total_price"` // UnitPrice times Quantity, set by the server
 	PaymentStatus string    `gorm:"type:enum('Pending','Paid','Failed','Expired')" json:"payment_status"`
 	PaymentGateway string   `gorm:"type:varchar(255)" json:"payment_gateway"`
 	Timeout       time.Time `gorm:"not null" json:"timeout"` // New field for timeout
+	PaidAt        *time.Time `json:"paid_at"` // Tickets and the invoice are issued when payment is confirmed
+	SeatIDs       []uint    `gorm:"type:text;serializer:json" json:"seat_ids,omitempty"` // Held seats to bind for reserved seating
+	AttendeeNames []string  `gorm:"type:text;serializer:json" json:"attendee_names,omitempty"` // Names printed on the tickets, defaulting to the buyer's
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
@@ -6,11 +6,23 @@ import (
 )
 
 type User struct {
-	UserID    uint           `gorm:"primaryKey" json:"user_id"`
-	Name      string         `gorm:"not null" json:"name"`
-	Email     string         `gorm:"uniqueIndex;size:255;not null" json:"email"` // Changed to VARCHAR(255)
-	Password  string         `gorm:"not null" json:"-"`                          // Hashed password
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`                             // Soft delete
+	UserID          uint           `gorm:"primaryKey" json:"user_id"`
+	Name            string         `gorm:"not null" json:"name"`
+	Email           string         `gorm:"uniqueIndex;size:255;not null" json:"email"`                         // Changed to VARCHAR(255)
+	Password        string         `gorm:"not null" json:"-"`                                                  // Hashed password
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`                                                  // Purchases need a verified email
+	Phone           *string        `gorm:"type:varchar(20)" json:"phone" example:"+6281234567890"`             // E.164, for SMS notifications
+	Language        string         `gorm:"type:varchar(8);not null;default:'en'" json:"language" example:"en"` // Email language: en or id
+	QuietHoursStart *string        `gorm:"type:varchar(5)" json:"quiet_hours_start" example:"22:00"`           // Non-urgent notifications wait until QuietHoursEnd
+	QuietHoursEnd   *string        `gorm:"type:varchar(5)" json:"quiet_hours_end" example:"07:00"`
+	Timezone        string         `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone" example:"Asia/Jakarta"`
+	CalendarToken   *string        `gorm:"type:varchar(64);uniqueIndex" json:"-"` // Secret for the calendar subscription feed
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"` // Soft delete
+}
+
+// LanguageRequest sets a user's email language
+type LanguageRequest struct {
+	Language string `json:"language" binding:"required" example:"id"`
 }
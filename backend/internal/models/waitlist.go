@@ -11,3 +11,8 @@ type Waitlist struct {
     Ticket     Ticket    `gorm:"constraint:OnDelete:CASCADE;"`    // Relationship to Ticket
     CreatedAt  time.Time `json:"created_at"`
 }
+
+// WaitlistRequest joins the waitlist of a ticket; the user is the caller, or the path user for staff
+type WaitlistRequest struct {
+    TicketID uint `json:"ticket_id" binding:"required" example:"1"`
+}
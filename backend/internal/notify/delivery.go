@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/push"
+	"coachella-backend/internal/sms"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	deliveryPollInterval  = 2 * time.Second
+	deliveryClaimDuration = 2 * time.Minute
+	deliverySendTimeout   = 30 * time.Second
+	deliveryMaxAttempts   = 5
+	deliveryBackoff       = 15 * time.Second
+)
+
+// Senders are the providers for the SMS and push channels. A nil sender turns its channel off.
+type Senders struct {
+	SMS  sms.Sender
+	Push push.Sender
+}
+
+var senders Senders
+
+// StartWorkers sets the SMS and push providers and starts n goroutines that
+// deliver queued SMS and push notifications through them
+func StartWorkers(configured Senders, n int) {
+	senders = configured
+	if senders.SMS == nil && senders.Push == nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				delivery, ok := claimNextDelivery()
+				if !ok {
+					time.Sleep(deliveryPollInterval)
+					continue
+				}
+				deliver(delivery)
+			}
+		}()
+	}
+	log.Printf("Started %d SMS and push delivery workers\n", n)
+}
+
+// queueDeliveries creates a Pending delivery per phone number and device of the
+// user, for each of the SMS and push channels that are on and configured
+func queueDeliveries(user models.User, message Message, notificationID *uint, channels map[string]bool, sendAt time.Time) error {
+	var deliveries []models.NotificationDelivery
+	newDelivery := func(channel, destination string) models.NotificationDelivery {
+		return models.NotificationDelivery{
+			NotificationID:   notificationID,
+			UserID:           user.UserID,
+			NotificationType: message.Type,
+			Channel:          channel,
+			Destination:      destination,
+			Title:            message.title(),
+			Body:             message.Content,
+			Status:           "Pending",
+			NextAttemptAt:    sendAt,
+		}
+	}
+
+	if channels[ChannelSMS] && senders.SMS != nil && user.Phone != nil {
+		deliveries = append(deliveries, newDelivery(ChannelSMS, *user.Phone))
+	}
+	if channels[ChannelPush] && senders.Push != nil {
+		var devices []models.DeviceToken
+		if err := config.DB.Where("user_id = ?", user.UserID).Find(&devices).Error; err != nil {
+			return err
+		}
+		for _, device := range devices {
+			deliveries = append(deliveries, newDelivery(ChannelPush, device.Token))
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return config.DB.Create(&deliveries).Error
+}
+
+// claimNextDelivery takes the oldest due delivery, like the email outbox
+func claimNextDelivery() (models.NotificationDelivery, bool) {
+	var delivery models.NotificationDelivery
+	now := time.Now()
+	due := "(status = 'Pending' AND next_attempt_at <= ?) OR (status = 'Sending' AND locked_until < ?)"
+	if err := config.DB.Where(due, now, now).Order("next_attempt_at").First(&delivery).Error; err != nil {
+		return delivery, false
+	}
+
+	result := config.DB.Model(&models.NotificationDelivery{}).
+		Where("delivery_id = ?", delivery.DeliveryID).
+		Where(due, now, now).
+		Updates(map[string]interface{}{"status": "Sending", "locked_until": now.Add(deliveryClaimDuration)})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return delivery, false
+	}
+	return delivery, true
+}
+
+// deliver sends a claimed delivery and records the receipt
+func deliver(delivery models.NotificationDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), deliverySendTimeout)
+	defer cancel()
+
+	var provider, messageID string
+	var err error
+	switch {
+	case delivery.Channel == ChannelSMS && senders.SMS != nil:
+		provider = senders.SMS.Name()
+		messageID, err = senders.SMS.Send(ctx, sms.Message{To: delivery.Destination, Body: delivery.Body})
+	case delivery.Channel == ChannelPush && senders.Push != nil:
+		provider = senders.Push.Name()
+		data := map[string]string{"notification_type": delivery.NotificationType}
+		if delivery.NotificationID != nil {
+			data["notification_id"] = strconv.FormatUint(uint64(*delivery.NotificationID), 10)
+		}
+		messageID, err = senders.Push.Send(ctx, push.Message{Token: delivery.Destination, Title: delivery.Title, Body: delivery.Body, Data: data})
+	default:
+		err = errors.New("no provider configured for " + delivery.Channel)
+	}
+
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts, "locked_until": nil, "provider": provider}
+	switch {
+	case err == nil:
+		updates["status"] = "Sent"
+		updates["provider_message_id"] = messageID
+		updates["sent_at"] = time.Now()
+		updates["last_error"] = ""
+	case errors.Is(err, push.ErrInvalidToken):
+		// The app was uninstalled or the token rotated; stop sending to it
+		config.DB.Where("token = ?", delivery.Destination).Delete(&models.DeviceToken{})
+		updates["status"] = "Failed"
+		updates["last_error"] = err.Error()
+	case attempts >= deliveryMaxAttempts:
+		updates["status"] = "Failed"
+		updates["last_error"] = err.Error()
+		log.Printf("%s delivery %d failed after %d attempts: %v\n", delivery.Channel, delivery.DeliveryID, attempts, err)
+	default:
+		updates["status"] = "Pending"
+		updates["last_error"] = err.Error()
+		updates["next_attempt_at"] = time.Now().Add(deliveryBackoff << (attempts - 1))
+	}
+	config.DB.Model(&delivery).Updates(updates)
+}
+
+// RecordDeliveryStatus applies a provider's delivery status callback to the
+// delivery with that provider message ID. delivered is false for final failures.
+func RecordDeliveryStatus(provider, providerMessageID string, delivered bool, reason string) error {
+	updates := map[string]interface{}{"status": "Delivered", "delivered_at": time.Now()}
+	if !delivered {
+		updates = map[string]interface{}{"status": "Failed", "last_error": reason}
+	}
+	return config.DB.Model(&models.NotificationDelivery{}).
+		Where("provider = ? AND provider_message_id = ?", provider, providerMessageID).
+		Updates(updates).Error
+}
@@ -0,0 +1,118 @@
+// Package notify delivers notifications to users on the channels they allow
+package notify
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/email"
+	"coachella-backend/internal/models"
+	"fmt"
+	"time"
+)
+
+// Message is a notification for one user
+type Message struct {
+	UserID  uint
+	Type    string // One of the Type constants
+	Title   string // Push title, defaulting to the app name
+	Content string // In-app, SMS and push text
+	Email   *Email // Nil when the notification has no email
+	Urgent  bool   // Send straight away during quiet hours, as urgent types always are
+}
+
+func (m Message) title() string {
+	if m.Title != "" {
+		return m.Title
+	}
+	return "Coachella"
+}
+
+// Email is the email form of a notification
+type Email struct {
+	Template    string // Registered email template name
+	Data        map[string]interface{}
+	Subject     string // Replaces the template's subject when set
+	Attachments []email.Attachment
+}
+
+// Send delivers a message on every channel the user allows for its type.
+// Non-urgent email, SMS and push are held until the user's quiet hours end;
+// in-app notifications are always stored straight away since they make no sound.
+func Send(message Message) error {
+	var user models.User
+	if err := config.DB.First(&user, message.UserID).Error; err != nil {
+		return err
+	}
+	channels, err := enabledChannels(user.UserID, message.Type)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	sendAt := now
+	if until, quiet := QuietUntil(user, now); quiet && !message.Urgent && !urgentTypes[message.Type] {
+		sendAt = until
+	}
+
+	var notificationID *uint
+	if channels[ChannelInApp] && message.Content != "" {
+		notification := models.Notification{
+			UserID:           user.UserID,
+			NotificationType: message.Type,
+			Content:          message.Content,
+			SentAt:           &now,
+		}
+		if err := config.DB.Create(&notification).Error; err != nil {
+			return fmt.Errorf("saving notification: %w", err)
+		}
+		notificationID = &notification.NotificationID
+	}
+
+	if channels[ChannelEmail] && message.Email != nil {
+		if err := sendEmail(user, message, sendAt); err != nil {
+			return fmt.Errorf("queueing email: %w", err)
+		}
+	}
+
+	if message.Content != "" {
+		if err := queueDeliveries(user, message, notificationID, channels, sendAt); err != nil {
+			return fmt.Errorf("queueing SMS and push: %w", err)
+		}
+	}
+	return nil
+}
+
+// sendEmail renders the email in the user's language and queues it. Marketing
+// email carries an unsubscribe link and List-Unsubscribe headers.
+func sendEmail(user models.User, message Message, sendAt time.Time) error {
+	data := map[string]interface{}{}
+	for key, value := range message.Email.Data {
+		data[key] = value
+	}
+	if _, ok := data["name"]; !ok {
+		data["name"] = user.Name
+	}
+
+	var headers map[string]string
+	if message.Type == TypeMarketing {
+		unsubscribeURL, err := UnsubscribeURL(user.UserID, message.Type)
+		if err != nil {
+			return err
+		}
+		data["unsubscribe_url"] = unsubscribeURL
+		headers = map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+
+	rendered, err := email.Render(message.Email.Template, user.Language, data)
+	if err != nil {
+		return err
+	}
+	if message.Email.Subject != "" {
+		rendered.Subject = message.Email.Subject
+	}
+	outgoing := rendered.Message(user.Email, message.Email.Attachments...)
+	outgoing.Headers = headers
+	return email.EnqueueAt(outgoing, sendAt)
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"fmt"
+	"time"
+)
+
+// Notification types
+const (
+	TypeConfirmation   = "Confirmation"
+	TypeReminder       = "Reminder"
+	TypeUpdate         = "Update"
+	TypeWaitlistOffer  = "WaitlistOffer"
+	TypePaymentStatus  = "PaymentStatus"
+	TypeScheduleChange = "ScheduleChange"
+	TypeMarketing      = "Marketing"
+)
+
+// Delivery channels
+const (
+	ChannelInApp = "InApp"
+	ChannelEmail = "Email"
+	ChannelSMS   = "SMS"
+	ChannelPush  = "Push"
+)
+
+// Types and Channels list every type and channel users can set preferences for
+var (
+	Types    = []string{TypeConfirmation, TypeReminder, TypeUpdate, TypeWaitlistOffer, TypePaymentStatus, TypeScheduleChange, TypeMarketing}
+	Channels = []string{ChannelInApp, ChannelEmail, ChannelSMS, ChannelPush}
+)
+
+// urgentTypes are sent straight away even during quiet hours
+var urgentTypes = map[string]bool{TypeConfirmation: true, TypePaymentStatus: true}
+
+// DefaultEnabled reports whether a channel is on for a type when the user has not chosen.
+// In-app and email are on, push is on except for marketing, and SMS is opt-in.
+func DefaultEnabled(notificationType, channel string) bool {
+	switch channel {
+	case ChannelInApp, ChannelEmail:
+		return true
+	case ChannelPush:
+		return notificationType != TypeMarketing
+	default:
+		return false
+	}
+}
+
+// ValidType reports whether users can set preferences for a notification type
+func ValidType(notificationType string) bool {
+	for _, t := range Types {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidChannel reports whether a channel exists
+func ValidChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Preferences returns a user's full preference matrix, filling in defaults
+func Preferences(userID uint) ([]models.NotificationPreference, error) {
+	var stored []models.NotificationPreference
+	if err := config.DB.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	chosen := map[string]bool{}
+	for _, preference := range stored {
+		chosen[preference.NotificationType+"/"+preference.Channel] = preference.Enabled
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(Types)*len(Channels))
+	for _, notificationType := range Types {
+		for _, channel := range Channels {
+			enabled, ok := chosen[notificationType+"/"+channel]
+			if !ok {
+				enabled = DefaultEnabled(notificationType, channel)
+			}
+			preferences = append(preferences, models.NotificationPreference{
+				UserID:           userID,
+				NotificationType: notificationType,
+				Channel:          channel,
+				Enabled:          enabled,
+			})
+		}
+	}
+	return preferences, nil
+}
+
+// SetPreference turns a channel on or off for a notification type
+func SetPreference(userID uint, notificationType, channel string, enabled bool) error {
+	preference := models.NotificationPreference{UserID: userID, NotificationType: notificationType, Channel: channel}
+	return config.DB.
+		Where(preference).
+		Assign(map[string]interface{}{"enabled": enabled}).
+		FirstOrCreate(&preference).Error
+}
+
+// enabledChannels returns the channels a user allows for a notification type
+func enabledChannels(userID uint, notificationType string) (map[string]bool, error) {
+	var stored []models.NotificationPreference
+	err := config.DB.Where("user_id = ? AND notification_type = ?", userID, notificationType).Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := map[string]bool{}
+	for _, channel := range Channels {
+		enabled[channel] = DefaultEnabled(notificationType, channel)
+	}
+	for _, preference := range stored {
+		enabled[preference.Channel] = preference.Enabled
+	}
+	return enabled, nil
+}
+
+// ParseClock parses an HH:MM time of day into minutes after midnight
+func ParseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// QuietUntil returns when the user's quiet hours end if now falls inside them.
+// Windows may cross midnight, e.g. 22:00 to 07:00.
+func QuietUntil(user models.User, now time.Time) (time.Time, bool) {
+	if user.QuietHoursStart == nil || user.QuietHoursEnd == nil {
+		return time.Time{}, false
+	}
+	start, err := ParseClock(*user.QuietHoursStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := ParseClock(*user.QuietHoursEnd)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	var quiet bool
+	if start < end {
+		quiet = minute >= start && minute < end
+	} else {
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
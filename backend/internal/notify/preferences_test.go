@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"coachella-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func TestQuietUntil(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 4, day, hour, minute, 0, 0, jakarta)
+	}
+	clock := func(value string) *string { return &value }
+
+	overnight := models.User{QuietHoursStart: clock("22:00"), QuietHoursEnd: clock("07:00"), Timezone: "Asia/Jakarta"}
+	daytime := models.User{QuietHoursStart: clock("13:00"), QuietHoursEnd: clock("15:30"), Timezone: "Asia/Jakarta"}
+
+	tests := []struct {
+		name      string
+		user      models.User
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{"overnight, before start", overnight, at(10, 21, 59), false, time.Time{}},
+		{"overnight, at start", overnight, at(10, 22, 0), true, at(11, 7, 0)},
+		{"overnight, before midnight", overnight, at(10, 23, 30), true, at(11, 7, 0)},
+		{"overnight, after midnight", overnight, at(11, 0, 30), true, at(11, 7, 0)},
+		{"overnight, last minute", overnight, at(11, 6, 59), true, at(11, 7, 0)},
+		{"overnight, at end", overnight, at(11, 7, 0), false, time.Time{}},
+		{"daytime, inside", daytime, at(10, 14, 0), true, at(10, 15, 30)},
+		{"daytime, outside", daytime, at(10, 16, 0), false, time.Time{}},
+		{"in the user's zone", overnight, time.Date(2025, 4, 10, 16, 0, 0, 0, time.UTC), true, at(11, 7, 0)},
+		{"no quiet hours", models.User{Timezone: "Asia/Jakarta"}, at(10, 23, 0), false, time.Time{}},
+		{"empty window", models.User{QuietHoursStart: clock("22:00"), QuietHoursEnd: clock("22:00")}, at(10, 22, 0), false, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := QuietUntil(tt.user, tt.now)
+			if quiet != tt.wantQuiet {
+				t.Fatalf("quiet = %v, want %v", quiet, tt.wantQuiet)
+			}
+			if !until.Equal(tt.wantUntil) {
+				t.Errorf("until = %v, want %v", until, tt.wantUntil)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"coachella-backend/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// UnsubscribeToken signs a user ID and notification type with UNSUBSCRIBE_SECRET
+func UnsubscribeToken(userID uint, notificationType string) (string, error) {
+	secret := os.Getenv("UNSUBSCRIBE_SECRET")
+	if secret == "" {
+		return "", errors.New("UNSUBSCRIBE_SECRET is not set")
+	}
+	payload := fmt.Sprintf("%d.%s", userID, notificationType)
+	return payload + "." + unsubscribeSignature(secret, payload), nil
+}
+
+// ParseUnsubscribeToken verifies a token from UnsubscribeToken
+func ParseUnsubscribeToken(token string) (uint, string, bool) {
+	secret := os.Getenv("UNSUBSCRIBE_SECRET")
+	i := strings.LastIndex(token, ".")
+	if secret == "" || i < 0 {
+		return 0, "", false
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(unsubscribeSignature(secret, payload))) {
+		return 0, "", false
+	}
+
+	id, notificationType, ok := strings.Cut(payload, ".")
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if !ok || err != nil || !ValidType(notificationType) {
+		return 0, "", false
+	}
+	return uint(userID), notificationType, true
+}
+
+// UnsubscribeURL is the link that turns off email of one type for one user.
+// It serves both the footer link and RFC 8058 one-click unsubscribe.
+func UnsubscribeURL(userID uint, notificationType string) (string, error) {
+	token, err := UnsubscribeToken(userID, notificationType)
+	if err != nil {
+		return "", err
+	}
+	return config.BaseURL() + "/unsubscribe?token=" + url.QueryEscape(token), nil
+}
+
+func unsubscribeSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("unsubscribe:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
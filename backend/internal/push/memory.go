@@ -0,0 +1,49 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MemorySender records notifications instead of sending them, for tests
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+	invalid  map[string]bool
+}
+
+// NewMemorySender creates an empty MemorySender
+func NewMemorySender() *MemorySender {
+	return &MemorySender{invalid: map[string]bool{}}
+}
+
+// Name identifies the provider on delivery receipts
+func (s *MemorySender) Name() string {
+	return "memory"
+}
+
+// Send records the notification, or returns ErrInvalidToken for tokens marked with Unregister
+func (s *MemorySender) Send(ctx context.Context, message Message) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.invalid[message.Token] {
+		return "", ErrInvalidToken
+	}
+	s.messages = append(s.messages, message)
+	return fmt.Sprintf("memory-%d", len(s.messages)), nil
+}
+
+// Unregister makes sends to a token fail as if the app had been uninstalled
+func (s *MemorySender) Unregister(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalid[token] = true
+}
+
+// Messages returns a copy of the recorded notifications, oldest first
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
@@ -0,0 +1,51 @@
+// Package push sends mobile push notifications through pluggable providers
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInvalidToken means the device token is no longer registered and should be forgotten
+var ErrInvalidToken = errors.New("push: device token is not registered")
+
+// Message is a push notification to one device
+type Message struct {
+	Token string // Device registration token
+	Title string
+	Body  string
+	Data  map[string]string // Delivered to the app alongside the notification
+}
+
+// Sender delivers push notifications. Send returns the provider's message ID.
+// Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, message Message) (string, error)
+	Name() string
+}
+
+// NewSenderFromEnv builds the sender selected by PUSH_PROVIDER: "fcm" or
+// "memory". It returns nil when PUSH_PROVIDER is unset, disabling push.
+func NewSenderFromEnv() (Sender, error) {
+	switch provider := os.Getenv("PUSH_PROVIDER"); provider {
+	case "":
+		return nil, nil
+	case "fcm":
+		config := FCMConfig{
+			BaseURL:   os.Getenv("FCM_BASE_URL"),
+			ProjectID: os.Getenv("FCM_PROJECT_ID"),
+		}
+		accessToken := os.Getenv("FCM_ACCESS_TOKEN")
+		if config.ProjectID == "" || accessToken == "" {
+			return nil, fmt.Errorf("FCM_PROJECT_ID and FCM_ACCESS_TOKEN are required")
+		}
+		config.TokenSource = func(context.Context) (string, error) { return accessToken, nil }
+		return NewFCMSender(config), nil
+	case "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown PUSH_PROVIDER %q", provider)
+	}
+}
@@ -0,0 +1,80 @@
+package push
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// FCMStub is a local HTTP server that accepts FCM HTTP v1 send calls,
+// for exercising FCMSender without the real service
+type FCMStub struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []Message
+	invalid  map[string]bool
+}
+
+// NewFCMStub starts a stub server. Point FCMConfig.BaseURL at its URL.
+func NewFCMStub() *FCMStub {
+	stub := &FCMStub{invalid: map[string]bool{}}
+	stub.Server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	return stub
+}
+
+// Unregister makes the stub reject a token with UNREGISTERED
+func (s *FCMStub) Unregister(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalid[token] = true
+}
+
+// Messages returns the notifications received so far
+func (s *FCMStub) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *FCMStub) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/messages:send") {
+		writeFCMError(w, http.StatusNotFound, "NOT_FOUND", "")
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeFCMError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "")
+		return
+	}
+	var request fcmRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Message.Token == "" {
+		writeFCMError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.invalid[request.Message.Token] {
+		writeFCMError(w, http.StatusNotFound, "NOT_FOUND", "UNREGISTERED")
+		return
+	}
+	s.messages = append(s.messages, Message{
+		Token: request.Message.Token,
+		Title: request.Message.Notification.Title,
+		Body:  request.Message.Notification.Body,
+		Data:  request.Message.Data,
+	})
+	json.NewEncoder(w).Encode(map[string]string{"name": fmt.Sprintf("projects/stub/messages/%d", len(s.messages))})
+}
+
+func writeFCMError(w http.ResponseWriter, code int, status, errorCode string) {
+	w.WriteHeader(code)
+	body := map[string]interface{}{"code": code, "status": status, "message": strings.ToLower(status)}
+	if errorCode != "" {
+		body["details"] = []map[string]string{{"errorCode": errorCode}}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": body})
+}
@@ -0,0 +1,209 @@
+// Package rbac holds the roles and permissions that decide what each account
+// may do. Roles and permissions live in the database; the built-in ones are
+// seeded at startup.
+package rbac
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Permissions checked by the routes
+const (
+	AccountSelf        = "account:self"        // Own profile, preferences and devices
+	TicketsPurchase    = "tickets:purchase"    // Buy tickets, hold seats and allocate sites
+	NotificationsRead  = "notifications:read"  // Own notifications
+	EventsRead         = "events:read"         // Every event, including archived ones
+	EventsWrite        = "events:write"        // Create, edit, archive and delete events
+	LineupWrite        = "lineup:write"        // Stages, artists, performances and schedule publishing
+	TicketsWrite       = "tickets:write"       // Ticket types and batches
+	SeatingWrite       = "seating:write"       // Sites, venue sections and site allocations
+	RemindersWrite     = "reminders:write"     // Reminder rules
+	BroadcastsSend     = "broadcasts:send"     // Message attendees
+	EmailsRead         = "emails:read"         // The email outbox
+	EmailsResend       = "emails:resend"       // Resend outbox emails
+	TransactionsRead   = "transactions:read"   // Every purchase and waitlist
+	TransactionsWrite  = "transactions:write"  // Buy tickets, confirm payments and join waitlists on behalf of users
+	TransactionsRefund = "transactions:refund" // Refund purchases
+	CheckinScan        = "checkin:scan"        // Admit ticket holders at the gate
+	RolesManage        = "roles:manage"        // Roles, role assignments and staff accounts
+	AccountsUnlock     = "accounts:unlock"     // Lift login lockouts
+)
+
+// catalog describes every permission
+var catalog = []models.Permission{
+	{Name: AccountSelf, Description: "Manage your own profile, preferences and devices"},
+	{Name: TicketsPurchase, Description: "Buy tickets, hold seats and allocate sites"},
+	{Name: NotificationsRead, Description: "Read your own notifications"},
+	{Name: EventsRead, Description: "View every event, including archived ones"},
+	{Name: EventsWrite, Description: "Create, edit, archive and delete events"},
+	{Name: LineupWrite, Description: "Manage stages, artists, performances and publish schedules"},
+	{Name: TicketsWrite, Description: "Manage ticket types and batches"},
+	{Name: SeatingWrite, Description: "Manage sites, venue sections and site allocations"},
+	{Name: RemindersWrite, Description: "Manage reminder rules"},
+	{Name: BroadcastsSend, Description: "Send broadcasts to attendees"},
+	{Name: EmailsRead, Description: "View the email outbox"},
+	{Name: EmailsResend, Description: "Resend outbox emails"},
+	{Name: TransactionsRead, Description: "View every purchase and waitlist"},
+	{Name: TransactionsWrite, Description: "Buy tickets, confirm payments and join waitlists on behalf of users"},
+	{Name: TransactionsRefund, Description: "Refund purchases"},
+	{Name: CheckinScan, Description: "Check ticket holders in at the gate"},
+	{Name: RolesManage, Description: "Manage roles, role assignments and staff accounts"},
+	{Name: AccountsUnlock, Description: "Unlock accounts locked after failed logins"},
+}
+
+// Built-in role names
+const (
+	SuperAdmin = "super_admin"
+	Customer   = "customer"
+)
+
+// builtInRoles are seeded with these permissions. Each grant is applied once,
+// when first listed here, so later edits through the API are kept.
+// super_admin always holds every permission.
+var builtInRoles = []struct {
+	role        models.Role
+	permissions []string
+}{
+	{models.Role{Name: SuperAdmin, AccountType: "admin", Description: "Full access, including managing roles"}, nil},
+	{models.Role{Name: "event_manager", AccountType: "admin", Description: "Runs events, lineups, tickets, seating and attendee messaging"},
+		[]string{EventsRead, EventsWrite, LineupWrite, TicketsWrite, SeatingWrite, RemindersWrite, BroadcastsSend}},
+	{models.Role{Name: "gate_staff", AccountType: "admin", Description: "Scans tickets at the gate"},
+		[]string{CheckinScan}},
+	{models.Role{Name: "support", AccountType: "admin", Description: "Helps attendees with purchases and emails"},
+		[]string{EventsRead, TransactionsRead, TransactionsWrite, EmailsRead, EmailsResend, AccountsUnlock}},
+	{models.Role{Name: "finance", AccountType: "admin", Description: "Reviews and refunds purchases"},
+		[]string{TransactionsRead, TransactionsRefund}},
+	{models.Role{Name: Customer, AccountType: "user", Description: "Every user account holds this role"},
+		[]string{AccountSelf, TicketsPurchase, NotificationsRead}},
+}
+
+// Seed creates the permission catalog and built-in roles, and on first run
+// makes every existing admin a super_admin so nobody loses access
+func Seed() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&catalog).Error; err != nil {
+			return err
+		}
+		var permissions []models.Permission
+		if err := tx.Find(&permissions).Error; err != nil {
+			return err
+		}
+		byName := make(map[string]models.Permission, len(permissions))
+		for _, permission := range permissions {
+			byName[permission.Name] = permission
+		}
+
+		for _, builtIn := range builtInRoles {
+			role := builtIn.role
+			role.BuiltIn = true
+			result := tx.Where(models.Role{Name: role.Name}).FirstOrCreate(&role)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			grant := permissions
+			if role.Name != SuperAdmin {
+				var err error
+				if grant, err = unseededGrants(tx, role.Name, builtIn.permissions, byName); err != nil {
+					return err
+				}
+			}
+			if len(grant) > 0 {
+				if err := tx.Model(&role).Association("Permissions").Append(grant); err != nil {
+					return err
+				}
+			}
+		}
+
+		return bootstrapSuperAdmins(tx)
+	})
+}
+
+// unseededGrants returns the permissions listed for a built-in role that the
+// seed has not granted it before, and records them as granted
+func unseededGrants(tx *gorm.DB, roleName string, names []string, byName map[string]models.Permission) ([]models.Permission, error) {
+	var seeded []string
+	if err := tx.Model(&models.RoleGrantSeed{}).Where("role_name = ?", roleName).Pluck("permission_name", &seeded).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(seeded))
+	for _, name := range seeded {
+		done[name] = true
+	}
+
+	var grant []models.Permission
+	for _, name := range names {
+		if done[name] {
+			continue
+		}
+		seed := models.RoleGrantSeed{RoleName: roleName, PermissionName: name}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+			return nil, err
+		}
+		grant = append(grant, byName[name])
+	}
+	return grant, nil
+}
+
+// bootstrapSuperAdmins assigns super_admin to every admin while no admin has
+// any role yet, i.e. on the first start with roles
+func bootstrapSuperAdmins(tx *gorm.DB) error {
+	var assigned int64
+	if err := tx.Model(&models.AccountRole{}).Where("account_type = ?", "admin").Count(&assigned).Error; err != nil {
+		return err
+	}
+	if assigned > 0 {
+		return nil
+	}
+
+	var superAdmin models.Role
+	if err := tx.Where("name = ?", SuperAdmin).First(&superAdmin).Error; err != nil {
+		return err
+	}
+	var adminIDs []uint
+	if err := tx.Model(&models.Admin{}).Pluck("admin_id", &adminIDs).Error; err != nil {
+		return err
+	}
+	for _, adminID := range adminIDs {
+		if err := tx.Create(&models.AccountRole{AccountType: "admin", AccountID: adminID, RoleID: superAdmin.RoleID}).Error; err != nil {
+			return err
+		}
+	}
+	if len(adminIDs) > 0 {
+		log.Printf("Granted %s to %d existing admins\n", SuperAdmin, len(adminIDs))
+	}
+	return nil
+}
+
+// PermissionsOf returns the permissions an account holds through its roles.
+// User accounts always hold the customer role.
+func PermissionsOf(accountType string, accountID uint) (map[string]bool, error) {
+	roles := config.DB.Model(&models.AccountRole{}).Select("role_id").
+		Where("account_type = ? AND account_id = ?", accountType, accountID)
+	if accountType == "user" {
+		roles = config.DB.Model(&models.Role{}).Select("role_id").
+			Where("role_id IN (?) OR name = ?", roles, Customer)
+	}
+
+	var names []string
+	err := config.DB.Model(&models.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_permission_id = permissions.permission_id").
+		Joins("JOIN roles ON roles.role_id = role_permissions.role_role_id").
+		Where("roles.role_id IN (?) AND roles.account_type = ?", roles, accountType).
+		Pluck("permissions.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+	return permissions, nil
+}
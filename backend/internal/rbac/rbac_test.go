@@ -0,0 +1,93 @@
+package rbac
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"coachella-backend/internal/testdb"
+	"testing"
+)
+
+// holds reports whether the built-in role holds permission
+func holds(t *testing.T, roleName, permission string) bool {
+	t.Helper()
+	var role models.Role
+	if err := config.DB.Preload("Permissions").Where("name = ?", roleName).First(&role).Error; err != nil {
+		t.Fatalf("load role %s: %v", roleName, err)
+	}
+	for _, p := range role.Permissions {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// revoke removes permission from the role as an admin would through the API
+func revoke(t *testing.T, roleName, permission string) {
+	t.Helper()
+	var role models.Role
+	var p models.Permission
+	config.DB.Where("name = ?", roleName).First(&role)
+	config.DB.Where("name = ?", permission).First(&p)
+	if err := config.DB.Model(&role).Association("Permissions").Delete(&p); err != nil {
+		t.Fatalf("revoke %s from %s: %v", permission, roleName, err)
+	}
+}
+
+func TestSeedGrantsNewPermissionsOnce(t *testing.T) {
+	testdb.Use(t)
+	if err := Seed(); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	if !holds(t, "support", EmailsResend) {
+		t.Fatalf("support does not hold %s after the first seed", EmailsResend)
+	}
+
+	// A database seeded by a release from before support held emails:resend
+	revoke(t, "support", EmailsResend)
+	config.DB.Where("role_name = ? AND permission_name = ?", "support", EmailsResend).Delete(&models.RoleGrantSeed{})
+	// ...and one where an admin took accounts:unlock away from support
+	revoke(t, "support", AccountsUnlock)
+
+	if err := Seed(); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	if !holds(t, "support", EmailsResend) {
+		t.Errorf("support does not hold %s, newly listed for it", EmailsResend)
+	}
+	if holds(t, "support", AccountsUnlock) {
+		t.Errorf("support holds %s again after an admin removed it", AccountsUnlock)
+	}
+	if !holds(t, SuperAdmin, EmailsResend) {
+		t.Errorf("%s does not hold %s", SuperAdmin, EmailsResend)
+	}
+}
+
+func TestSeedBootstrapsSuperAdmins(t *testing.T) {
+	testdb.Use(t)
+	admin := models.Admin{Name: "Owner", Email: "owner@example.com", Password: "x"}
+	testdb.Create(t, &admin)
+
+	// Seeding again must not grant super_admin to admins added since
+	for i := 0; i < 2; i++ {
+		if err := Seed(); err != nil {
+			t.Fatalf("Seed: %v", err)
+		}
+		if i == 0 {
+			testdb.Create(t, &models.Admin{Name: "Gate 3", Email: "gate3@example.com", Password: "x"})
+		}
+	}
+
+	permissions, err := PermissionsOf("admin", admin.AdminID)
+	if err != nil {
+		t.Fatalf("PermissionsOf: %v", err)
+	}
+	if len(permissions) != len(catalog) {
+		t.Errorf("existing admin holds %d permissions, want all %d", len(permissions), len(catalog))
+	}
+	var assigned int64
+	config.DB.Model(&models.AccountRole{}).Count(&assigned)
+	if assigned != 1 {
+		t.Errorf("%d role assignments, want only the existing admin's", assigned)
+	}
+}
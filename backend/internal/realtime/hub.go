@@ -0,0 +1,164 @@
+// Package realtime pushes new notifications to connected clients
+package realtime
+
+import (
+	"coachella-backend/internal/models"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// subscriberBuffer is how many notifications a slow client may fall behind
+// before it is disconnected. Clients reconnect and replay what they missed.
+const subscriberBuffer = 64
+
+// Hub fans notifications out to the subscriptions of the users they belong to
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[*Subscription]struct{}
+}
+
+// Subscription receives a user's notifications until it is closed.
+// C is closed when the subscription ends, including when it falls behind.
+type Subscription struct {
+	C      <-chan models.Notification
+	ch     chan models.Notification
+	hub    *Hub
+	userID uint
+	once   sync.Once
+}
+
+// NewHub creates a hub with no subscribers. Notifications only reach it
+// through Publish; see NewHubFromEnv for wiring it to the database.
+func NewHub() *Hub {
+	return &Hub{subscribers: map[uint]map[*Subscription]struct{}{}}
+}
+
+// NewHubFromEnv creates a hub fed according to REALTIME_BACKEND:
+//   - "memory" (default) publishes notifications as this instance commits them.
+//     Clients only see notifications created by the instance they are connected to.
+//   - "db" polls the notifications table, so every instance sees every notification.
+func NewHubFromEnv(db *gorm.DB) (*Hub, error) {
+	hub := NewHub()
+	switch backend := os.Getenv("REALTIME_BACKEND"); backend {
+	case "", "memory":
+		return hub, hub.PublishOnCreate(db)
+	case "db":
+		go hub.PollDatabase(db, time.Second)
+		return hub, nil
+	default:
+		return nil, fmt.Errorf("unknown REALTIME_BACKEND %q", backend)
+	}
+}
+
+// Subscribe starts receiving the notifications of a user
+func (h *Hub) Subscribe(userID uint) *Subscription {
+	ch := make(chan models.Notification, subscriberBuffer)
+	subscription := &Subscription{C: ch, ch: ch, hub: h, userID: userID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[*Subscription]struct{}{}
+	}
+	h.subscribers[userID][subscription] = struct{}{}
+	return subscription
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Subscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.hub.subscribers[s.userID], s)
+		if len(s.hub.subscribers[s.userID]) == 0 {
+			delete(s.hub.subscribers, s.userID)
+		}
+		close(s.ch)
+	})
+}
+
+// Publish delivers a notification to its user's subscriptions without blocking
+func (h *Hub) Publish(notification models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for subscription := range h.subscribers[notification.UserID] {
+		select {
+		case subscription.ch <- notification:
+		default:
+			subscription.closeLocked()
+		}
+	}
+}
+
+// PublishOnCreate publishes every notification created through db once it
+// has been committed. Rows created inside a caller's transaction are not
+// published, since the hook cannot tell whether that transaction commits;
+// notify.Send creates notifications outside any transaction.
+func (h *Hub) PublishOnCreate(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("realtime:publish", func(tx *gorm.DB) {
+		if tx.Error != nil || !tx.Statement.ReflectValue.IsValid() || !tx.Statement.ReflectValue.CanInterface() {
+			return
+		}
+		// After its own transaction commits, GORM hands the statement back its
+		// pool; a connection that is still a transaction belongs to the caller
+		if _, inTransaction := tx.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+			return
+		}
+		switch value := tx.Statement.ReflectValue.Interface().(type) {
+		case models.Notification:
+			h.Publish(value)
+		case []models.Notification:
+			for _, notification := range value {
+				h.Publish(notification)
+			}
+		}
+	})
+}
+
+// pollOverlap is how far back each poll looks before the previous one.
+// Transactions commit out of ID and creation order, and instances' clocks
+// differ, so a row can appear with a creation time before the last poll.
+const pollOverlap = 30 * time.Second
+
+// PollDatabase publishes notifications as they appear in the notifications
+// table, whichever instance created them. It runs until the process exits.
+func (h *Hub) PollDatabase(db *gorm.DB, interval time.Duration) {
+	since := time.Now()
+	published := map[uint]time.Time{} // IDs published within the overlap, by creation time
+
+	for range time.Tick(interval) {
+		polledAt := time.Now()
+		var notifications []models.Notification
+		err := db.Where("created_at >= ?", since.Add(-pollOverlap)).
+			Order("created_at, notification_id").
+			Find(&notifications).Error
+		if err != nil {
+			log.Printf("Failed to poll notifications: %v\n", err)
+			continue
+		}
+		for _, notification := range notifications {
+			if _, ok := published[notification.NotificationID]; ok {
+				continue
+			}
+			h.Publish(notification)
+			published[notification.NotificationID] = notification.CreatedAt
+		}
+
+		// Forget IDs the next poll can no longer return
+		since = polledAt
+		for id, createdAt := range published {
+			if createdAt.Before(since.Add(-pollOverlap)) {
+				delete(published, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package signing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sealedPrefix marks a private key encrypted with the key-encryption key.
+// Keys stored before encryption are plain PEM and are sealed when loaded.
+const sealedPrefix = "v1:"
+
+// keyEncryption returns the AEAD for JWT_KEY_ENCRYPTION_KEY, a base64-encoded
+// 32-byte AES-256 key, e.g. from `openssl rand -base64 32`. Keep it out of
+// the database: it is what makes a leaked signing_keys table useless.
+func keyEncryption() (cipher.AEAD, error) {
+	encoded := os.Getenv("JWT_KEY_ENCRYPTION_KEY")
+	if encoded == "" {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY is not set; generate one with `openssl rand -base64 32`")
+	}
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(secret) != 32 {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY must be 32 bytes, base64-encoded")
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealPrivateKey encrypts a PEM private key for storage. The kid is
+// authenticated with it, so a sealed key cannot be moved to another row.
+func sealPrivateKey(kid string, privatePEM []byte) (string, error) {
+	aead, err := keyEncryption()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, privatePEM, []byte(kid))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openPrivateKey returns the PEM of a stored private key, and whether it was
+// stored in plain text
+func openPrivateKey(kid, stored string) ([]byte, bool, error) {
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		if strings.HasPrefix(stored, "-----BEGIN") {
+			return []byte(stored), true, nil
+		}
+		return nil, false, errors.New("private key is neither sealed nor PEM")
+	}
+	aead, err := keyEncryption()
+	if err != nil {
+		return nil, false, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, false, errors.New("sealed private key is malformed")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	privatePEM, err := aead.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		return nil, false, fmt.Errorf("decrypting private key (wrong JWT_KEY_ENCRYPTION_KEY?): %w", err)
+	}
+	return privatePEM, false, nil
+}
@@ -0,0 +1,54 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"time"
+)
+
+// JWK is a public key in JSON Web Key form (RFC 7517, RFC 8037)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"` // OKP keys
+	X         string `json:"x,omitempty"`   // OKP keys
+	N         string `json:"n,omitempty"`   // RSA keys
+	E         string `json:"e,omitempty"`   // RSA keys
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every verification key, including keys published ahead of
+// signing and retired keys still within their grace period
+func JWKS() JWKSet {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	now := time.Now()
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for _, k := range keys {
+		if k.expiresAt != nil && !now.Before(*k.expiresAt) {
+			continue
+		}
+		jwk := JWK{KeyID: k.id, Use: "sig", Algorithm: k.method.Alg()}
+		switch public := k.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve, jwk.X = "OKP", "Ed25519", base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,318 @@
+// Package signing signs and verifies JWTs with rotating asymmetric keys
+// stored in the database, and publishes the verification keys as a JWKS.
+package signing
+
+import (
+	"coachella-backend/config"
+	"coachella-backend/internal/models"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms new keys can be generated for
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+// Audiences of the tokens signed with the keys. Every token names one, and
+// is only accepted where that audience is expected, so a token issued for
+// one purpose can't be used for another.
+const (
+	AudienceAccess      = "coachella:access"              // Access tokens sent as Bearer tokens
+	AudienceMFA         = "coachella:mfa"                 // Login challenges waiting for a second factor
+	AudienceVerifyEmail = "coachella:verify_email"        // Email verification links
+	AudienceStream      = "coachella:notification_stream" // Notification stream tickets
+)
+
+// key is a parsed SigningKey
+type key struct {
+	id          string
+	method      jwt.SigningMethod
+	private     crypto.Signer
+	public      crypto.PublicKey
+	activatedAt time.Time
+	expiresAt   *time.Time
+}
+
+var (
+	mu   sync.RWMutex
+	keys []key // Unexpired keys, newest first
+)
+
+// Load reads the unexpired keys from the database, generating the first key
+// when there is none able to sign yet. Private keys are stored encrypted with
+// JWT_KEY_ENCRYPTION_KEY; keys stored before that are encrypted as they load.
+// It must run after the database connects.
+func Load() error {
+	if _, err := keyEncryption(); err != nil {
+		return err
+	}
+	loaded, err := loadKeys()
+	if err != nil {
+		return err
+	}
+	if current(loaded, time.Now()) == nil {
+		if _, err := createKey(time.Now()); err != nil {
+			return err
+		}
+		if loaded, err = loadKeys(); err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	keys = loaded
+	mu.Unlock()
+	return nil
+}
+
+// Refresh reloads the keys, picking up keys other instances rotated in.
+// It is run every minute by the scheduler.
+func Refresh() {
+	if err := Load(); err != nil {
+		log.Printf("Failed to reload signing keys: %v\n", err)
+	}
+}
+
+// Rotate generates the next key once the current one has signed for the
+// rotation period (JWT_KEY_ROTATION, default 720h). The new key is published
+// for JWT_KEY_PREPUBLISH (default 1h) before it signs, so verifiers caching
+// the JWKS see it first, and the old key keeps verifying for JWT_KEY_GRACE
+// (default 72h) after, covering the tokens it signed. It is run hourly.
+func Rotate() {
+	now := time.Now()
+	mu.RLock()
+	signing := current(keys, now)
+	pending := len(keys) > 0 && keys[0].activatedAt.After(now)
+	mu.RUnlock()
+	if signing == nil || pending || now.Sub(signing.activatedAt) < duration("JWT_KEY_ROTATION", 30*24*time.Hour) {
+		return
+	}
+
+	if err := RotateAt(now.Add(duration("JWT_KEY_PREPUBLISH", time.Hour))); err != nil {
+		log.Printf("Failed to rotate signing keys: %v\n", err)
+	}
+}
+
+// RotateAt generates a key that starts signing at activateAt, and expires the
+// keys it replaces JWT_KEY_GRACE after that
+func RotateAt(activateAt time.Time) error {
+	if _, err := createKey(activateAt); err != nil {
+		return err
+	}
+	expiresAt := activateAt.Add(duration("JWT_KEY_GRACE", 72*time.Hour))
+	err := config.DB.Model(&models.SigningKey{}).
+		Where("activated_at < ? AND expires_at IS NULL", activateAt).
+		Update("expires_at", expiresAt).Error
+	if err != nil {
+		return err
+	}
+	log.Printf("Rotated signing keys; the new key signs from %s\n", activateAt.Format(time.RFC3339))
+	return Load()
+}
+
+// Sign signs claims for an audience with the current key, naming it in the
+// kid header
+func Sign(audience string, claims jwt.MapClaims) (string, error) {
+	mu.RLock()
+	signing := current(keys, time.Now())
+	mu.RUnlock()
+	if signing == nil {
+		return "", errors.New("signing: no active key; call Load first")
+	}
+
+	claims["aud"] = audience
+	token := jwt.NewWithClaims(signing.method, claims)
+	token.Header["kid"] = signing.id
+	return token.SignedString(signing.private)
+}
+
+// Parse verifies a token against the key named in its kid header and returns
+// it. Expired tokens, tokens without an expiry and tokens for any other
+// audience are rejected.
+func Parse(tokenString, audience string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, verificationKey,
+		jwt.WithValidMethods([]string{AlgorithmEdDSA, AlgorithmRS256}),
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(audience))
+}
+
+// verificationKey is the jwt.Keyfunc finding the public key of a token's kid
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	mu.RLock()
+	defer mu.RUnlock()
+	now := time.Now()
+	for _, k := range keys {
+		if k.id != kid {
+			continue
+		}
+		if k.expiresAt != nil && !now.Before(*k.expiresAt) {
+			return nil, fmt.Errorf("signing key %q has expired", kid)
+		}
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("token algorithm %s does not match key %q", token.Method.Alg(), kid)
+		}
+		return k.public, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// current returns the newest key that has started signing, or nil
+func current(keys []key, now time.Time) *key {
+	for i := range keys {
+		if !keys[i].activatedAt.After(now) {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+func loadKeys() ([]key, error) {
+	var rows []models.SigningKey
+	err := config.DB.
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("activated_at DESC, signing_key_id DESC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]key, 0, len(rows))
+	for _, row := range rows {
+		privatePEM, plain, err := openPrivateKey(row.KeyID, row.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", row.KeyID, err)
+		}
+		k, err := parseKey(row, privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", row.KeyID, err)
+		}
+		if plain {
+			if err := sealStoredKey(row, privatePEM); err != nil {
+				return nil, fmt.Errorf("signing key %q: %w", row.KeyID, err)
+			}
+		}
+		loaded = append(loaded, k)
+	}
+	return loaded, nil
+}
+
+// sealStoredKey replaces a private key stored in plain text with its sealed form
+func sealStoredKey(row models.SigningKey, privatePEM []byte) error {
+	sealed, err := sealPrivateKey(row.KeyID, privatePEM)
+	if err != nil {
+		return err
+	}
+	// Another instance may have sealed it first; either sealed form opens
+	err = config.DB.Model(&models.SigningKey{}).
+		Where("signing_key_id = ? AND private_key = ?", row.SigningKeyID, row.PrivateKey).
+		Update("private_key", sealed).Error
+	if err != nil {
+		return err
+	}
+	log.Printf("Encrypted signing key %s at rest\n", row.KeyID)
+	return nil
+}
+
+func parseKey(row models.SigningKey, privatePEM []byte) (key, error) {
+	method := jwt.GetSigningMethod(row.Algorithm)
+	if method == nil {
+		return key{}, fmt.Errorf("unsupported algorithm %q", row.Algorithm)
+	}
+	block, _ := pem.Decode(privatePEM)
+	if block == nil {
+		return key{}, errors.New("private key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return key{}, err
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return key{}, errors.New("private key cannot sign")
+	}
+	return key{
+		id:          row.KeyID,
+		method:      method,
+		private:     signer,
+		public:      signer.Public(),
+		activatedAt: row.ActivatedAt,
+		expiresAt:   row.ExpiresAt,
+	}, nil
+}
+
+// createKey generates and stores a key of the JWT_SIGNING_ALG algorithm
+// (EdDSA by default, or RS256) that starts signing at activateAt
+func createKey(activateAt time.Time) (models.SigningKey, error) {
+	algorithm := os.Getenv("JWT_SIGNING_ALG")
+	if algorithm == "" {
+		algorithm = AlgorithmEdDSA
+	}
+
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return models.SigningKey{}, fmt.Errorf("unsupported JWT_SIGNING_ALG %q; use EdDSA or RS256", algorithm)
+	}
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return models.SigningKey{}, err
+	}
+
+	kid := hex.EncodeToString(id)
+	sealed, err := sealPrivateKey(kid, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	row := models.SigningKey{
+		KeyID:       kid,
+		Algorithm:   algorithm,
+		PrivateKey:  sealed,
+		PublicKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		ActivatedAt: activateAt,
+	}
+	return row, config.DB.Create(&row).Error
+}
+
+// duration reads a duration from the environment, falling back when unset or invalid
+func duration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MemorySender records messages instead of sending them, for tests
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+// NewMemorySender creates an empty MemorySender
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// Name identifies the provider on delivery receipts
+func (s *MemorySender) Name() string {
+	return "memory"
+}
+
+// Send records the message, or returns the error set with FailWith
+func (s *MemorySender) Send(ctx context.Context, message Message) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	s.messages = append(s.messages, message)
+	return fmt.Sprintf("memory-%d", len(s.messages)), nil
+}
+
+// FailWith makes every following Send return err. A nil err restores normal delivery.
+func (s *MemorySender) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Messages returns a copy of the recorded messages, oldest first
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
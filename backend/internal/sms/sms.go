@@ -0,0 +1,54 @@
+// Package sms sends text messages through pluggable providers
+package sms
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Message is a text message to one phone number
+type Message struct {
+	To   string // E.164, e.g. +6281234567890
+	Body string
+}
+
+// Sender delivers text messages. Send returns the provider's message ID,
+// which delivery status callbacks refer to. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, message Message) (string, error)
+	Name() string
+}
+
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// ValidPhone reports whether a number is in E.164 format
+func ValidPhone(phone string) bool {
+	return phonePattern.MatchString(phone)
+}
+
+// NewSenderFromEnv builds the sender selected by SMS_PROVIDER: "twilio" or
+// "memory". It returns nil when SMS_PROVIDER is unset, disabling SMS.
+func NewSenderFromEnv() (Sender, error) {
+	switch provider := os.Getenv("SMS_PROVIDER"); provider {
+	case "":
+		return nil, nil
+	case "twilio":
+		config := TwilioConfig{
+			BaseURL:        os.Getenv("TWILIO_BASE_URL"),
+			AccountSID:     os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:      os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:           os.Getenv("TWILIO_FROM"),
+			StatusCallback: os.Getenv("TWILIO_STATUS_CALLBACK_URL"),
+		}
+		if config.AccountSID == "" || config.AuthToken == "" || config.From == "" {
+			return nil, fmt.Errorf("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM are required")
+		}
+		return NewTwilioSender(config), nil
+	case "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS_PROVIDER %q", provider)
+	}
+}
@@ -0,0 +1,60 @@
+package sms
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// TwilioStub is a local HTTP server that accepts Twilio Messages API calls,
+// for exercising TwilioSender without the real service
+type TwilioStub struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewTwilioStub starts a stub server. Point TwilioConfig.BaseURL at its URL.
+func NewTwilioStub() *TwilioStub {
+	stub := &TwilioStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	return stub
+}
+
+// Messages returns the messages received so far
+func (s *TwilioStub) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *TwilioStub) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/Messages.json") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 20404, "message": "The requested resource was not found"})
+		return
+	}
+	if _, _, ok := r.BasicAuth(); !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 20003, "message": "Authenticate"})
+		return
+	}
+	to := r.PostFormValue("To")
+	if !ValidPhone(to) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 21211, "message": "Invalid 'To' Phone Number"})
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, Message{To: to, Body: r.PostFormValue("Body")})
+	sid := fmt.Sprintf("SM%032d", len(s.messages))
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"sid": sid, "status": "queued"})
+}
@@ -0,0 +1,58 @@
+package sms
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/url"
+	"testing"
+)
+
+func TestVerifyTwilioSignature(t *testing.T) {
+	// The example from Twilio's webhook security documentation
+	const (
+		authToken   = "12345"
+		callbackURL = "https://mycompany.com/myapp.php?foo=1&bar=2"
+		signature   = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+	)
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	tampered := url.Values{}
+	for key, values := range params {
+		tampered[key] = values
+	}
+	tampered.Set("Digits", "4321")
+
+	// What anyone could sign the example with if the auth token were empty
+	unkeyed := hmac.New(sha1.New, nil)
+	unkeyed.Write([]byte(callbackURL + "CallSidCA1234567890ABCDECaller+12349013030Digits1234From+12349013030To+18005551212"))
+	unkeyedSignature := base64.StdEncoding.EncodeToString(unkeyed.Sum(nil))
+
+	tests := []struct {
+		name        string
+		authToken   string
+		callbackURL string
+		params      url.Values
+		signature   string
+		want        bool
+	}{
+		{"documented example", authToken, callbackURL, params, signature, true},
+		{"tampered parameter", authToken, callbackURL, tampered, signature, false},
+		{"other URL", authToken, "https://mycompany.com/myapp.php", params, signature, false},
+		{"wrong token", "54321", callbackURL, params, signature, false},
+		{"missing signature", authToken, callbackURL, params, "", false},
+		{"no token configured", "", callbackURL, params, unkeyedSignature, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyTwilioSignature(tt.authToken, tt.callbackURL, tt.params, tt.signature); got != tt.want {
+				t.Errorf("VerifyTwilioSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
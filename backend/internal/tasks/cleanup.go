@@ -2,7 +2,11 @@ package tasks
 
 import (
 	"coachella-backend/config"
+	"coachella-backend/internal/lockout"
 	"coachella-backend/internal/models"
+	"coachella-backend/internal/notify"
+	"context"
+	"fmt"
 	"log"
 	"time"
 )
@@ -25,7 +29,52 @@ func CleanUpExpiredTransactions() {
 			config.DB.Save(&ticket)
 		}
 
+		// Release any sites allocated to the purchase
+		config.DB.Where("transaction_id = ?", transaction.TransactionID).Delete(&models.SiteAllocation{})
+
+		// Release any seats bound to the purchase
+		config.DB.Where("transaction_id = ?", transaction.TransactionID).Delete(&models.SeatHold{})
+
+		// Tell the buyer the reservation lapsed
+		notify.Send(notify.Message{
+			UserID:  transaction.UserID,
+			Type:    notify.TypePaymentStatus,
+			Content: fmt.Sprintf("Your reservation #%d expired before payment was received and its tickets were released.", transaction.TransactionID),
+		})
+
 		log.Printf("Processed expired transaction: %d\n", transaction.TransactionID)
 	}
 }
 
+
+
+// CleanUpExpiredSeatHolds releases seat holds that lapsed without a purchase
+func CleanUpExpiredSeatHolds() {
+	result := config.DB.Where("transaction_id IS NULL AND expires_at <= ?", time.Now()).Delete(&models.SeatHold{})
+	if result.Error != nil {
+		log.Printf("Failed to release expired seat holds: %v\n", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Released %d expired seat holds\n", result.RowsAffected)
+	}
+}
+
+// CleanUpExpiredSessions deletes sessions a week after they expire, along with their refresh tokens
+func CleanUpExpiredSessions() {
+	result := config.DB.Where("expires_at <= ?", time.Now().AddDate(0, 0, -7)).Delete(&models.Session{})
+	if result.Error != nil {
+		log.Printf("Failed to delete expired sessions: %v\n", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Deleted %d expired sessions\n", result.RowsAffected)
+	}
+}
+
+// CleanUpLoginAttempts forgets failed logins that are too old to count
+func CleanUpLoginAttempts() {
+	if err := lockout.Prune(context.Background()); err != nil {
+		log.Printf("Failed to prune login attempts: %v\n", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package testdb opens in-memory SQLite databases for tests
+package testdb
+
+import (
+	"coachella-backend/config"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// dialector is SQLite with the MySQL enum columns of the models stored as text
+type dialector struct {
+	gorm.Dialector
+}
+
+func (d dialector) DataTypeOf(field *schema.Field) string {
+	if strings.HasPrefix(string(field.DataType), "enum(") {
+		return "text"
+	}
+	return d.Dialector.DataTypeOf(field)
+}
+
+// Translate reports SQLite's constraint violations as GORM errors, as the
+// MySQL dialector does
+func (d dialector) Translate(err error) error {
+	return d.Dialector.(gorm.ErrorTranslator).Translate(err)
+}
+
+func (d dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqlite.Migrator{Migrator: migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d, CreateIndexAfterCreateTable: true}}}
+}
+
+// Use points config.DB at a fresh in-memory SQLite database with every model
+// migrated, and restores the previous database when the test ends
+func Use(t testing.TB) {
+	t.Helper()
+	db, err := gorm.Open(dialector{sqlite.Open("file::memory:")}, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), TranslateError: true})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := config.Migrate(db); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		sqlDB.Close()
+	})
+}
+
+// Create inserts records into the test database
+func Create(t testing.TB, records ...interface{}) {
+	t.Helper()
+	for _, record := range records {
+		if err := config.DB.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key of RFC 6238 appendix B, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		step, ok := Validate(rfc6238Secret, tt.code, now)
+		if !ok {
+			t.Errorf("Validate(%q) at %d rejected the RFC code", tt.code, tt.unix)
+			continue
+		}
+		if step != Step(now) {
+			t.Errorf("Validate(%q) at %d matched step %d, want %d", tt.code, tt.unix, step, Step(now))
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   bool
+	}{
+		{"previous step", -period * time.Second, true},
+		{"next step", period * time.Second, true},
+		{"two steps early", -2 * period * time.Second, false},
+		{"two steps late", 2 * period * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(rfc6238Secret, "005924", now.Add(tt.offset)); ok != tt.want {
+				t.Errorf("Validate = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	for _, code := range []string{"", "00592", "0059240", "abcdef"} {
+		if _, ok := Validate(rfc6238Secret, code, now); ok {
+			t.Errorf("Validate(%q) accepted a malformed code", code)
+		}
+	}
+	if _, ok := Validate(rfc6238Secret, " 005 924 ", now); !ok {
+		t.Error("Validate rejected a code with spaces")
+	}
+}
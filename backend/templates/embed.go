@@ -0,0 +1,11 @@
+// Package templates embeds the email templates into the binary
+package templates
+
+import "embed"
+
+// Emails holds the email templates: shared layouts and partials, plus one
+// directory per locale with a .html and .txt file for each template. The all:
+// prefix keeps the "_" partial files, which go:embed skips by default.
+//
+//go:embed all:emails
+var Emails embed.FS